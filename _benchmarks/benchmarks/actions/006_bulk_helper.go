@@ -34,102 +34,110 @@ import (
 )
 
 func init() {
-	benchmarks.Register(
-		benchmarks.Action{
-			Name:     "bulk-helper",
-			Category: "helpers",
-
-			NumWarmups:     1,
-			NumRepetitions: 10,
-			NumOperations:  1000000,
-
-			SetupFunc: func(n int, c runner.Config) (*esapi.Response, error) {
-				var (
-					res *esapi.Response
-					err error
-
-					indexName     = "test-bench-bulk-helper"
-					indexSettings = `{"settings": { "number_of_shards": 3, "refresh_interval":"5s"}}`
-				)
-				res, _ = c.RunnerClient.Indices.Delete([]string{indexName})
-				if res != nil && res.Body != nil {
-					res.Body.Close()
-				}
-				res, err = c.RunnerClient.Indices.Create(
-					indexName,
-					c.RunnerClient.Indices.Create.WithBody(strings.NewReader(indexSettings)),
-					c.RunnerClient.Indices.Create.WithWaitForActiveShards("1"))
-				if err != nil {
-					return res, err
-				}
-				if res != nil && res.Body != nil {
-					res.Body.Close()
-				}
-				return res, err
-			},
+	benchmarks.Register(bulkHelperAction("bulk-helper", "test-bench-bulk-helper", esutil.BulkIndexerConfig{}))
+	benchmarks.Register(bulkHelperAction("bulk-helper-gzip", "test-bench-bulk-helper-gzip", esutil.BulkIndexerConfig{
+		CompressRequestBody: true,
+		PoolCompressor:      true,
+	}))
+}
 
-			RunnerFunc: func(n int, c runner.Config) (*esapi.Response, error) {
-				var (
-					err error
+// bulkHelperAction returns a benchmark action for the bulk indexer,
+// configured with the compression options from biConfig.
+func bulkHelperAction(name, indexName string, biConfig esutil.BulkIndexerConfig) benchmarks.Action {
+	return benchmarks.Action{
+		Name:     name,
+		Category: "helpers",
+
+		NumWarmups:     1,
+		NumRepetitions: 10,
+		NumOperations:  1000000,
+
+		SetupFunc: func(n int, c runner.Config) (*esapi.Response, error) {
+			var (
+				res *esapi.Response
+				err error
+
+				indexSettings = `{"settings": { "number_of_shards": 3, "refresh_interval":"5s"}}`
+			)
+			res, _ = c.RunnerClient.Indices.Delete([]string{indexName})
+			if res != nil && res.Body != nil {
+				res.Body.Close()
+			}
+			res, err = c.RunnerClient.Indices.Create(
+				indexName,
+				c.RunnerClient.Indices.Create.WithBody(strings.NewReader(indexSettings)),
+				c.RunnerClient.Indices.Create.WithWaitForActiveShards("1"))
+			if err != nil {
+				return res, err
+			}
+			if res != nil && res.Body != nil {
+				res.Body.Close()
+			}
+			return res, err
+		},
 
-					indexName = "test-bench-bulk-helper"
+		RunnerFunc: func(n int, c runner.Config) (*esapi.Response, error) {
+			var (
+				err error
+			)
+
+			var addresses []string
+			for _, u := range c.RunnerClient.Transport.(*elastictransport.Client).URLs() {
+				addresses = append(addresses, u.String())
+			}
+
+			es, err := elasticsearch.NewClient(elasticsearch.Config{
+				Addresses:     addresses,
+				RetryOnStatus: []int{502, 503, 504, 429}, // Retry on 429 TooManyRequests statuses
+				MaxRetries:    5,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			bi, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
+				Index:      indexName,
+				Client:     es,
+				NumWorkers: 8,
+				FlushBytes: 2e+6,
+				// FlushInterval: 30 * time.Second,
+
+				CompressRequestBody: biConfig.CompressRequestBody,
+				PoolCompressor:      biConfig.PoolCompressor,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			docBody := bytes.NewBuffer(bytes.ReplaceAll(benchmarks.DataSources["small"].Bytes(), []byte("\n"), []byte("")))
+			docBody.WriteRune('\n')
+
+			for i := 0; i < c.NumOperations; i++ {
+				err = bi.Add(
+					context.Background(),
+					esutil.BulkIndexerItem{
+						Action: "index",
+						Body:   bytes.NewReader(docBody.Bytes()),
+					},
 				)
-
-				var addresses []string
-				for _, u := range c.RunnerClient.Transport.(*elastictransport.Client).URLs() {
-					addresses = append(addresses, u.String())
-				}
-
-				es, err := elasticsearch.NewClient(elasticsearch.Config{
-					Addresses:     addresses,
-					RetryOnStatus: []int{502, 503, 504, 429}, // Retry on 429 TooManyRequests statuses
-					MaxRetries:    5,
-				})
 				if err != nil {
 					return nil, err
 				}
-
-				bi, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
-					Index:      indexName,
-					Client:     es,
-					NumWorkers: 8,
-					FlushBytes: 2e+6,
-					// FlushInterval: 30 * time.Second,
-				})
-				if err != nil {
-					return nil, err
-				}
-
-				docBody := bytes.NewBuffer(bytes.ReplaceAll(benchmarks.DataSources["small"].Bytes(), []byte("\n"), []byte("")))
-				docBody.WriteRune('\n')
-
-				for i := 0; i < c.NumOperations; i++ {
-					err = bi.Add(
-						context.Background(),
-						esutil.BulkIndexerItem{
-							Action: "index",
-							Body:   bytes.NewReader(docBody.Bytes()),
-						},
-					)
-					if err != nil {
-						return nil, err
-					}
-				}
-
-				err = bi.Close(context.Background())
-				if err != nil {
-					return nil, err
-				}
-
-				biStats := bi.Stats()
-				if biStats.NumFailed > 0 {
-					return nil, fmt.Errorf("Unexpected failures: %s", biStats)
-				}
-				if int(biStats.NumAdded) != c.NumOperations {
-					return nil, fmt.Errorf("Unexpected failures: added=%d, expected=%d", biStats.NumAdded, c.NumOperations)
-				}
-				return &esapi.Response{StatusCode: 200}, nil
-			},
+			}
+
+			err = bi.Close(context.Background())
+			if err != nil {
+				return nil, err
+			}
+
+			biStats := bi.Stats()
+			if biStats.NumFailed > 0 {
+				return nil, fmt.Errorf("Unexpected failures: %s", biStats)
+			}
+			if int(biStats.NumAdded) != c.NumOperations {
+				return nil, fmt.Errorf("Unexpected failures: added=%d, expected=%d", biStats.NumAdded, c.NumOperations)
+			}
+			return &esapi.Response{StatusCode: 200}, nil
 		},
-	)
+	}
 }
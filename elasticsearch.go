@@ -136,6 +136,7 @@ type BaseClient struct {
 	Transport           elastictransport.Interface
 	metaHeader          string
 	compatibilityHeader bool
+	compressRequestBody bool
 
 	disableMetaHeader   bool
 	productCheckMu      sync.RWMutex
@@ -190,6 +191,7 @@ func NewClient(cfg Config) (*Client, error) {
 			disableMetaHeader:   cfg.DisableMetaHeader,
 			metaHeader:          initMetaHeader(tp),
 			compatibilityHeader: cfg.EnableCompatibilityMode || compatibilityHeader,
+			compressRequestBody: cfg.CompressRequestBody,
 		},
 	}
 	client.API = esapi.New(client)
@@ -223,6 +225,7 @@ func NewTypedClient(cfg Config) (*TypedClient, error) {
 			disableMetaHeader:   cfg.DisableMetaHeader,
 			metaHeader:          metaHeader,
 			compatibilityHeader: cfg.EnableCompatibilityMode || compatibilityHeader,
+			compressRequestBody: cfg.CompressRequestBody,
 		},
 	}
 	client.API = typedapi.New(client)
@@ -362,6 +365,11 @@ func (c *BaseClient) InstrumentationEnabled() elastictransport.Instrumentation {
 	return nil
 }
 
+// CompressRequestBodyEnabled reports whether the client compresses the request bodies.
+func (c *BaseClient) CompressRequestBodyEnabled() bool {
+	return c.compressRequestBody
+}
+
 // doProductCheck calls f if there as not been a prior successful call to doProductCheck,
 // returning nil otherwise.
 func (c *BaseClient) doProductCheck(f func() error) error {
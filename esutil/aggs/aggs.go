@@ -0,0 +1,250 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package aggs builds the aggregations of a search request with a typed handle
+// per aggregation, which returns the typed aggregate from the response.
+//
+//	b := aggs.New()
+//	byTag := aggs.Terms(b, "by_tag", types.TermsAggregation{Field: some.String("tags")})
+//	avgPrice := aggs.Avg(byTag, "avg_price", types.AverageAggregation{Field: some.String("price")})
+//
+//	res, err := es.Search().Index("products").Aggregations(b.Aggregations()).Size(0).Do(ctx)
+//	...
+//	tags, err := byTag.Result(res)
+//	for _, bucket := range tags.Buckets {
+//		avg, err := avgPrice.In(bucket.Aggregations)
+//		...
+//	}
+//
+// Sub-aggregations of multi-bucket aggregations are read from the buckets with In.
+// Sub-aggregations of single bucket aggregations, such as filter or nested,
+// are read from the response with Result as well.
+//
+// The typed API requests the aggregations with typed keys, which is required
+// to decode the typed aggregates.
+package aggs
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// Parent is implemented by the builder and by the handles,
+// to add aggregations at the top level or as sub-aggregations.
+type Parent interface {
+	node() *node
+}
+
+// Builder collects the top level aggregations of a search request.
+type Builder struct {
+	root node
+}
+
+// New returns a new builder.
+func New() *Builder {
+	return &Builder{}
+}
+
+func (b *Builder) node() *node { return &b.root }
+
+// Aggregations returns the aggregations of the search request.
+func (b *Builder) Aggregations() map[string]types.Aggregations {
+	return b.root.build()
+}
+
+// node represents a single aggregation of the tree. Names must be unique among siblings.
+type node struct {
+	name     string
+	agg      types.Aggregations
+	parent   *node
+	children []*node
+}
+
+// build returns the sub-aggregations of the node.
+func (n *node) build() map[string]types.Aggregations {
+	if len(n.children) == 0 {
+		return nil
+	}
+	aggs := make(map[string]types.Aggregations, len(n.children))
+	for _, c := range n.children {
+		agg := c.agg
+		if sub := c.build(); sub != nil {
+			agg.Aggregations = sub
+		}
+		aggs[c.name] = agg
+	}
+	return aggs
+}
+
+// Handle represents an aggregation of the request, and returns its typed aggregate from the response.
+type Handle[T any] struct {
+	n       *node
+	convert func(types.Aggregate) (T, bool)
+}
+
+func (h *Handle[T]) node() *node { return h.n }
+
+// Name returns the name of the aggregation.
+func (h *Handle[T]) Name() string {
+	return h.n.name
+}
+
+// Result returns the aggregate from the search response.
+//
+// The aggregation must be at the top level, or nested in single bucket aggregations only;
+// use In with the buckets of multi-bucket aggregations.
+func (h *Handle[T]) Result(res *search.Response) (T, error) {
+	var path []*node
+	for n := h.n; n.parent != nil; n = n.parent {
+		path = append([]*node{n}, path...)
+	}
+
+	aggs := res.Aggregations
+	for _, n := range path[:len(path)-1] {
+		agg, ok := aggs[n.name]
+		if !ok {
+			var zero T
+			return zero, fmt.Errorf("aggs: aggregation [%s] not found", n.name)
+		}
+		sub, ok := singleBucket(agg)
+		if !ok {
+			var zero T
+			return zero, fmt.Errorf("aggs: aggregation [%s] is under the multi-bucket aggregation [%s], read it from the buckets with In", h.n.name, n.name)
+		}
+		aggs = sub
+	}
+	return h.In(aggs)
+}
+
+// In returns the aggregate from the aggregations of a bucket of the parent aggregation,
+// or from the aggregations of a response, eg. the response of a multi search.
+func (h *Handle[T]) In(aggs map[string]types.Aggregate) (T, error) {
+	var zero T
+
+	agg, ok := aggs[h.n.name]
+	if !ok {
+		return zero, fmt.Errorf("aggs: aggregation [%s] not found", h.n.name)
+	}
+	v, ok := h.convert(agg)
+	if !ok {
+		return zero, fmt.Errorf("aggs: unexpected aggregate type %T for aggregation [%s], expected %T", agg, h.n.name, zero)
+	}
+	return v, nil
+}
+
+// Add adds an aggregation to the parent, and returns a handle which asserts
+// the aggregate to T, eg. for aggregations without a dedicated function:
+//
+//	p := aggs.Add[*types.TDigestPercentilesAggregate](b, "load_time", types.Aggregations{
+//		Percentiles: &types.PercentilesAggregation{Field: some.String("load_time")},
+//	})
+//
+// Sub-aggregations of agg are replaced by the ones added to the handle.
+func Add[T any](p Parent, name string, agg types.Aggregations) *Handle[T] {
+	return add(p, name, agg, func(a types.Aggregate) (T, bool) {
+		v, ok := a.(T)
+		return v, ok
+	})
+}
+
+// add adds the aggregation to the parent with the conversion of its aggregate.
+func add[T any](p Parent, name string, agg types.Aggregations, convert func(types.Aggregate) (T, bool)) *Handle[T] {
+	parent := p.node()
+	n := &node{name: name, agg: agg, parent: parent}
+	for i, c := range parent.children {
+		if c.name == name {
+			parent.children = append(parent.children[:i], parent.children[i+1:]...)
+			break
+		}
+	}
+	parent.children = append(parent.children, n)
+	return &Handle[T]{n: n, convert: convert}
+}
+
+// singleBucket returns the sub-aggregations of single bucket aggregates.
+func singleBucket(agg types.Aggregate) (map[string]types.Aggregate, bool) {
+	switch a := agg.(type) {
+	case *types.FilterAggregate:
+		return a.Aggregations, true
+	case *types.NestedAggregate:
+		return a.Aggregations, true
+	case *types.ReverseNestedAggregate:
+		return a.Aggregations, true
+	case *types.GlobalAggregate:
+		return a.Aggregations, true
+	case *types.MissingAggregate:
+		return a.Aggregations, true
+	case *types.SamplerAggregate:
+		return a.Aggregations, true
+	case *types.UnmappedSamplerAggregate:
+		return a.Aggregations, true
+	case *types.ChildrenAggregate:
+		return a.Aggregations, true
+	case *types.ParentAggregate:
+		return a.Aggregations, true
+	}
+	return nil, false
+}
+
+// BucketAggregate represents the aggregate A of a multi-bucket aggregation, with its buckets of type B.
+type BucketAggregate[A any, B any] struct {
+	Aggregate A
+
+	// Buckets contains the buckets in the order of the response.
+	// Keyed buckets are ordered by key.
+	Buckets []B
+
+	// Keyed contains the buckets by key, when the response has keyed buckets,
+	// eg. for a filters aggregation with named filters.
+	Keyed map[string]B
+}
+
+// multiBucket adds a multi-bucket aggregation, whose aggregate A has the buckets of type B.
+func multiBucket[A any, B any](p Parent, name string, agg types.Aggregations, buckets func(A) interface{}) *Handle[*BucketAggregate[A, B]] {
+	return add(p, name, agg, func(v types.Aggregate) (*BucketAggregate[A, B], bool) {
+		a, ok := v.(A)
+		if !ok {
+			return nil, false
+		}
+		r := BucketAggregate[A, B]{Aggregate: a}
+		r.Buckets, r.Keyed = bucketList[B](buckets(a))
+		return &r, true
+	})
+}
+
+// bucketList returns the buckets of the union of a list and a map of buckets.
+func bucketList[B any](v interface{}) ([]B, map[string]B) {
+	switch b := v.(type) {
+	case []B:
+		return b, nil
+	case map[string]B:
+		keys := make([]string, 0, len(b))
+		for k := range b {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		list := make([]B, len(keys))
+		for i, k := range keys {
+			list[i] = b[k]
+		}
+		return list, b
+	}
+	return nil, nil
+}
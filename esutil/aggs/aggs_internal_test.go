@@ -0,0 +1,177 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package aggs
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/some"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/calendarinterval"
+)
+
+var testResponse = `{
+  "took": 1,
+  "timed_out": false,
+  "_shards": {"total": 1, "successful": 1, "skipped": 0, "failed": 0},
+  "hits": {"hits": []},
+  "aggregations": {
+    "sterms#by_tag": {
+      "doc_count_error_upper_bound": 0,
+      "sum_other_doc_count": 3,
+      "buckets": [
+        {"key": "go", "doc_count": 5, "avg#avg_price": {"value": 10.5}},
+        {"key": "rust", "doc_count": 2, "avg#avg_price": {"value": 20}}
+      ]
+    },
+    "lterms#by_year": {
+      "buckets": [{"key": 2024, "doc_count": 7}]
+    },
+    "filter#recent": {
+      "doc_count": 4,
+      "nested#comments": {
+        "doc_count": 9,
+        "value_count#count": {"value": 9}
+      }
+    },
+    "date_histogram#by_day": {
+      "buckets": [
+        {"key_as_string": "2024-01-01", "key": 1704067200000, "doc_count": 1},
+        {"key_as_string": "2024-01-02", "key": 1704153600000, "doc_count": 2}
+      ]
+    },
+    "filters#by_status": {
+      "buckets": {
+        "published": {"doc_count": 6},
+        "draft": {"doc_count": 1}
+      }
+    }
+  }
+}`
+
+func TestAggregations(t *testing.T) {
+	b := New()
+	byTag := Terms(b, "by_tag", types.TermsAggregation{Field: some.String("tags")})
+	avgPrice := Avg(byTag, "avg_price", types.AverageAggregation{Field: some.String("price")})
+	byYear := Terms(b, "by_year", types.TermsAggregation{Field: some.String("year")})
+	recent := Filter(b, "recent", &types.Query{Exists: &types.ExistsQuery{Field: "ts"}})
+	comments := Nested(recent, "comments", types.NestedAggregation{Path: some.String("comments")})
+	count := ValueCount(comments, "count", types.ValueCountAggregation{Field: some.String("comments.id")})
+	byDay := DateHistogram(b, "by_day", types.DateHistogramAggregation{Field: some.String("ts"), CalendarInterval: &calendarinterval.Day})
+	byStatus := Filters(b, "by_status", types.FiltersAggregation{})
+
+	t.Run("Request", func(t *testing.T) {
+		out, err := json.Marshal(b.Aggregations())
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		for _, s := range []string{
+			`"by_tag":{"aggregations":{"avg_price":{"avg":{"field":"price"}}},"terms":{"field":"tags"}}`,
+			`"recent":{"aggregations":{"comments":{"aggregations":{"count":{"value_count":{"field":"comments.id"}}},"nested":{"path":"comments"}}},"filter":{"exists":{"field":"ts"}}}`,
+		} {
+			if !strings.Contains(string(out), s) {
+				t.Errorf("Expected %s in request:\n%s", s, out)
+			}
+		}
+	})
+
+	var res search.Response
+	if err := json.Unmarshal([]byte(testResponse), &res); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	t.Run("Terms", func(t *testing.T) {
+		tags, err := byTag.Result(&res)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(tags.Buckets) != 2 || tags.Buckets[0].Key != "go" || tags.Buckets[0].DocCount != 5 || *tags.SumOtherDocCount != 3 {
+			t.Errorf("Unexpected result: %+v", tags)
+		}
+		avg, err := avgPrice.In(tags.Buckets[1].Aggregations)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if avg.Value != 20 {
+			t.Errorf("Unexpected average: %v", avg.Value)
+		}
+
+		years, err := byYear.Result(&res)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if years.Buckets[0].Key != int64(2024) || years.Buckets[0].KeyAsString != "2024" {
+			t.Errorf("Unexpected bucket: %+v", years.Buckets[0])
+		}
+	})
+
+	t.Run("Single bucket parents", func(t *testing.T) {
+		c, err := count.Result(&res)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if c.Value != 9 {
+			t.Errorf("Unexpected count: %v", c.Value)
+		}
+		n, err := comments.Result(&res)
+		if err != nil || n.DocCount != 9 {
+			t.Errorf("Unexpected result: %+v, %v", n, err)
+		}
+	})
+
+	t.Run("Multi-bucket parent", func(t *testing.T) {
+		if _, err := avgPrice.Result(&res); err == nil || !strings.Contains(err.Error(), "multi-bucket") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Buckets", func(t *testing.T) {
+		days, err := byDay.Result(&res)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(days.Buckets) != 2 || *days.Buckets[1].KeyAsString != "2024-01-02" || days.Keyed != nil {
+			t.Errorf("Unexpected result: %+v", days)
+		}
+
+		statuses, err := byStatus.Result(&res)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if statuses.Keyed["published"].DocCount != 6 || len(statuses.Buckets) != 2 || statuses.Buckets[0].DocCount != 1 {
+			t.Errorf("Unexpected result: %+v", statuses)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		missing := Sum(b, "missing", types.SumAggregation{})
+		if _, err := missing.Result(&res); err == nil {
+			t.Errorf("Expected error for missing aggregation")
+		}
+		wrong := Add[*types.SumAggregate](New(), "by_day", types.Aggregations{})
+		if _, err := wrong.Result(&res); err == nil || !strings.Contains(err.Error(), "unexpected aggregate type") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
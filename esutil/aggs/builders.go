@@ -0,0 +1,129 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package aggs
+
+import (
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// Avg adds an avg aggregation.
+func Avg(p Parent, name string, agg types.AverageAggregation) *Handle[*types.AvgAggregate] {
+	return Add[*types.AvgAggregate](p, name, types.Aggregations{Avg: &agg})
+}
+
+// Sum adds a sum aggregation.
+func Sum(p Parent, name string, agg types.SumAggregation) *Handle[*types.SumAggregate] {
+	return Add[*types.SumAggregate](p, name, types.Aggregations{Sum: &agg})
+}
+
+// Min adds a min aggregation.
+func Min(p Parent, name string, agg types.MinAggregation) *Handle[*types.MinAggregate] {
+	return Add[*types.MinAggregate](p, name, types.Aggregations{Min: &agg})
+}
+
+// Max adds a max aggregation.
+func Max(p Parent, name string, agg types.MaxAggregation) *Handle[*types.MaxAggregate] {
+	return Add[*types.MaxAggregate](p, name, types.Aggregations{Max: &agg})
+}
+
+// Cardinality adds a cardinality aggregation.
+func Cardinality(p Parent, name string, agg types.CardinalityAggregation) *Handle[*types.CardinalityAggregate] {
+	return Add[*types.CardinalityAggregate](p, name, types.Aggregations{Cardinality: &agg})
+}
+
+// ValueCount adds a value_count aggregation.
+func ValueCount(p Parent, name string, agg types.ValueCountAggregation) *Handle[*types.ValueCountAggregate] {
+	return Add[*types.ValueCountAggregate](p, name, types.Aggregations{ValueCount: &agg})
+}
+
+// Stats adds a stats aggregation.
+func Stats(p Parent, name string, agg types.StatsAggregation) *Handle[*types.StatsAggregate] {
+	return Add[*types.StatsAggregate](p, name, types.Aggregations{Stats: &agg})
+}
+
+// ExtendedStats adds an extended_stats aggregation.
+func ExtendedStats(p Parent, name string, agg types.ExtendedStatsAggregation) *Handle[*types.ExtendedStatsAggregate] {
+	return Add[*types.ExtendedStatsAggregate](p, name, types.Aggregations{ExtendedStats: &agg})
+}
+
+// TopHits adds a top_hits aggregation.
+func TopHits(p Parent, name string, agg types.TopHitsAggregation) *Handle[*types.TopHitsAggregate] {
+	return Add[*types.TopHitsAggregate](p, name, types.Aggregations{TopHits: &agg})
+}
+
+// Filter adds a filter aggregation, a single bucket of the documents matching the query.
+func Filter(p Parent, name string, query *types.Query) *Handle[*types.FilterAggregate] {
+	return Add[*types.FilterAggregate](p, name, types.Aggregations{Filter: query})
+}
+
+// Nested adds a nested aggregation, a single bucket of the nested objects at a path.
+func Nested(p Parent, name string, agg types.NestedAggregation) *Handle[*types.NestedAggregate] {
+	return Add[*types.NestedAggregate](p, name, types.Aggregations{Nested: &agg})
+}
+
+// ReverseNested adds a reverse_nested aggregation, from nested objects back to their parents.
+func ReverseNested(p Parent, name string, agg types.ReverseNestedAggregation) *Handle[*types.ReverseNestedAggregate] {
+	return Add[*types.ReverseNestedAggregate](p, name, types.Aggregations{ReverseNested: &agg})
+}
+
+// Global adds a global aggregation, a single bucket of all documents regardless of the query.
+func Global(p Parent, name string) *Handle[*types.GlobalAggregate] {
+	return Add[*types.GlobalAggregate](p, name, types.Aggregations{Global: &types.GlobalAggregation{}})
+}
+
+// Missing adds a missing aggregation, a single bucket of the documents without a value.
+func Missing(p Parent, name string, agg types.MissingAggregation) *Handle[*types.MissingAggregate] {
+	return Add[*types.MissingAggregate](p, name, types.Aggregations{Missing: &agg})
+}
+
+// DateHistogram adds a date_histogram aggregation.
+func DateHistogram(p Parent, name string, agg types.DateHistogramAggregation) *Handle[*BucketAggregate[*types.DateHistogramAggregate, types.DateHistogramBucket]] {
+	return multiBucket[*types.DateHistogramAggregate, types.DateHistogramBucket](p, name, types.Aggregations{DateHistogram: &agg},
+		func(a *types.DateHistogramAggregate) interface{} { return a.Buckets })
+}
+
+// Histogram adds a histogram aggregation.
+func Histogram(p Parent, name string, agg types.HistogramAggregation) *Handle[*BucketAggregate[*types.HistogramAggregate, types.HistogramBucket]] {
+	return multiBucket[*types.HistogramAggregate, types.HistogramBucket](p, name, types.Aggregations{Histogram: &agg},
+		func(a *types.HistogramAggregate) interface{} { return a.Buckets })
+}
+
+// Range adds a range aggregation.
+func Range(p Parent, name string, agg types.RangeAggregation) *Handle[*BucketAggregate[*types.RangeAggregate, types.RangeBucket]] {
+	return multiBucket[*types.RangeAggregate, types.RangeBucket](p, name, types.Aggregations{Range: &agg},
+		func(a *types.RangeAggregate) interface{} { return a.Buckets })
+}
+
+// DateRange adds a date_range aggregation.
+func DateRange(p Parent, name string, agg types.DateRangeAggregation) *Handle[*BucketAggregate[*types.DateRangeAggregate, types.RangeBucket]] {
+	return multiBucket[*types.DateRangeAggregate, types.RangeBucket](p, name, types.Aggregations{DateRange: &agg},
+		func(a *types.DateRangeAggregate) interface{} { return a.Buckets })
+}
+
+// Filters adds a filters aggregation. Named filters return keyed buckets.
+func Filters(p Parent, name string, agg types.FiltersAggregation) *Handle[*BucketAggregate[*types.FiltersAggregate, types.FiltersBucket]] {
+	return multiBucket[*types.FiltersAggregate, types.FiltersBucket](p, name, types.Aggregations{Filters: &agg},
+		func(a *types.FiltersAggregate) interface{} { return a.Buckets })
+}
+
+// Composite adds a composite aggregation. The after key of the aggregate
+// requests the next page, see the composite pager.
+func Composite(p Parent, name string, agg types.CompositeAggregation) *Handle[*BucketAggregate[*types.CompositeAggregate, types.CompositeBucket]] {
+	return multiBucket[*types.CompositeAggregate, types.CompositeBucket](p, name, types.Aggregations{Composite: &agg},
+		func(a *types.CompositeAggregate) interface{} { return a.Buckets })
+}
@@ -0,0 +1,91 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package aggs
+
+import (
+	"fmt"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// TermsResult represents the aggregate of a terms aggregation, whatever the type of the field.
+type TermsResult struct {
+	DocCountErrorUpperBound *int64
+	SumOtherDocCount        *int64
+	Buckets                 []TermsBucket
+}
+
+// TermsBucket represents a bucket of a terms aggregation.
+type TermsBucket struct {
+	Key           types.FieldValue // A string, an int64 or a float64, depending on the type of the field.
+	KeyAsString   string           // The key as a string, formatted for dates and numbers.
+	DocCount      int64
+	DocCountError *int64
+
+	Aggregations map[string]types.Aggregate
+}
+
+// Terms adds a terms aggregation.
+//
+// Elasticsearch returns a different aggregate by type of field; the handle
+// returns them all as a TermsResult, and an empty one for unmapped fields.
+func Terms(p Parent, name string, agg types.TermsAggregation) *Handle[*TermsResult] {
+	return add(p, name, types.Aggregations{Terms: &agg}, termsResult)
+}
+
+// termsResult converts the terms aggregates.
+func termsResult(agg types.Aggregate) (*TermsResult, bool) {
+	switch a := agg.(type) {
+	case *types.StringTermsAggregate:
+		r := TermsResult{DocCountErrorUpperBound: a.DocCountErrorUpperBound, SumOtherDocCount: a.SumOtherDocCount}
+		buckets, _ := bucketList[types.StringTermsBucket](a.Buckets)
+		for _, b := range buckets {
+			key, _ := b.Key.(string)
+			r.Buckets = append(r.Buckets, TermsBucket{Key: b.Key, KeyAsString: key, DocCount: b.DocCount, DocCountError: b.DocCountError, Aggregations: b.Aggregations})
+		}
+		return &r, true
+
+	case *types.LongTermsAggregate:
+		r := TermsResult{DocCountErrorUpperBound: a.DocCountErrorUpperBound, SumOtherDocCount: a.SumOtherDocCount}
+		buckets, _ := bucketList[types.LongTermsBucket](a.Buckets)
+		for _, b := range buckets {
+			r.Buckets = append(r.Buckets, TermsBucket{Key: b.Key, KeyAsString: keyAsString(b.KeyAsString, b.Key), DocCount: b.DocCount, DocCountError: b.DocCountError, Aggregations: b.Aggregations})
+		}
+		return &r, true
+
+	case *types.DoubleTermsAggregate:
+		r := TermsResult{DocCountErrorUpperBound: a.DocCountErrorUpperBound, SumOtherDocCount: a.SumOtherDocCount}
+		buckets, _ := bucketList[types.DoubleTermsBucket](a.Buckets)
+		for _, b := range buckets {
+			r.Buckets = append(r.Buckets, TermsBucket{Key: float64(b.Key), KeyAsString: keyAsString(b.KeyAsString, float64(b.Key)), DocCount: b.DocCount, DocCountError: b.DocCountError, Aggregations: b.Aggregations})
+		}
+		return &r, true
+
+	case *types.UnmappedTermsAggregate:
+		return &TermsResult{DocCountErrorUpperBound: a.DocCountErrorUpperBound, SumOtherDocCount: a.SumOtherDocCount}, true
+	}
+	return nil, false
+}
+
+// keyAsString returns the formatted key, or the key when there is none.
+func keyAsString(s *string, key interface{}) string {
+	if s != nil {
+		return *s
+	}
+	return fmt.Sprint(key)
+}
@@ -0,0 +1,473 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// The steps of the alias swap, in the order they run.
+const (
+	AliasSwapStepCreateIndex     = "create_index"
+	AliasSwapStepDisableRefresh  = "disable_refresh"
+	AliasSwapStepReindex         = "reindex"
+	AliasSwapStepRestoreSettings = "restore_settings"
+	AliasSwapStepRefresh         = "refresh"
+	AliasSwapStepValidate        = "validate"
+	AliasSwapStepSwapAlias       = "swap_alias"
+	AliasSwapStepDeleteOld       = "delete_old"
+)
+
+// AliasSwapConfig represents configuration of the alias swap.
+type AliasSwapConfig struct {
+	Client *elasticsearch.Client // The client. Required.
+
+	// Alias is the alias to move to the new index. Required.
+	//
+	// It can also be the name of a concrete index, which is then deleted
+	// and replaced with the alias in the same atomic update.
+	Alias string
+
+	// NewIndex is the name of the new index. Defaults to the alias
+	// with the UTC time as suffix, eg. "products-20240102150405".
+	NewIndex string
+
+	// IndexBody is encoded as the body of the create index request,
+	// eg. the settings and the new mappings.
+	IndexBody interface{}
+
+	Query  interface{} // Encoded as the query of the source documents. Defaults to match_all.
+	Script interface{} // Encoded as the script of the reindex request, eg. a *types.Script.
+
+	Slices            interface{} // The number of reindex slices. Defaults to "auto".
+	RequestsPerSecond *int        // The reindex throttle. Defaults to unlimited.
+
+	// Task configures the watcher of the reindex task, eg. to report progress.
+	Task TaskWatcherConfig
+
+	// Validate is called with the document counts before the alias is swapped,
+	// and aborts the swap when it returns an error. By default, the swap is
+	// aborted when the counts differ.
+	Validate func(context.Context, AliasSwapCounts) error
+
+	DeleteOld bool // Delete the old indices after the swap.
+	DryRun    bool // Only resolve the plan, without making any change.
+
+	OnStep func(context.Context, AliasSwapStep) // Called before every step.
+}
+
+// AliasSwapStep represents a single step of the alias swap.
+type AliasSwapStep struct {
+	Name        string // One of the AliasSwapStep constants
+	Description string
+}
+
+// AliasSwapPlan represents the steps of the alias swap.
+type AliasSwapPlan []AliasSwapStep
+
+// String returns the plan as a numbered list of steps.
+func (p AliasSwapPlan) String() string {
+	var b strings.Builder
+	for i, step := range p {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, step.Description)
+	}
+	return b.String()
+}
+
+// AliasSwapCounts represents the number of documents in the old and new indices.
+type AliasSwapCounts struct {
+	Source int64 // The number of documents matching the query in the old indices
+	Target int64 // The number of documents in the new index
+}
+
+// AliasSwapReport represents the outcome of the alias swap.
+type AliasSwapReport struct {
+	Plan       AliasSwapPlan
+	NewIndex   string
+	OldIndices []string
+	Completed  []string // The names of the completed steps
+
+	Counts     AliasSwapCounts
+	Task       *TaskResult // The result of the reindex task
+	RolledBack bool        // Whether the new index was deleted after a failure
+}
+
+// ReindexWithAliasSwap reindexes the indices behind an alias into a new index,
+// and atomically moves the alias to it, so that readers and writers through the
+// alias never see a partial index.
+//
+// Refresh and replicas are disabled on the new index during the reindex,
+// and restored to their values after its creation. When any step before the
+// swap fails, the new index is deleted.
+//
+// Writes to the old indices during the reindex are not copied: they should be
+// paused, or replayed after the swap.
+func ReindexWithAliasSwap(ctx context.Context, cfg AliasSwapConfig) (AliasSwapReport, error) {
+	if cfg.Client == nil {
+		return AliasSwapReport{}, errors.New("alias swap: client is required")
+	}
+	if cfg.Alias == "" {
+		return AliasSwapReport{}, errors.New("alias swap: alias is required")
+	}
+	if cfg.NewIndex == "" {
+		cfg.NewIndex = cfg.Alias + "-" + time.Now().UTC().Format("20060102150405")
+	}
+	if cfg.Slices == nil {
+		cfg.Slices = "auto"
+	}
+	if cfg.Validate == nil {
+		cfg.Validate = func(ctx context.Context, c AliasSwapCounts) error {
+			if c.Source != c.Target {
+				return fmt.Errorf("document count mismatch: source=%d, target=%d", c.Source, c.Target)
+			}
+			return nil
+		}
+	}
+
+	s := aliasSwap{cfg: cfg}
+	s.report.NewIndex = cfg.NewIndex
+
+	if err := s.resolve(ctx); err != nil {
+		return s.report, fmt.Errorf("alias swap: %s", err)
+	}
+	s.report.Plan = s.plan()
+
+	if cfg.DryRun {
+		return s.report, nil
+	}
+	return s.report, s.run(ctx)
+}
+
+// aliasSwap represents a single alias swap.
+type aliasSwap struct {
+	cfg    AliasSwapConfig
+	report AliasSwapReport
+
+	concrete bool              // Whether the alias is a concrete index
+	restore  map[string]string // The settings to restore, nil values are reset
+}
+
+// resolve finds the indices behind the alias.
+func (s *aliasSwap) resolve(ctx context.Context) error {
+	var aliases map[string]json.RawMessage
+	status, err := s.perform(ctx, esapi.IndicesGetAliasRequest{Name: []string{s.cfg.Alias}}, &aliases)
+	switch {
+	case status == http.StatusNotFound:
+		status, err := s.perform(ctx, esapi.IndicesExistsRequest{Index: []string{s.cfg.Alias}}, nil)
+		if status == http.StatusNotFound {
+			return fmt.Errorf("alias [%s] not found", s.cfg.Alias)
+		}
+		if err != nil {
+			return fmt.Errorf("get index: %s", err)
+		}
+		s.concrete = true
+		s.report.OldIndices = []string{s.cfg.Alias}
+	case err != nil:
+		return fmt.Errorf("get alias: %s", err)
+	default:
+		for index := range aliases {
+			s.report.OldIndices = append(s.report.OldIndices, index)
+		}
+		sort.Strings(s.report.OldIndices)
+	}
+
+	for _, index := range s.report.OldIndices {
+		if index == s.cfg.NewIndex {
+			return fmt.Errorf("new index [%s] is already behind the alias", index)
+		}
+	}
+	return nil
+}
+
+// plan returns the steps of the alias swap.
+func (s *aliasSwap) plan() AliasSwapPlan {
+	var (
+		alias = s.cfg.Alias
+		old   = strings.Join(s.report.OldIndices, ", ")
+		idx   = s.cfg.NewIndex
+	)
+
+	plan := AliasSwapPlan{
+		{AliasSwapStepCreateIndex, fmt.Sprintf("create index [%s]", idx)},
+		{AliasSwapStepDisableRefresh, fmt.Sprintf("disable refresh and replicas of [%s]", idx)},
+		{AliasSwapStepReindex, fmt.Sprintf("reindex [%s] into [%s]", old, idx)},
+		{AliasSwapStepRestoreSettings, fmt.Sprintf("restore refresh and replicas of [%s]", idx)},
+		{AliasSwapStepRefresh, fmt.Sprintf("refresh [%s]", idx)},
+		{AliasSwapStepValidate, fmt.Sprintf("validate the document counts of [%s] and [%s]", old, idx)},
+	}
+	if s.concrete {
+		plan = append(plan, AliasSwapStep{AliasSwapStepSwapAlias, fmt.Sprintf("replace index [%s] with an alias to [%s]", alias, idx)})
+	} else {
+		plan = append(plan, AliasSwapStep{AliasSwapStepSwapAlias, fmt.Sprintf("move alias [%s] from [%s] to [%s]", alias, old, idx)})
+		if s.cfg.DeleteOld {
+			plan = append(plan, AliasSwapStep{AliasSwapStepDeleteOld, fmt.Sprintf("delete [%s]", old)})
+		}
+	}
+	return plan
+}
+
+// run executes the plan, and rolls back when a step before the swap fails.
+func (s *aliasSwap) run(ctx context.Context) error {
+	steps := map[string]func(context.Context) error{
+		AliasSwapStepCreateIndex:     s.createIndex,
+		AliasSwapStepDisableRefresh:  s.disableRefresh,
+		AliasSwapStepReindex:         s.reindex,
+		AliasSwapStepRestoreSettings: s.restoreSettings,
+		AliasSwapStepRefresh:         s.refresh,
+		AliasSwapStepValidate:        s.validate,
+		AliasSwapStepSwapAlias:       s.swapAlias,
+		AliasSwapStepDeleteOld:       s.deleteOld,
+	}
+
+	for _, step := range s.report.Plan {
+		if s.cfg.OnStep != nil {
+			s.cfg.OnStep(ctx, step)
+		}
+
+		err := ctx.Err()
+		if err == nil {
+			err = steps[step.Name](ctx)
+		}
+		if err != nil {
+			err = fmt.Errorf("alias swap: %s: %w", step.Name, err)
+			if s.swapped() {
+				return err
+			}
+			return s.rollback(ctx, err)
+		}
+		s.report.Completed = append(s.report.Completed, step.Name)
+	}
+	return nil
+}
+
+// swapped returns true when the alias has been moved to the new index.
+func (s *aliasSwap) swapped() bool {
+	for _, name := range s.report.Completed {
+		if name == AliasSwapStepSwapAlias {
+			return true
+		}
+	}
+	return false
+}
+
+// rollback deletes the new index, when it has been created.
+func (s *aliasSwap) rollback(ctx context.Context, err error) error {
+	if len(s.report.Completed) == 0 {
+		return err
+	}
+
+	// Use a separate context, since the provided one may be done.
+	ctx = context.WithoutCancel(ctx)
+	if _, rerr := s.perform(ctx, esapi.IndicesDeleteRequest{Index: []string{s.cfg.NewIndex}}, nil); rerr != nil {
+		return errors.Join(err, fmt.Errorf("alias swap: rollback: %s", rerr))
+	}
+	s.report.RolledBack = true
+	return err
+}
+
+func (s *aliasSwap) createIndex(ctx context.Context) error {
+	req := esapi.IndicesCreateRequest{Index: s.cfg.NewIndex}
+	if s.cfg.IndexBody != nil {
+		body, err := json.Marshal(s.cfg.IndexBody)
+		if err != nil {
+			return fmt.Errorf("error encoding request body: %s", err)
+		}
+		req.Body = bytes.NewReader(body)
+	}
+	_, err := s.perform(ctx, req, nil)
+	return err
+}
+
+// disableRefresh records the refresh and replicas settings of the new index,
+// which includes the values from templates, and disables them.
+func (s *aliasSwap) disableRefresh(ctx context.Context) error {
+	var res map[string]struct {
+		Settings map[string]interface{} `json:"settings"`
+	}
+	flat := true
+	req := esapi.IndicesGetSettingsRequest{
+		Index:        []string{s.cfg.NewIndex},
+		Name:         []string{"index.refresh_interval", "index.number_of_replicas"},
+		FlatSettings: &flat,
+	}
+	if _, err := s.perform(ctx, req, &res); err != nil {
+		return err
+	}
+
+	s.restore = map[string]string{"index.refresh_interval": "", "index.number_of_replicas": ""}
+	for name := range s.restore {
+		if v, ok := res[s.cfg.NewIndex].Settings[name].(string); ok {
+			s.restore[name] = v
+		}
+	}
+
+	return s.putSettings(ctx, map[string]interface{}{
+		"index.refresh_interval":   "-1",
+		"index.number_of_replicas": 0,
+	})
+}
+
+func (s *aliasSwap) reindex(ctx context.Context) error {
+	source := map[string]interface{}{"index": s.report.OldIndices}
+	if s.cfg.Query != nil {
+		source["query"] = s.cfg.Query
+	}
+	body := map[string]interface{}{
+		"source": source,
+		"dest":   map[string]interface{}{"index": s.cfg.NewIndex},
+	}
+	if s.cfg.Script != nil {
+		body["script"] = s.cfg.Script
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("error encoding request body: %s", err)
+	}
+
+	var res struct {
+		Task string `json:"task"`
+	}
+	wait := false
+	req := esapi.ReindexRequest{
+		Body:              bytes.NewReader(b),
+		Slices:            s.cfg.Slices,
+		RequestsPerSecond: s.cfg.RequestsPerSecond,
+		WaitForCompletion: &wait,
+	}
+	if _, err := s.perform(ctx, req, &res); err != nil {
+		return err
+	}
+
+	result, err := NewTaskWatcher(s.cfg.Client, res.Task, s.cfg.Task).Wait(ctx)
+	s.report.Task = result
+	return err
+}
+
+func (s *aliasSwap) restoreSettings(ctx context.Context) error {
+	settings := make(map[string]interface{}, len(s.restore))
+	for name, v := range s.restore {
+		if v == "" {
+			settings[name] = nil // Reset to the default
+		} else {
+			settings[name] = v
+		}
+	}
+	return s.putSettings(ctx, settings)
+}
+
+func (s *aliasSwap) refresh(ctx context.Context) error {
+	_, err := s.perform(ctx, esapi.IndicesRefreshRequest{Index: []string{s.cfg.NewIndex}}, nil)
+	return err
+}
+
+func (s *aliasSwap) validate(ctx context.Context) error {
+	source, err := s.count(ctx, s.report.OldIndices, s.cfg.Query)
+	if err != nil {
+		return err
+	}
+	target, err := s.count(ctx, []string{s.cfg.NewIndex}, nil)
+	if err != nil {
+		return err
+	}
+
+	s.report.Counts = AliasSwapCounts{Source: source, Target: target}
+	return s.cfg.Validate(ctx, s.report.Counts)
+}
+
+func (s *aliasSwap) swapAlias(ctx context.Context) error {
+	var actions []map[string]interface{}
+	if s.concrete {
+		actions = append(actions, map[string]interface{}{"remove_index": map[string]interface{}{"index": s.cfg.Alias}})
+	} else {
+		actions = append(actions, map[string]interface{}{"remove": map[string]interface{}{"indices": s.report.OldIndices, "alias": s.cfg.Alias}})
+	}
+	actions = append(actions, map[string]interface{}{"add": map[string]interface{}{"index": s.cfg.NewIndex, "alias": s.cfg.Alias}})
+
+	body, err := json.Marshal(map[string]interface{}{"actions": actions})
+	if err != nil {
+		return fmt.Errorf("error encoding request body: %s", err)
+	}
+	_, err = s.perform(ctx, esapi.IndicesUpdateAliasesRequest{Body: bytes.NewReader(body)}, nil)
+	return err
+}
+
+func (s *aliasSwap) deleteOld(ctx context.Context) error {
+	_, err := s.perform(ctx, esapi.IndicesDeleteRequest{Index: s.report.OldIndices}, nil)
+	return err
+}
+
+// putSettings updates the settings of the new index.
+func (s *aliasSwap) putSettings(ctx context.Context, settings map[string]interface{}) error {
+	body, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("error encoding request body: %s", err)
+	}
+	_, err = s.perform(ctx, esapi.IndicesPutSettingsRequest{Index: []string{s.cfg.NewIndex}, Body: bytes.NewReader(body)}, nil)
+	return err
+}
+
+// count returns the number of documents matching the query in the indices.
+func (s *aliasSwap) count(ctx context.Context, indices []string, query interface{}) (int64, error) {
+	req := esapi.CountRequest{Index: indices}
+	if query != nil {
+		body, err := json.Marshal(map[string]interface{}{"query": query})
+		if err != nil {
+			return 0, fmt.Errorf("error encoding request body: %s", err)
+		}
+		req.Body = bytes.NewReader(body)
+	}
+
+	var res struct {
+		Count int64 `json:"count"`
+	}
+	if _, err := s.perform(ctx, req, &res); err != nil {
+		return 0, err
+	}
+	return res.Count, nil
+}
+
+// perform executes the request and decodes the response body into out, when not nil.
+// It returns the response status, and an error for the error responses.
+func (s *aliasSwap) perform(ctx context.Context, req esapi.Request, out interface{}) (int, error) {
+	res, err := req.Do(ctx, s.cfg.Client)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return res.StatusCode, errors.New(res.String())
+	}
+	if out != nil {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			return res.StatusCode, fmt.Errorf("error parsing response body: %s", err)
+		}
+	}
+	return res.StatusCode, nil
+}
@@ -0,0 +1,262 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// mockAliasCluster serves the requests of the alias swap, recording them in calls.
+type mockAliasCluster struct {
+	mu sync.Mutex
+
+	aliasIndices []string // The indices behind the alias, or none
+	concrete     bool     // Whether the alias is a concrete index
+	sourceCount  int
+	targetCount  int
+	taskError    string
+
+	calls  []string
+	bodies map[string]string
+}
+
+func (m *mockAliasCluster) handle(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	call := req.Method + " " + req.URL.Path
+	m.calls = append(m.calls, call)
+	if req.Body != nil {
+		body, _ := ioutil.ReadAll(req.Body)
+		if m.bodies == nil {
+			m.bodies = make(map[string]string)
+		}
+		m.bodies[call] = string(body)
+	}
+
+	switch {
+	case req.URL.Path == "/_alias/products":
+		if len(m.aliasIndices) == 0 {
+			return mockResponse(404, `{"error":"alias [products] missing","status":404}`)
+		}
+		var indices []string
+		for _, index := range m.aliasIndices {
+			indices = append(indices, fmt.Sprintf(`%q:{"aliases":{"products":{}}}`, index))
+		}
+		return mockResponse(200, "{"+strings.Join(indices, ",")+"}")
+
+	case req.Method == http.MethodHead && req.URL.Path == "/products":
+		if m.concrete {
+			return mockResponse(200, ``)
+		}
+		return mockResponse(404, ``)
+
+	case strings.Contains(req.URL.Path, "/_settings/") && req.Method == http.MethodGet:
+		return mockResponse(200, `{"products-v2":{"settings":{"index.number_of_replicas":"2"}}}`)
+
+	case req.URL.Path == "/_reindex":
+		return mockResponse(200, `{"task":"node:1"}`)
+
+	case req.URL.Path == "/_tasks/node:1":
+		result := `"response":{"total":3,"created":3,"failures":[]}`
+		if m.taskError != "" {
+			result = `"error":` + m.taskError
+		}
+		return mockResponse(200, `{"completed":true,"task":{"node":"node","id":1,"type":"transport","action":"indices:data/write/reindex",`+
+			`"cancellable":true,"start_time_in_millis":0,"running_time_in_nanos":0},`+result+`}`)
+
+	case strings.HasSuffix(req.URL.Path, "/_count"):
+		if strings.HasPrefix(req.URL.Path, "/products-v2/") {
+			return mockResponse(200, fmt.Sprintf(`{"count":%d}`, m.targetCount))
+		}
+		return mockResponse(200, fmt.Sprintf(`{"count":%d}`, m.sourceCount))
+	}
+
+	return mockResponse(200, `{"acknowledged":true}`)
+}
+
+func TestReindexWithAliasSwap(t *testing.T) {
+	t.Run("Swap", func(t *testing.T) {
+		cluster := &mockAliasCluster{aliasIndices: []string{"products-v1"}, sourceCount: 3, targetCount: 3}
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+
+		var steps []string
+		report, err := ReindexWithAliasSwap(context.Background(), AliasSwapConfig{
+			Client:    es,
+			Alias:     "products",
+			NewIndex:  "products-v2",
+			IndexBody: map[string]interface{}{"mappings": map[string]interface{}{"dynamic": "strict"}},
+			Query:     map[string]interface{}{"term": map[string]interface{}{"active": true}},
+			DeleteOld: true,
+			Task:      TaskWatcherConfig{MinInterval: time.Millisecond},
+			OnStep: func(ctx context.Context, step AliasSwapStep) {
+				steps = append(steps, step.Name)
+			},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		expected := []string{
+			AliasSwapStepCreateIndex, AliasSwapStepDisableRefresh, AliasSwapStepReindex, AliasSwapStepRestoreSettings,
+			AliasSwapStepRefresh, AliasSwapStepValidate, AliasSwapStepSwapAlias, AliasSwapStepDeleteOld,
+		}
+		if fmt.Sprint(steps) != fmt.Sprint(expected) || fmt.Sprint(report.Completed) != fmt.Sprint(expected) {
+			t.Errorf("Unexpected steps: %v, completed: %v", steps, report.Completed)
+		}
+		if report.Task == nil || report.Counts.Target != 3 || report.RolledBack {
+			t.Errorf("Unexpected report: %+v", report)
+		}
+
+		for call, body := range map[string]string{
+			"PUT /products-v2":         `{"mappings":{"dynamic":"strict"}}`,
+			"POST /_reindex":           `{"dest":{"index":"products-v2"},"source":{"index":["products-v1"],"query":{"term":{"active":true}}}}`,
+			"POST /_aliases":           `{"actions":[{"remove":{"alias":"products","indices":["products-v1"]}},{"add":{"alias":"products","index":"products-v2"}}]}`,
+			"POST /products-v1/_count": `{"query":{"term":{"active":true}}}`,
+		} {
+			if cluster.bodies[call] != body {
+				t.Errorf("Unexpected body for %s: %s", call, cluster.bodies[call])
+			}
+		}
+
+		var settings []string
+		for _, call := range cluster.calls {
+			if call == "PUT /products-v2/_settings" {
+				settings = append(settings, call)
+			}
+		}
+		if len(settings) != 2 || cluster.bodies["PUT /products-v2/_settings"] != `{"index.number_of_replicas":"2","index.refresh_interval":null}` {
+			t.Errorf("Unexpected settings: %v, %s", settings, cluster.bodies["PUT /products-v2/_settings"])
+		}
+		if last := cluster.calls[len(cluster.calls)-1]; last != "DELETE /products-v1" {
+			t.Errorf("Expected the old index to be deleted, got: %s", last)
+		}
+	})
+
+	t.Run("Concrete index", func(t *testing.T) {
+		cluster := &mockAliasCluster{concrete: true, sourceCount: 3, targetCount: 3}
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+
+		report, err := ReindexWithAliasSwap(context.Background(), AliasSwapConfig{
+			Client:    es,
+			Alias:     "products",
+			NewIndex:  "products-v2",
+			DeleteOld: true,
+			Task:      TaskWatcherConfig{MinInterval: time.Millisecond},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if report.Plan[len(report.Plan)-1].Name != AliasSwapStepSwapAlias {
+			t.Errorf("Unexpected plan:\n%s", report.Plan)
+		}
+		if body := cluster.bodies["POST /_aliases"]; body != `{"actions":[{"remove_index":{"index":"products"}},{"add":{"alias":"products","index":"products-v2"}}]}` {
+			t.Errorf("Unexpected body: %s", body)
+		}
+	})
+
+	t.Run("Dry run", func(t *testing.T) {
+		cluster := &mockAliasCluster{aliasIndices: []string{"products-v1"}}
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+
+		report, err := ReindexWithAliasSwap(context.Background(), AliasSwapConfig{
+			Client:   es,
+			Alias:    "products",
+			NewIndex: "products-v2",
+			DryRun:   true,
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if len(cluster.calls) != 1 {
+			t.Errorf("Unexpected calls: %v", cluster.calls)
+		}
+		if plan := report.Plan.String(); !strings.HasPrefix(plan, "1. create index [products-v2]\n") ||
+			!strings.Contains(plan, "7. move alias [products] from [products-v1] to [products-v2]\n") {
+			t.Errorf("Unexpected plan:\n%s", plan)
+		}
+	})
+
+	t.Run("Validation failure rolls back", func(t *testing.T) {
+		cluster := &mockAliasCluster{aliasIndices: []string{"products-v1"}, sourceCount: 3, targetCount: 2}
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+
+		report, err := ReindexWithAliasSwap(context.Background(), AliasSwapConfig{
+			Client:   es,
+			Alias:    "products",
+			NewIndex: "products-v2",
+			Task:     TaskWatcherConfig{MinInterval: time.Millisecond},
+		})
+		if err == nil || !strings.Contains(err.Error(), "validate: document count mismatch: source=3, target=2") {
+			t.Fatalf("Expected validation error, got: %v", err)
+		}
+
+		if !report.RolledBack || cluster.calls[len(cluster.calls)-1] != "DELETE /products-v2" {
+			t.Errorf("Expected the new index to be deleted, got: %v", cluster.calls)
+		}
+		for _, call := range cluster.calls {
+			if call == "POST /_aliases" {
+				t.Errorf("Unexpected alias update")
+			}
+		}
+	})
+
+	t.Run("Task failure rolls back", func(t *testing.T) {
+		cluster := &mockAliasCluster{aliasIndices: []string{"products-v1"}, taskError: `{"type":"illegal_argument_exception","reason":"bad script"}`}
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+
+		report, err := ReindexWithAliasSwap(context.Background(), AliasSwapConfig{
+			Client:   es,
+			Alias:    "products",
+			NewIndex: "products-v2",
+			Task:     TaskWatcherConfig{MinInterval: time.Millisecond},
+		})
+
+		var taskErr *TaskError
+		if !errors.As(err, &taskErr) {
+			t.Fatalf("Expected TaskError, got: %v", err)
+		}
+		if !report.RolledBack || fmt.Sprint(report.Completed) != "[create_index disable_refresh]" {
+			t.Errorf("Unexpected report: %+v", report)
+		}
+	})
+
+	t.Run("Missing alias", func(t *testing.T) {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: (&mockAliasCluster{}).handle}})
+
+		_, err := ReindexWithAliasSwap(context.Background(), AliasSwapConfig{Client: es, Alias: "products"})
+		if err == nil || !strings.Contains(err.Error(), "alias [products] not found") {
+			t.Errorf("Expected missing alias error, got: %v", err)
+		}
+	})
+}
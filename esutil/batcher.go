@@ -0,0 +1,300 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/elastic/elastic-transport-go/v8/elastictransport"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/mget"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/msearch"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// BatcherConfig represents configuration of the get and search batchers.
+type BatcherConfig struct {
+	MaxBatch int           // The maximum number of items in a batch. Defaults to 100.
+	Wait     time.Duration // The time to wait for more items before a batch is sent. Defaults to 2ms.
+
+	OnBatch func(context.Context, int) // Called with the number of items before a batch is sent.
+}
+
+// GetBatcher collects concurrent single document gets into Multi Get API calls,
+// in the manner of a data loader: the gets of a batch wait until the batch is full,
+// or until the wait time has passed since the first get.
+//
+// Identical gets in the same batch are sent once.
+type GetBatcher struct {
+	client elastictransport.Interface
+	b      *batcher[types.MgetOperation, *types.GetResult]
+}
+
+// NewGetBatcher creates a get batcher.
+//
+// The client is a *elasticsearch.TypedClient or a *elasticsearch.Client.
+func NewGetBatcher(client elastictransport.Interface, cfg BatcherConfig) *GetBatcher {
+	g := GetBatcher{client: client}
+	g.b = newBatcher(cfg, g.dispatch)
+	return &g
+}
+
+// Get returns the document from the index. A missing document is returned
+// with Found set to false, like with the Get API.
+func (g *GetBatcher) Get(ctx context.Context, index, id string) (*types.GetResult, error) {
+	return g.GetOperation(ctx, types.MgetOperation{Index_: &index, Id_: id})
+}
+
+// GetOperation returns the document of the operation, eg. with routing or source filtering.
+// The index of the operation is required.
+//
+// Errors of the document are returned as *types.ElasticsearchError, without a status.
+func (g *GetBatcher) GetOperation(ctx context.Context, op types.MgetOperation) (*types.GetResult, error) {
+	if op.Index_ == nil || *op.Index_ == "" {
+		return nil, errors.New("get batcher: index is required")
+	}
+	return g.b.do(ctx, op)
+}
+
+// dispatch sends the operations with a single Multi Get API call.
+func (g *GetBatcher) dispatch(ctx context.Context, ops []types.MgetOperation) ([]*types.GetResult, []error, error) {
+	var (
+		unique  []types.MgetOperation
+		keys    = make(map[string]int)
+		indexes = make([]int, len(ops))
+	)
+	for i, op := range ops {
+		key, err := getKey(op)
+		n, ok := keys[key]
+		if err != nil || !ok {
+			n = len(unique)
+			if err == nil {
+				keys[key] = n
+			}
+			unique = append(unique, op)
+		}
+		indexes[i] = n
+	}
+
+	res, err := mget.NewMgetFunc(g.client)().Request(&mget.Request{Docs: unique}).Do(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get batcher: %w", err)
+	}
+	if len(res.Docs) != len(unique) {
+		return nil, nil, fmt.Errorf("get batcher: unexpected number of documents: %d, expected %d", len(res.Docs), len(unique))
+	}
+
+	var (
+		results = make([]*types.GetResult, len(ops))
+		errs    = make([]error, len(ops))
+	)
+	for i, n := range indexes {
+		switch doc := res.Docs[n].(type) {
+		case *types.GetResult:
+			results[i] = doc
+		case *types.MultiGetError:
+			errs[i] = &types.ElasticsearchError{ErrorCause: doc.Error}
+		default:
+			errs[i] = fmt.Errorf("get batcher: unexpected document %T", doc)
+		}
+	}
+	return results, errs, nil
+}
+
+// getKey returns the identity of the operation, to send identical operations once.
+//
+// The key is the operation encoded as JSON, so that it covers all the fields
+// by value; an operation which can't be encoded is sent as is.
+func getKey(op types.MgetOperation) (string, error) {
+	b, err := json.Marshal(op)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// SearchBatcher collects concurrent searches into Multi Search API calls,
+// in the manner of a data loader, like GetBatcher.
+type SearchBatcher struct {
+	client elastictransport.Interface
+	b      *batcher[searchItem, *types.MultiSearchItem]
+}
+
+// searchItem represents a single search of a batch.
+type searchItem struct {
+	header types.MultisearchHeader
+	body   types.MultisearchBody
+}
+
+// NewSearchBatcher creates a search batcher.
+//
+// The client is a *elasticsearch.TypedClient or a *elasticsearch.Client.
+func NewSearchBatcher(client elastictransport.Interface, cfg BatcherConfig) *SearchBatcher {
+	s := SearchBatcher{client: client}
+	s.b = newBatcher(cfg, s.dispatch)
+	return &s
+}
+
+// Search runs the search, with the header as the target and options,
+// and returns its response.
+//
+// Errors of the search are returned as *types.ElasticsearchError.
+func (s *SearchBatcher) Search(ctx context.Context, header types.MultisearchHeader, body types.MultisearchBody) (*types.MultiSearchItem, error) {
+	return s.b.do(ctx, searchItem{header: header, body: body})
+}
+
+// dispatch sends the searches with a single Multi Search API call.
+func (s *SearchBatcher) dispatch(ctx context.Context, items []searchItem) ([]*types.MultiSearchItem, []error, error) {
+	req := make(msearch.Request, 0, len(items)*2)
+	for _, item := range items {
+		req = append(req, item.header, item.body)
+	}
+
+	res, err := msearch.NewMsearchFunc(s.client)().Request(&req).Do(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("search batcher: %w", err)
+	}
+	if len(res.Responses) != len(items) {
+		return nil, nil, fmt.Errorf("search batcher: unexpected number of responses: %d, expected %d", len(res.Responses), len(items))
+	}
+
+	var (
+		results = make([]*types.MultiSearchItem, len(items))
+		errs    = make([]error, len(items))
+	)
+	for i, r := range res.Responses {
+		switch r := r.(type) {
+		case *types.MultiSearchItem:
+			results[i] = r
+		case *types.ErrorResponseBase:
+			errs[i] = &types.ElasticsearchError{ErrorCause: r.Error, Status: r.Status}
+		default:
+			errs[i] = fmt.Errorf("search batcher: unexpected response %T", r)
+		}
+	}
+	return results, errs, nil
+}
+
+// batcher collects the calls of concurrent callers into batches.
+type batcher[K any, V any] struct {
+	cfg      BatcherConfig
+	dispatch func(context.Context, []K) ([]V, []error, error)
+
+	mu      sync.Mutex
+	pending []*batchCall[K, V]
+	timer   *time.Timer
+}
+
+// batchCall represents a single call waiting for its batch.
+type batchCall[K any, V any] struct {
+	ctx   context.Context
+	key   K
+	value V
+	err   error
+	done  chan struct{}
+}
+
+func newBatcher[K any, V any](cfg BatcherConfig, dispatch func(context.Context, []K) ([]V, []error, error)) *batcher[K, V] {
+	if cfg.MaxBatch <= 0 {
+		cfg.MaxBatch = 100
+	}
+	if cfg.Wait <= 0 {
+		cfg.Wait = 2 * time.Millisecond
+	}
+	return &batcher[K, V]{cfg: cfg, dispatch: dispatch}
+}
+
+// do adds the call to the pending batch, and waits for its result.
+func (b *batcher[K, V]) do(ctx context.Context, key K) (V, error) {
+	call := batchCall[K, V]{ctx: ctx, key: key, done: make(chan struct{})}
+
+	b.mu.Lock()
+	b.pending = append(b.pending, &call)
+	if len(b.pending) >= b.cfg.MaxBatch {
+		batch := b.take()
+		b.mu.Unlock()
+		go b.send(batch)
+	} else {
+		if b.timer == nil {
+			b.timer = time.AfterFunc(b.cfg.Wait, b.expire)
+		}
+		b.mu.Unlock()
+	}
+
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// expire sends the pending batch when the wait time has passed.
+func (b *batcher[K, V]) expire() {
+	b.mu.Lock()
+	batch := b.take()
+	b.mu.Unlock()
+
+	if len(batch) > 0 {
+		b.send(batch)
+	}
+}
+
+// take returns the pending batch, and resets it. The lock must be held.
+func (b *batcher[K, V]) take() []*batchCall[K, V] {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	batch := b.pending
+	b.pending = nil
+	return batch
+}
+
+// send dispatches the batch, and fans the results out to the callers.
+//
+// The request uses the context of the first call, without its cancellation,
+// since the other calls of the batch depend on it.
+func (b *batcher[K, V]) send(batch []*batchCall[K, V]) {
+	ctx := context.WithoutCancel(batch[0].ctx)
+
+	keys := make([]K, len(batch))
+	for i, call := range batch {
+		keys[i] = call.key
+	}
+
+	if b.cfg.OnBatch != nil {
+		b.cfg.OnBatch(ctx, len(batch))
+	}
+
+	values, errs, err := b.dispatch(ctx, keys)
+	for i, call := range batch {
+		if err != nil {
+			call.err = err
+		} else {
+			call.value, call.err = values[i], errs[i]
+		}
+		close(call.done)
+	}
+}
@@ -0,0 +1,293 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/versiontype"
+)
+
+// mockBatchCluster serves multi get and multi search requests.
+type mockBatchCluster struct {
+	mu sync.Mutex
+
+	mgets    [][]string
+	msearchs [][]string
+	fail     bool
+}
+
+func (m *mockBatchCluster) handle(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.fail {
+		return mockResponse(500, `{"error":{"type":"internal_server_error","reason":"boom"},"status":500}`)
+	}
+
+	switch {
+	case req.URL.Path == "/_mget":
+		var body struct {
+			Docs []struct {
+				Index string `json:"_index"`
+				ID    string `json:"_id"`
+			} `json:"docs"`
+		}
+		json.NewDecoder(req.Body).Decode(&body)
+
+		var (
+			ids  []string
+			docs []string
+		)
+		for _, d := range body.Docs {
+			ids = append(ids, d.ID)
+			switch d.ID {
+			case "missing":
+				docs = append(docs, fmt.Sprintf(`{"_index":%q,"_id":%q,"found":false}`, d.Index, d.ID))
+			case "error":
+				docs = append(docs, fmt.Sprintf(`{"_index":%q,"_id":%q,"error":{"type":"index_not_found_exception","reason":"no such index"}}`, d.Index, d.ID))
+			default:
+				docs = append(docs, fmt.Sprintf(`{"_index":%q,"_id":%q,"found":true,"_seq_no":1,"_primary_term":1,"_source":{"id":%q}}`, d.Index, d.ID, d.ID))
+			}
+		}
+		m.mgets = append(m.mgets, ids)
+		return mockResponse(200, fmt.Sprintf(`{"docs":[%s]}`, strings.Join(docs, ",")))
+
+	case req.URL.Path == "/_msearch":
+		body, _ := ioutil.ReadAll(req.Body)
+		lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+
+		var (
+			indices   []string
+			responses []string
+		)
+		for i := 0; i < len(lines); i += 2 {
+			var header struct {
+				Index []string `json:"index"`
+			}
+			json.Unmarshal([]byte(lines[i]), &header)
+			index := strings.Join(header.Index, ",")
+			indices = append(indices, index)
+			if index == "missing" {
+				responses = append(responses, `{"error":{"type":"index_not_found_exception","reason":"no such index [missing]"},"status":404}`)
+				continue
+			}
+			responses = append(responses, fmt.Sprintf(`{"took":1,"timed_out":false,"_shards":{"total":1,"successful":1,"failed":0},"hits":{"hits":[{"_index":%q,"_id":"1"}]},"status":200}`, index))
+		}
+		m.msearchs = append(m.msearchs, indices)
+		return mockResponse(200, fmt.Sprintf(`{"took":1,"responses":[%s]}`, strings.Join(responses, ",")))
+	}
+
+	return mockResponse(404, `{}`)
+}
+
+func TestGetBatcher(t *testing.T) {
+	t.Run("Batch", func(t *testing.T) {
+		cluster := &mockBatchCluster{}
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+		g := NewGetBatcher(es, BatcherConfig{Wait: 50 * time.Millisecond})
+
+		ids := []string{"1", "2", "missing", "error", "2"}
+		var (
+			wg      sync.WaitGroup
+			results = make([]*types.GetResult, len(ids))
+			errs    = make([]error, len(ids))
+		)
+		for i, id := range ids {
+			wg.Add(1)
+			go func(i int, id string) {
+				defer wg.Done()
+				results[i], errs[i] = g.Get(context.Background(), "test", id)
+			}(i, id)
+		}
+		wg.Wait()
+
+		if len(cluster.mgets) != 1 {
+			t.Fatalf("Unexpected number of requests: %v", cluster.mgets)
+		}
+		if n := len(cluster.mgets[0]); n != 4 {
+			t.Errorf("Expected identical gets to be sent once, got: %v", cluster.mgets[0])
+		}
+
+		for i, id := range ids {
+			switch id {
+			case "missing":
+				if errs[i] != nil || results[i].Found {
+					t.Errorf("Unexpected result for missing document: %+v, %v", results[i], errs[i])
+				}
+			case "error":
+				var esErr *types.ElasticsearchError
+				if !errors.As(errs[i], &esErr) || esErr.ErrorCause.Type != "index_not_found_exception" {
+					t.Errorf("Unexpected error: %v", errs[i])
+				}
+			default:
+				if errs[i] != nil {
+					t.Fatalf("Unexpected error: %s", errs[i])
+				}
+				if !results[i].Found || string(results[i].Source_) != fmt.Sprintf(`{"id":%q}`, id) {
+					t.Errorf("Unexpected result: %+v", results[i])
+				}
+			}
+		}
+	})
+
+	t.Run("Identical operations", func(t *testing.T) {
+		cluster := &mockBatchCluster{}
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+		g := NewGetBatcher(es, BatcherConfig{Wait: 50 * time.Millisecond})
+
+		operation := func(version int64, versionType versiontype.VersionType) types.MgetOperation {
+			index := "test"
+			return types.MgetOperation{Index_: &index, Id_: "1", Version: &version, VersionType: &versionType}
+		}
+		ops := []types.MgetOperation{
+			operation(1, versiontype.Internal),
+			operation(1, versiontype.Internal),
+			operation(1, versiontype.External),
+		}
+
+		var wg sync.WaitGroup
+		for _, op := range ops {
+			wg.Add(1)
+			go func(op types.MgetOperation) {
+				defer wg.Done()
+				if _, err := g.GetOperation(context.Background(), op); err != nil {
+					t.Errorf("Unexpected error: %s", err)
+				}
+			}(op)
+		}
+		wg.Wait()
+
+		if len(cluster.mgets) != 1 || len(cluster.mgets[0]) != 2 {
+			t.Errorf("Expected operations differing only in version_type to be sent separately, got: %v", cluster.mgets)
+		}
+	})
+
+	t.Run("MaxBatch", func(t *testing.T) {
+		cluster := &mockBatchCluster{}
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+
+		var (
+			mu      sync.Mutex
+			batches []int
+		)
+		g := NewGetBatcher(es, BatcherConfig{
+			MaxBatch: 3,
+			Wait:     time.Hour,
+			OnBatch: func(ctx context.Context, n int) {
+				mu.Lock()
+				batches = append(batches, n)
+				mu.Unlock()
+			},
+		})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 6; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if _, err := g.Get(context.Background(), "test", fmt.Sprint(i)); err != nil {
+					t.Errorf("Unexpected error: %s", err)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		if fmt.Sprint(batches) != "[3 3]" {
+			t.Errorf("Unexpected batches: %v", batches)
+		}
+	})
+
+	t.Run("Request error", func(t *testing.T) {
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: (&mockBatchCluster{fail: true}).handle}})
+		g := NewGetBatcher(es, BatcherConfig{})
+
+		if _, err := g.Get(context.Background(), "test", "1"); err == nil {
+			t.Errorf("Expected error")
+		}
+	})
+
+	t.Run("Context", func(t *testing.T) {
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: (&mockBatchCluster{}).handle}})
+		g := NewGetBatcher(es, BatcherConfig{Wait: time.Hour})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := g.Get(ctx, "test", "1"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
+
+func TestSearchBatcher(t *testing.T) {
+	cluster := &mockBatchCluster{}
+	es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+	s := NewSearchBatcher(es, BatcherConfig{Wait: 50 * time.Millisecond})
+
+	indices := []string{"a", "missing", "b"}
+	var (
+		wg      sync.WaitGroup
+		results = make([]*types.MultiSearchItem, len(indices))
+		errs    = make([]error, len(indices))
+	)
+	for i, index := range indices {
+		wg.Add(1)
+		go func(i int, index string) {
+			defer wg.Done()
+			size := 1
+			results[i], errs[i] = s.Search(context.Background(),
+				types.MultisearchHeader{Index: []string{index}},
+				types.MultisearchBody{Size: &size})
+		}(i, index)
+	}
+	wg.Wait()
+
+	if len(cluster.msearchs) != 1 || len(cluster.msearchs[0]) != 3 {
+		t.Fatalf("Unexpected requests: %v", cluster.msearchs)
+	}
+
+	for i, index := range indices {
+		if index == "missing" {
+			var esErr *types.ElasticsearchError
+			if !errors.As(errs[i], &esErr) || esErr.Status != 404 {
+				t.Errorf("Unexpected error: %v", errs[i])
+			}
+			continue
+		}
+		if errs[i] != nil {
+			t.Fatalf("Unexpected error: %s", errs[i])
+		}
+		if len(results[i].Hits.Hits) != 1 || results[i].Hits.Hits[0].Index_ != index {
+			t.Errorf("Unexpected result: %+v", results[i].Hits)
+		}
+	}
+}
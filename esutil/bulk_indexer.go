@@ -19,9 +19,12 @@ package esutil
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"net/http"
 	"runtime"
@@ -30,22 +33,36 @@ import (
 	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/metric"
+
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esapi"
 )
 
 // BulkIndexer represents a parallel, asynchronous, efficient indexer for Elasticsearch.
 type BulkIndexer interface {
-	// Add adds an item to the indexer. It returns an error when the item cannot be added.
+	// Add adds an item to the indexer. It returns an error when the item cannot be added,
+	// eg. after a call to Close.
 	// Use the OnSuccess and OnFailure callbacks to get the operation result for the item.
 	//
 	// You must call the Close() method after you're done adding items.
 	//
-	// It is safe for concurrent use. When it's called from goroutines,
-	// they must finish before the call to Close, eg. using sync.WaitGroup.
+	// It is safe for concurrent use, also from the item callbacks, eg. to retry a failed item.
+	// When it's called from goroutines, they must finish before the call to Close,
+	// eg. using sync.WaitGroup.
 	Add(context.Context, BulkIndexerItem) error
 
+	// Flush sends all items added so far to Elasticsearch and waits until
+	// the requests complete. It returns early with the context error when
+	// the context is done; the flush continues in the background.
+	//
+	// The callbacks must not call Flush, since they run on the workers it waits for.
+	Flush(context.Context) error
+
 	// Close waits until all added items are flushed and closes the indexer.
+	//
+	// When the context is done before the workers finish, Close returns
+	// the context error; the in-flight requests drain in the background.
 	Close(context.Context) error
 
 	// Stats returns indexer statistics.
@@ -58,13 +75,34 @@ type BulkIndexerConfig struct {
 	FlushBytes    int           // The flush threshold in bytes. Defaults to 5MB.
 	FlushInterval time.Duration // The flush threshold as duration. Defaults to 30sec.
 
+	// CompressRequestBody enables gzip compression of the bulk request body,
+	// which is compressed while the items are written. The flush threshold
+	// applies to the uncompressed size.
+	//
+	// It can't be combined with the CompressRequestBody option of the client,
+	// which would compress the body a second time.
+	CompressRequestBody      bool
+	CompressRequestBodyLevel int  // Default: gzip.DefaultCompression.
+	PoolCompressor           bool // If true, a sync.Pool based gzip writer is used. Default: false.
+
+	// OrderByKey sends items with the same index and document ID (or routing, when
+	// the ID is empty) to the same worker, so that operations on a document are
+	// applied in the order they were added. Items without a key are distributed
+	// across the workers in turn.
+	OrderByKey bool
+
 	Client      *elasticsearch.Client   // The Elasticsearch client.
 	Decoder     BulkResponseJSONDecoder // A custom JSON decoder.
 	DebugLogger BulkIndexerDebugLogger  // An optional logger for debugging.
 
 	OnError      func(context.Context, error)          // Called for indexer errors.
 	OnFlushStart func(context.Context) context.Context // Called when the flush starts.
-	OnFlushEnd   func(context.Context)                 // Called when the flush ends.
+	OnFlushEnd   func(context.Context)                 // Called when the flush ends, see BulkIndexerFlushReportFromContext.
+
+	// MeterProvider, when set, is used to record the indexer throughput and latency
+	// as OpenTelemetry metrics. Flush spans are recorded when the client has
+	// Instrumentation set.
+	MeterProvider metric.MeterProvider
 
 	// Parameters of the Bulk API.
 	Index               string
@@ -96,6 +134,21 @@ type BulkIndexerStats struct {
 	NumRequests uint64
 }
 
+// BulkIndexerFlushReport represents the outcome of a single flush.
+type BulkIndexerFlushReport struct {
+	WorkerID  int
+	NumItems  int           // The number of items sent in the request
+	NumBytes  int           // The uncompressed size of the request body
+	NumFailed int           // The number of failed items, including failures of the whole request
+	Took      time.Duration // The processing time reported by Elasticsearch
+	Duration  time.Duration // The total duration of the flush
+
+	StatusCounts map[int]int    // The number of items per response status
+	ErrorCounts  map[string]int // The number of failed items per error type
+
+	Err error // The error of the whole request, if any
+}
+
 // BulkIndexerItem represents an indexer item.
 type BulkIndexerItem struct {
 	Index           string
@@ -109,88 +162,146 @@ type BulkIndexerItem struct {
 	RetryOnConflict *int
 	IfSeqNo         *int64
 	IfPrimaryTerm   *int64
-	meta            bytes.Buffer // Item metadata header
-	payloadLength   int          // Item payload total length metadata+newline+body length
+	meta            []byte // Item metadata header
+	payloadLength   int    // Item payload total length metadata+newline+body length
 
 	OnSuccess func(context.Context, BulkIndexerItem, BulkIndexerResponseItem)        // Per item
 	OnFailure func(context.Context, BulkIndexerItem, BulkIndexerResponseItem, error) // Per item
+
+	// Future, when set, is resolved once the item has been acknowledged
+	// by Elasticsearch or has failed. See NewBulkIndexerFuture.
+	Future *BulkIndexerFuture
+}
+
+// BulkIndexerItemResult represents the outcome of an indexer item.
+type BulkIndexerItemResult struct {
+	Item     BulkIndexerItem
+	Response BulkIndexerResponseItem
+	Err      error // Set when the item has failed
+}
+
+// BulkIndexerFuture represents the pending result of an indexer item.
+//
+// It allows to commit upstream positions, eg. message queue offsets,
+// only once the item has been durably indexed.
+type BulkIndexerFuture struct {
+	once sync.Once
+	done chan struct{}
+	res  BulkIndexerItemResult
+	fn   func(BulkIndexerItemResult)
+}
+
+// NewBulkIndexerFuture creates a new future, to be set as BulkIndexerItem.Future.
+func NewBulkIndexerFuture() *BulkIndexerFuture {
+	return &BulkIndexerFuture{done: make(chan struct{})}
+}
+
+// NewBulkIndexerFutureFunc creates a new future which calls fn when the item completes,
+// from the goroutine of the worker; fn must not block.
+//
+// Unlike OnFailure, the future also completes with the failure of the whole
+// bulk request, eg. when Elasticsearch is not available.
+func NewBulkIndexerFutureFunc(fn func(BulkIndexerItemResult)) *BulkIndexerFuture {
+	return &BulkIndexerFuture{done: make(chan struct{}), fn: fn}
+}
+
+// Done returns a channel which is closed when the item completes.
+func (f *BulkIndexerFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the item completes or the context is done.
+func (f *BulkIndexerFuture) Wait(ctx context.Context) (BulkIndexerItemResult, error) {
+	select {
+	case <-ctx.Done():
+		return BulkIndexerItemResult{}, ctx.Err()
+	case <-f.done:
+		return f.res, f.res.Err
+	}
+}
+
+// resolve sets the future result; subsequent calls are no-op.
+func (f *BulkIndexerFuture) resolve(item BulkIndexerItem, info BulkIndexerResponseItem, err error) {
+	if f == nil {
+		return
+	}
+	f.once.Do(func() {
+		f.res = BulkIndexerItemResult{Item: item, Response: info, Err: err}
+		close(f.done)
+		if f.fn != nil {
+			f.fn(f.res)
+		}
+	})
 }
 
 // marshallMeta format as JSON the item metadata.
+//
+// The metadata is appended to a single slice sized for the item,
+// which is written to the worker buffer as is.
 func (item *BulkIndexerItem) marshallMeta() {
-	// Pre-allocate a buffer large enough for most use cases.
-	// 'aux = aux[:0]' resets the length without changing the capacity.
-	aux := make([]byte, 0, 256)
-
-	item.meta.WriteRune('{')
-	item.meta.Write(strconv.AppendQuote(aux, item.Action))
-	aux = aux[:0]
-	item.meta.WriteRune(':')
-	item.meta.WriteRune('{')
+	// Pre-allocate a slice large enough for most use cases.
+	meta := make([]byte, 0, 192+len(item.Action)+len(item.DocumentID)+len(item.Routing)+len(item.Index)+len(item.VersionType))
+
+	meta = append(meta, '{')
+	meta = strconv.AppendQuote(meta, item.Action)
+	meta = append(meta, ':', '{')
 	if item.DocumentID != "" {
-		item.meta.WriteString(`"_id":`)
-		item.meta.Write(strconv.AppendQuote(aux, item.DocumentID))
-		aux = aux[:0]
+		meta = append(meta, `"_id":`...)
+		meta = strconv.AppendQuote(meta, item.DocumentID)
 	}
 
 	if item.DocumentID != "" && item.Version != nil {
-		item.meta.WriteRune(',')
-		item.meta.WriteString(`"version":`)
-		item.meta.WriteString(strconv.FormatInt(*item.Version, 10))
+		meta = append(meta, ',')
+		meta = append(meta, `"version":`...)
+		meta = strconv.AppendInt(meta, *item.Version, 10)
 	}
 
 	if item.DocumentID != "" && item.VersionType != "" {
-		item.meta.WriteRune(',')
-		item.meta.WriteString(`"version_type":`)
-		item.meta.Write(strconv.AppendQuote(aux, item.VersionType))
-		aux = aux[:0]
+		meta = append(meta, ',')
+		meta = append(meta, `"version_type":`...)
+		meta = strconv.AppendQuote(meta, item.VersionType)
 	}
 
 	if item.Routing != "" {
 		if item.DocumentID != "" {
-			item.meta.WriteRune(',')
+			meta = append(meta, ',')
 		}
-		item.meta.WriteString(`"routing":`)
-		item.meta.Write(strconv.AppendQuote(aux, item.Routing))
-		aux = aux[:0]
+		meta = append(meta, `"routing":`...)
+		meta = strconv.AppendQuote(meta, item.Routing)
 	}
 	if item.Index != "" {
 		if item.DocumentID != "" || item.Routing != "" {
-			item.meta.WriteRune(',')
+			meta = append(meta, ',')
 		}
-		item.meta.WriteString(`"_index":`)
-		item.meta.Write(strconv.AppendQuote(aux, item.Index))
-		aux = aux[:0]
+		meta = append(meta, `"_index":`...)
+		meta = strconv.AppendQuote(meta, item.Index)
 	}
 	if item.RetryOnConflict != nil && item.Action == "update" {
 		if item.DocumentID != "" || item.Routing != "" || item.Index != "" {
-			item.meta.WriteString(",")
+			meta = append(meta, ',')
 		}
-		item.meta.WriteString(`"retry_on_conflict":`)
-		item.meta.Write(strconv.AppendInt(aux, int64(*item.RetryOnConflict), 10))
-		aux = aux[:0]
+		meta = append(meta, `"retry_on_conflict":`...)
+		meta = strconv.AppendInt(meta, int64(*item.RetryOnConflict), 10)
 	}
 	if item.RequireAlias {
 		if item.DocumentID != "" || item.Routing != "" || item.Index != "" || item.RetryOnConflict != nil {
-			item.meta.WriteString(",")
+			meta = append(meta, ',')
 		}
-		item.meta.WriteString(`"require_alias":`)
-		item.meta.Write(strconv.AppendBool(aux, item.RequireAlias))
-		aux = aux[:0]
+		meta = append(meta, `"require_alias":`...)
+		meta = strconv.AppendBool(meta, item.RequireAlias)
 	}
 
 	if item.DocumentID != "" && item.IfSeqNo != nil && item.IfPrimaryTerm != nil {
-		item.meta.WriteRune(',')
-		item.meta.WriteString(`"if_seq_no":`)
-		item.meta.WriteString(strconv.FormatInt(*item.IfSeqNo, 10))
-		item.meta.WriteRune(',')
-		item.meta.WriteString(`"if_primary_term":`)
-		item.meta.WriteString(strconv.FormatInt(*item.IfPrimaryTerm, 10))
+		meta = append(meta, ',')
+		meta = append(meta, `"if_seq_no":`...)
+		meta = strconv.AppendInt(meta, *item.IfSeqNo, 10)
+		meta = append(meta, ',')
+		meta = append(meta, `"if_primary_term":`...)
+		meta = strconv.AppendInt(meta, *item.IfPrimaryTerm, 10)
 	}
 
-	item.meta.WriteRune('}')
-	item.meta.WriteRune('}')
-	item.meta.WriteRune('\n')
+	meta = append(meta, '}', '}', '\n')
+	item.meta = meta
 }
 
 // computeLength calculate the size of the body and the metadata.
@@ -206,7 +317,7 @@ func (item *BulkIndexerItem) computeLength() error {
 			return err
 		}
 	}
-	item.payloadLength += len(item.meta.Bytes())
+	item.payloadLength += len(item.meta)
 	// Add one byte to account for newline at the end of payload.
 	item.payloadLength++
 
@@ -236,14 +347,53 @@ type BulkIndexerResponseItem struct {
 		Failed     int `json:"failed"`
 	} `json:"_shards"`
 
-	Error struct {
-		Type   string `json:"type"`
-		Reason string `json:"reason"`
-		Cause  struct {
-			Type   string `json:"type"`
-			Reason string `json:"reason"`
-		} `json:"caused_by"`
-	} `json:"error,omitempty"`
+	Error BulkIndexerResponseItemError `json:"error,omitempty"`
+
+	// Get contains the document source for updates with the _source parameter.
+	Get *BulkIndexerResponseItemGet `json:"get,omitempty"`
+
+	// Raw contains the item JSON as returned by Elasticsearch.
+	// It is only set by the default decoder.
+	Raw json.RawMessage `json:"-"`
+}
+
+// BulkIndexerResponseItemGet represents the document returned for an update.
+type BulkIndexerResponseItemGet struct {
+	Found       bool                       `json:"found"`
+	SeqNo       *int64                     `json:"_seq_no,omitempty"`
+	PrimaryTerm *int64                     `json:"_primary_term,omitempty"`
+	Routing     string                     `json:"_routing,omitempty"`
+	Source      json.RawMessage            `json:"_source,omitempty"`
+	Fields      map[string]json.RawMessage `json:"fields,omitempty"`
+}
+
+// UnmarshalJSON decodes the item and keeps a copy of the raw JSON.
+func (item *BulkIndexerResponseItem) UnmarshalJSON(data []byte) error {
+	type alias BulkIndexerResponseItem
+	var v alias
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	v.Raw = append(json.RawMessage(nil), data...)
+	*item = BulkIndexerResponseItem(v)
+	return nil
+}
+
+// Err returns the item error, or nil when the operation has succeeded.
+//
+// The returned error is a BulkIndexerResponseItemError,
+// which can be matched with errors.Is against the error classes,
+// eg. ErrVersionConflict.
+func (item BulkIndexerResponseItem) Err() error {
+	if item.Error.Type != "" {
+		e := item.Error
+		e.Status = item.Status
+		return e
+	}
+	if item.Status > 201 {
+		return BulkIndexerResponseItemError{Status: item.Status}
+	}
+	return nil
 }
 
 // BulkResponseJSONDecoder defines the interface for custom JSON decoders.
@@ -258,10 +408,18 @@ type BulkIndexerDebugLogger interface {
 
 type bulkIndexer struct {
 	wg      sync.WaitGroup
-	queue   chan BulkIndexerItem
+	mu      sync.Mutex             // Guards closed, and adding against Close
+	closed  bool                   // Set by Close, after which Add and Flush fail
+	adding  sync.WaitGroup         // Calls to Add in progress, which Close waits for before closing the queues
+	queues  []chan BulkIndexerItem // A single shared queue, or one per worker with OrderByKey
+	next    uint64                 // Worker for the next item without a key, with OrderByKey
 	workers []*worker
 	stats   *bulkIndexerStats
 
+	bufPool  sync.Pool // Worker buffers, with the capacity of FlushBytes
+	gzipPool sync.Pool // Gzip writers, with PoolCompressor
+	metrics  *bulkIndexerMetrics
+
 	config BulkIndexerConfig
 }
 
@@ -298,11 +456,40 @@ func NewBulkIndexer(cfg BulkIndexerConfig) (BulkIndexer, error) {
 		cfg.FlushInterval = 30 * time.Second
 	}
 
+	if cfg.CompressRequestBodyLevel == 0 {
+		cfg.CompressRequestBodyLevel = gzip.DefaultCompression
+	}
+	if cfg.CompressRequestBody {
+		if cfg.Client != nil && cfg.Client.CompressRequestBodyEnabled() {
+			return nil, errors.New("bulk indexer: CompressRequestBody can't be combined with the CompressRequestBody option of the client")
+		}
+		// Fail early on an invalid compression level.
+		if _, err := gzip.NewWriterLevel(io.Discard, cfg.CompressRequestBodyLevel); err != nil {
+			return nil, fmt.Errorf("bulk indexer: %s", err)
+		}
+	}
+
 	bi := bulkIndexer{
 		config: cfg,
 		stats:  &bulkIndexerStats{},
 	}
 
+	if cfg.MeterProvider != nil {
+		m, err := newBulkIndexerMetrics(cfg.MeterProvider)
+		if err != nil {
+			return nil, fmt.Errorf("bulk indexer: %s", err)
+		}
+		bi.metrics = m
+	}
+
+	bi.bufPool.New = func() interface{} {
+		return bytes.NewBuffer(make([]byte, 0, cfg.FlushBytes))
+	}
+	bi.gzipPool.New = func() interface{} {
+		zw, _ := gzip.NewWriterLevel(io.Discard, cfg.CompressRequestBodyLevel)
+		return zw
+	}
+
 	bi.init()
 
 	return &bi, nil
@@ -310,14 +497,24 @@ func NewBulkIndexer(cfg BulkIndexerConfig) (BulkIndexer, error) {
 
 // Add adds an item to the indexer.
 //
-// Adding an item after a call to Close() will panic.
+// Adding an item after a call to Close() returns an error.
 func (bi *bulkIndexer) Add(ctx context.Context, item BulkIndexerItem) error {
+	bi.mu.Lock()
+	if bi.closed {
+		bi.mu.Unlock()
+		return errors.New("add: bulk indexer is closed")
+	}
+	bi.adding.Add(1)
+	bi.mu.Unlock()
+	defer bi.adding.Done()
+
 	atomic.AddUint64(&bi.stats.numAdded, 1)
 
 	// Serialize metadata to JSON
 	item.marshallMeta()
 	// Compute length for body & metadata
 	if err := item.computeLength(); err != nil {
+		item.Future.resolve(item, BulkIndexerResponseItem{}, err)
 		return err
 	}
 
@@ -326,26 +523,96 @@ func (bi *bulkIndexer) Add(ctx context.Context, item BulkIndexerItem) error {
 		if bi.config.OnError != nil {
 			bi.config.OnError(ctx, ctx.Err())
 		}
+		item.Future.resolve(item, BulkIndexerResponseItem{}, ctx.Err())
 		return ctx.Err()
-	case bi.queue <- item:
+	case bi.queueFor(&item) <- item:
 	}
 
 	return nil
 }
 
+// Flush asks every worker to drain the queue and flush its buffer,
+// and waits for all of them to report back.
+//
+// The indexer mutex is not held while waiting, so that the item callbacks
+// can call Add on the workers which Flush waits for.
+func (bi *bulkIndexer) Flush(ctx context.Context) error {
+	bi.mu.Lock()
+	closed := bi.closed
+	bi.mu.Unlock()
+	if closed {
+		return errors.New("flush: bulk indexer is closed")
+	}
+
+	reqs := make([]flushRequest, len(bi.workers))
+	for i, w := range bi.workers {
+		reqs[i] = flushRequest{ctx: ctx, done: make(chan error, 1)}
+		select {
+		case <-ctx.Done():
+			return bi.flushCanceled(ctx)
+		case <-w.stopped:
+			return errors.New("flush: bulk indexer is closed")
+		case w.flushCh <- reqs[i]:
+		}
+	}
+
+	var errs []error
+	for _, req := range reqs {
+		select {
+		case <-ctx.Done():
+			return bi.flushCanceled(ctx)
+		case err := <-req.done:
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// flushCanceled reports and returns the context error.
+func (bi *bulkIndexer) flushCanceled(ctx context.Context) error {
+	if bi.config.OnError != nil {
+		bi.config.OnError(ctx, ctx.Err())
+	}
+	return ctx.Err()
+}
+
 // Close stops the periodic flush, closes the indexer queue channel,
 // which triggers the workers to flush and stop.
+//
+// The queues are closed once the calls to Add in progress have returned,
+// which the workers keep consuming.
 func (bi *bulkIndexer) Close(ctx context.Context) error {
-	close(bi.queue)
+	bi.mu.Lock()
+	closed := bi.closed
+	bi.closed = true
+	bi.mu.Unlock()
 
+	done := make(chan struct{})
+	go func() {
+		if !closed {
+			bi.adding.Wait()
+			for _, q := range bi.queues {
+				close(q)
+			}
+		}
+		bi.wg.Wait()
+		close(done)
+	}()
+
+	// Give priority to a context which is already done.
 	select {
 	case <-ctx.Done():
-		if bi.config.OnError != nil {
-			bi.config.OnError(ctx, ctx.Err())
-		}
-		return ctx.Err()
+		return bi.flushCanceled(ctx)
 	default:
-		bi.wg.Wait()
+	}
+
+	select {
+	case <-ctx.Done():
+		return bi.flushCanceled(ctx)
+	case <-done:
 	}
 
 	return nil
@@ -367,15 +634,22 @@ func (bi *bulkIndexer) Stats() BulkIndexerStats {
 
 // init initializes the bulk indexer.
 func (bi *bulkIndexer) init() {
-	bi.queue = make(chan BulkIndexerItem, bi.config.NumWorkers)
+	if bi.config.OrderByKey {
+		for i := 0; i < bi.config.NumWorkers; i++ {
+			bi.queues = append(bi.queues, make(chan BulkIndexerItem, 1))
+		}
+	} else {
+		bi.queues = append(bi.queues, make(chan BulkIndexerItem, bi.config.NumWorkers))
+	}
 
 	for i := 1; i <= bi.config.NumWorkers; i++ {
 		w := worker{
-			id:     i,
-			ch:     bi.queue,
-			bi:     bi,
-			buf:    bytes.NewBuffer(make([]byte, 0, bi.config.FlushBytes)),
-			ticker: time.NewTicker(bi.config.FlushInterval),
+			id:      i,
+			ch:      bi.queues[(i-1)%len(bi.queues)],
+			flushCh: make(chan flushRequest),
+			stopped: make(chan struct{}),
+			bi:      bi,
+			ticker:  time.NewTicker(bi.config.FlushInterval),
 		}
 		w.run()
 		bi.workers = append(bi.workers, &w)
@@ -383,14 +657,54 @@ func (bi *bulkIndexer) init() {
 	bi.wg.Add(bi.config.NumWorkers)
 }
 
+// queueFor returns the queue for the item.
+//
+// With OrderByKey, the worker is selected by a hash of the item key,
+// which keeps the operations on a single document in order.
+func (bi *bulkIndexer) queueFor(item *BulkIndexerItem) chan BulkIndexerItem {
+	if len(bi.queues) == 1 {
+		return bi.queues[0]
+	}
+
+	key := item.DocumentID
+	if key == "" {
+		key = item.Routing
+	}
+	if key == "" {
+		n := atomic.AddUint64(&bi.next, 1)
+		return bi.queues[n%uint64(len(bi.queues))]
+	}
+
+	index := item.Index
+	if index == "" {
+		index = bi.config.Index
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(index))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return bi.queues[h.Sum32()%uint32(len(bi.queues))]
+}
+
 // worker represents an indexer worker.
 type worker struct {
-	id     int
-	ch     <-chan BulkIndexerItem
-	bi     *bulkIndexer
-	buf    *bytes.Buffer
-	items  []BulkIndexerItem
-	ticker *time.Ticker
+	id      int
+	ch      <-chan BulkIndexerItem
+	flushCh chan flushRequest
+	stopped chan struct{} // Closed when the worker has stopped
+	bi      *bulkIndexer
+	buf     *bytes.Buffer // Acquired from the pool on first write, released on flush
+	zw      *gzip.Writer  // Compresses into buf, with CompressRequestBody
+	size    int           // Uncompressed size of the buffered items
+	items   []BulkIndexerItem
+	ticker  *time.Ticker
+}
+
+// flushRequest represents an explicit request to flush the worker.
+type flushRequest struct {
+	ctx  context.Context
+	done chan error
 }
 
 // run launches the worker in a goroutine.
@@ -404,6 +718,7 @@ func (w *worker) run() {
 		defer func() {
 			w.flush(ctx)
 			w.ticker.Stop()
+			close(w.stopped)
 			w.bi.wg.Done()
 		}()
 
@@ -415,54 +730,129 @@ func (w *worker) run() {
 						w.id, w.bi.config.FlushInterval)
 				}
 				w.flush(ctx)
+			case req := <-w.flushCh:
+				if w.bi.config.DebugLogger != nil {
+					w.bi.config.DebugLogger.Printf("[worker-%03d] Flush requested\n", w.id)
+				}
+				w.drain(ctx)
+				// The request is not canceled with Flush, which returns early
+				// and leaves the flush to complete in the background.
+				err := w.flushBuffer(context.WithoutCancel(req.ctx))
+				w.ticker.Reset(w.bi.config.FlushInterval)
+				req.done <- err
 			case item, ok := <-w.ch:
 				if !ok {
 					return
 				}
+				w.process(ctx, item)
+			}
+		}
+	}()
+}
 
-				if w.bi.config.DebugLogger != nil {
-					w.bi.config.DebugLogger.Printf("[worker-%03d] Received item [%s:%s]\n", w.id, item.Action, item.DocumentID)
-				}
+// drain processes the items remaining in the queue, without blocking.
+func (w *worker) drain(ctx context.Context) {
+	for {
+		select {
+		case item, ok := <-w.ch:
+			if !ok {
+				return
+			}
+			w.process(ctx, item)
+		default:
+			return
+		}
+	}
+}
 
-				oversizePayload := w.bi.config.FlushBytes <= item.payloadLength
-				if !oversizePayload && w.buf.Len() > 0 && w.buf.Len()+item.payloadLength >= w.bi.config.FlushBytes {
-					if !w.flush(ctx) {
-						continue
-					}
-				}
+// process writes the item to the buffer, flushing it when full.
+func (w *worker) process(ctx context.Context, item BulkIndexerItem) {
+	if w.bi.config.DebugLogger != nil {
+		w.bi.config.DebugLogger.Printf("[worker-%03d] Received item [%s:%s]\n", w.id, item.Action, item.DocumentID)
+	}
 
-				if err := w.writeMeta(&item); err != nil {
-					if item.OnFailure != nil {
-						item.OnFailure(ctx, item, BulkIndexerResponseItem{}, err)
-					}
-					atomic.AddUint64(&w.bi.stats.numFailed, 1)
-					continue
-				}
+	oversizePayload := w.bi.config.FlushBytes <= item.payloadLength
+	if !oversizePayload && w.size > 0 && w.size+item.payloadLength >= w.bi.config.FlushBytes {
+		if err := w.flush(ctx); err != nil {
+			w.fail(ctx, item, err)
+			return
+		}
+	}
 
-				if err := w.writeBody(&item); err != nil {
-					if item.OnFailure != nil {
-						item.OnFailure(ctx, item, BulkIndexerResponseItem{}, err)
-					}
-					atomic.AddUint64(&w.bi.stats.numFailed, 1)
-					continue
-				}
+	if w.buf == nil {
+		w.acquire()
+	}
 
-				w.items = append(w.items, item)
-				// Should the item payload exceed the configured FlushBytes flush happens instantly.
-				if oversizePayload {
-					if w.bi.config.DebugLogger != nil {
-						w.bi.config.DebugLogger.Printf("[worker-%03d] Oversize Payload in item [%s:%s]\n", w.id, item.Action, item.DocumentID)
-					}
-					w.flush(ctx)
-				}
-			}
+	if err := w.writeMeta(&item); err != nil {
+		w.fail(ctx, item, err)
+		return
+	}
+
+	if err := w.writeBody(&item); err != nil {
+		w.fail(ctx, item, err)
+		return
+	}
+
+	w.items = append(w.items, item)
+	// Should the item payload exceed the configured FlushBytes flush happens instantly.
+	if oversizePayload {
+		if w.bi.config.DebugLogger != nil {
+			w.bi.config.DebugLogger.Printf("[worker-%03d] Oversize Payload in item [%s:%s]\n", w.id, item.Action, item.DocumentID)
 		}
-	}()
+		w.flush(ctx)
+	}
+}
+
+// fail reports an item which failed without a response item,
+// eg. when it could not be written to the buffer.
+func (w *worker) fail(ctx context.Context, item BulkIndexerItem, err error) {
+	if item.OnFailure != nil {
+		item.OnFailure(ctx, item, BulkIndexerResponseItem{}, err)
+	}
+	item.Future.resolve(item, BulkIndexerResponseItem{}, err)
+	atomic.AddUint64(&w.bi.stats.numFailed, 1)
+}
+
+// acquire takes a buffer, and a gzip writer with CompressRequestBody, from the pools.
+func (w *worker) acquire() {
+	w.buf = w.bi.bufPool.Get().(*bytes.Buffer)
+	if !w.bi.config.CompressRequestBody {
+		return
+	}
+	if w.bi.config.PoolCompressor {
+		w.zw = w.bi.gzipPool.Get().(*gzip.Writer)
+		w.zw.Reset(w.buf)
+	} else {
+		w.zw, _ = gzip.NewWriterLevel(w.buf, w.bi.config.CompressRequestBodyLevel)
+	}
+}
+
+// release returns the buffer and the gzip writer to the pools.
+// Buffers grown over FlushBytes by an oversize payload are dropped.
+func (w *worker) release() {
+	if w.zw != nil && w.bi.config.PoolCompressor {
+		w.bi.gzipPool.Put(w.zw)
+	}
+	if w.buf != nil && w.buf.Cap() <= w.bi.config.FlushBytes {
+		w.buf.Reset()
+		w.bi.bufPool.Put(w.buf)
+	}
+	w.buf, w.zw, w.size = nil, nil, 0
+}
+
+// out returns the writer for the item payloads.
+func (w *worker) out() io.Writer {
+	if w.zw != nil {
+		return w.zw
+	}
+	return w.buf
 }
 
 // writeMeta writes the item metadata to the buffer.
 func (w *worker) writeMeta(item *BulkIndexerItem) error {
-	if _, err := w.buf.Write(item.meta.Bytes()); err != nil {
+	n, err := w.out().Write(item.meta)
+	w.size += n
+	if err != nil {
 		return err
 	}
 	return nil
@@ -471,44 +861,46 @@ func (w *worker) writeMeta(item *BulkIndexerItem) error {
 // writeBody writes the item body to the buffer.
 func (w *worker) writeBody(item *BulkIndexerItem) error {
 	if item.Body != nil {
-		if _, err := w.buf.ReadFrom(item.Body); err != nil {
+		n, err := io.Copy(w.out(), item.Body)
+		w.size += int(n)
+		if err != nil {
 			if w.bi.config.OnError != nil {
 				w.bi.config.OnError(context.Background(), err)
 			}
 			return err
 		}
 		item.Body.Seek(0, io.SeekStart)
-		w.buf.WriteRune('\n')
+		w.out().Write([]byte{'\n'})
+		w.size++
 	}
 	return nil
 }
 
 // flush writes out the worker buffer and handles errors.
 // It also restarts the ticker.
-// Returns true to indicate success.
-func (w *worker) flush(ctx context.Context) bool {
-	ok := true
-	if err := w.flushBuffer(ctx); err != nil {
-		if w.bi.config.OnError != nil {
-			w.bi.config.OnError(ctx, err)
-		}
-		ok = false
+// Returns the error of the flush.
+func (w *worker) flush(ctx context.Context) error {
+	err := w.flushBuffer(ctx)
+	if err != nil && w.bi.config.OnError != nil {
+		w.bi.config.OnError(ctx, err)
 	}
 	w.ticker.Reset(w.bi.config.FlushInterval)
-	return ok
+	return err
 }
 
 // flushBuffer writes out the worker buffer.
-func (w *worker) flushBuffer(ctx context.Context) error {
+func (w *worker) flushBuffer(ctx context.Context) (err error) {
 	if w.bi.config.OnFlushStart != nil {
 		ctx = w.bi.config.OnFlushStart(ctx)
 	}
 
+	// The context carries the flush report by the time the callback runs,
+	// since the report is deferred after it.
 	if w.bi.config.OnFlushEnd != nil {
 		defer func() { w.bi.config.OnFlushEnd(ctx) }()
 	}
 
-	if w.buf.Len() < 1 {
+	if w.size < 1 {
 		if w.bi.config.DebugLogger != nil {
 			w.bi.config.DebugLogger.Printf("[worker-%03d] Flush: Buffer empty\n", w.id)
 		}
@@ -516,21 +908,51 @@ func (w *worker) flushBuffer(ctx context.Context) error {
 	}
 
 	var (
-		err error
 		blk BulkIndexerResponse
+
+		start  = time.Now()
+		report = BulkIndexerFlushReport{
+			WorkerID:     w.id,
+			NumItems:     len(w.items),
+			NumBytes:     w.size,
+			StatusCounts: make(map[int]int),
+			ErrorCounts:  make(map[string]int),
+		}
 	)
 
+	if instr := w.bi.config.Client.InstrumentationEnabled(); instr != nil {
+		ctx = instr.Start(ctx, "bulk_indexer.flush")
+		defer instr.Close(ctx)
+	}
+
 	defer func() {
-		w.items = nil
-		if w.buf.Cap() > w.bi.config.FlushBytes {
-			w.buf = bytes.NewBuffer(make([]byte, 0, w.bi.config.FlushBytes))
-		} else {
-			w.buf.Reset()
+		report.Duration = time.Since(start)
+		report.Err = err
+		if err != nil {
+			report.NumFailed = report.NumItems
 		}
+		ctx = w.report(ctx, report)
 	}()
 
+	defer func() {
+		w.items = nil
+		w.release()
+	}()
+
+	if w.zw != nil {
+		if err := w.zw.Close(); err != nil {
+			atomic.AddUint64(&w.bi.stats.numFailed, uint64(len(w.items)))
+			w.resolveAll(fmt.Errorf("flush: error compressing request body: %s", err))
+			return fmt.Errorf("flush: error compressing request body: %s", err)
+		}
+	}
+
 	if w.bi.config.DebugLogger != nil {
-		w.bi.config.DebugLogger.Printf("[worker-%03d] Flush: %s\n", w.id, w.buf.String())
+		if w.zw != nil {
+			w.bi.config.DebugLogger.Printf("[worker-%03d] Flush: %d bytes, %d compressed\n", w.id, w.size, w.buf.Len())
+		} else {
+			w.bi.config.DebugLogger.Printf("[worker-%03d] Flush: %s\n", w.id, w.buf.String())
+		}
 	}
 
 	atomic.AddUint64(&w.bi.stats.numRequests, 1)
@@ -562,6 +984,9 @@ func (w *worker) flushBuffer(ctx context.Context) error {
 		req.Header = http.Header{}
 	}
 	req.Header.Set(elasticsearch.HeaderClientMeta, "h=bp")
+	if w.zw != nil {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
 
 	res, err := req.Do(ctx, w.bi.config.Client)
 	if err != nil {
@@ -569,6 +994,7 @@ func (w *worker) flushBuffer(ctx context.Context) error {
 		if w.bi.config.OnError != nil {
 			w.bi.config.OnError(ctx, fmt.Errorf("flush: %s", err))
 		}
+		w.resolveAll(fmt.Errorf("flush: %s", err))
 		return fmt.Errorf("flush: %s", err)
 	}
 	if res.Body != nil {
@@ -580,6 +1006,7 @@ func (w *worker) flushBuffer(ctx context.Context) error {
 		if w.bi.config.OnError != nil {
 			w.bi.config.OnError(ctx, fmt.Errorf("flush: %s", res.String()))
 		}
+		w.resolveAll(fmt.Errorf("flush: %s", res.String()))
 		return fmt.Errorf("flush: %s", res.String())
 	}
 
@@ -588,10 +1015,16 @@ func (w *worker) flushBuffer(ctx context.Context) error {
 		if w.bi.config.OnError != nil {
 			w.bi.config.OnError(ctx, fmt.Errorf("flush: %s", err))
 		}
+		w.resolveAll(fmt.Errorf("flush: error parsing response body: %s", err))
 		return fmt.Errorf("flush: error parsing response body: %s", err)
 	}
+	report.Took = time.Duration(blk.Took) * time.Millisecond
 
 	for i, blkItem := range blk.Items {
+		if i >= len(w.items) {
+			break
+		}
+
 		var (
 			item BulkIndexerItem
 			info BulkIndexerResponseItem
@@ -606,11 +1039,16 @@ func (w *worker) flushBuffer(ctx context.Context) error {
 			op = k
 			info = v
 		}
-		if info.Error.Type != "" || info.Status > 201 {
+		report.StatusCounts[info.Status]++
+		if itemErr := info.Err(); itemErr != nil {
+			report.NumFailed++
+			report.ErrorCounts[info.Error.Type]++
+			w.recordFailure(ctx, item, info)
 			atomic.AddUint64(&w.bi.stats.numFailed, 1)
 			if item.OnFailure != nil {
 				item.OnFailure(ctx, item, info, nil)
 			}
+			item.Future.resolve(item, info, itemErr)
 		} else {
 			atomic.AddUint64(&w.bi.stats.numFlushed, 1)
 
@@ -628,12 +1066,30 @@ func (w *worker) flushBuffer(ctx context.Context) error {
 			if item.OnSuccess != nil {
 				item.OnSuccess(ctx, item, info)
 			}
+			item.Future.resolve(item, info, nil)
+		}
+	}
+
+	// Items without a matching response entry cannot be acknowledged.
+	if len(blk.Items) < len(w.items) {
+		err := errors.New("flush: missing item in response")
+		for _, item := range w.items[len(blk.Items):] {
+			report.NumFailed++
+			w.fail(ctx, item, err)
 		}
 	}
 
 	return err
 }
 
+// resolveAll resolves the futures of the buffered items with err.
+// Futures which were already resolved are left untouched.
+func (w *worker) resolveAll(err error) {
+	for _, item := range w.items {
+		item.Future.resolve(item, BulkIndexerResponseItem{}, err)
+	}
+}
+
 type defaultJSONDecoder struct{}
 
 func (d defaultJSONDecoder) UnmarshalFromReader(r io.Reader, blk *BulkIndexerResponse) error {
@@ -61,30 +61,41 @@ func (t *mockTransp) RoundTrip(req *http.Request) (*http.Response, error) {
 func BenchmarkBulkIndexer(b *testing.B) {
 	b.ReportAllocs()
 
-	b.Run("Basic", func(b *testing.B) {
-		b.ResetTimer()
+	for _, tt := range []struct {
+		name string
+		cfg  esutil.BulkIndexerConfig
+	}{
+		{name: "Basic"},
+		{name: "Compressed", cfg: esutil.BulkIndexerConfig{CompressRequestBody: true}},
+		{name: "Compressed Pooled", cfg: esutil.BulkIndexerConfig{CompressRequestBody: true, PoolCompressor: true}},
+	} {
+		tt := tt
 
-		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransp{}})
-		bi, _ := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
-			Client:     es,
-			FlushBytes: 1024,
-		})
-		defer bi.Close(context.Background())
+		b.Run(tt.name, func(b *testing.B) {
+			b.ResetTimer()
+
+			es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransp{}})
+			cfg := tt.cfg
+			cfg.Client = es
+			cfg.FlushBytes = 1024
+			bi, _ := esutil.NewBulkIndexer(cfg)
+			defer bi.Close(context.Background())
 
-		docID := make([]byte, 0, 16)
-		var docIDBuf bytes.Buffer
-		docIDBuf.Grow(cap(docID))
+			docID := make([]byte, 0, 16)
+			var docIDBuf bytes.Buffer
+			docIDBuf.Grow(cap(docID))
 
-		for i := 0; i < b.N; i++ {
-			docID = strconv.AppendInt(docID, int64(i), 10)
-			docIDBuf.Write(docID)
-			bi.Add(context.Background(), esutil.BulkIndexerItem{
-				Action:     "index",
-				DocumentID: docIDBuf.String(),                  // 1x alloc
-				Body:       strings.NewReader(`{"foo":"bar"}`), // 1x alloc
-			})
-			docID = docID[:0]
-			docIDBuf.Reset()
-		}
-	})
+			for i := 0; i < b.N; i++ {
+				docID = strconv.AppendInt(docID, int64(i), 10)
+				docIDBuf.Write(docID)
+				bi.Add(context.Background(), esutil.BulkIndexerItem{
+					Action:     "index",
+					DocumentID: docIDBuf.String(),                  // 1x alloc
+					Body:       strings.NewReader(`{"foo":"bar"}`), // 1x alloc
+				})
+				docID = docID[:0]
+				docIDBuf.Reset()
+			}
+		})
+	}
 }
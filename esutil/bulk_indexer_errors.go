@@ -0,0 +1,179 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// Error classes of the bulk indexer items, to be used with errors.Is.
+var (
+	ErrVersionConflict  = errors.New("version conflict")
+	ErrDocumentMissing  = errors.New("document missing")
+	ErrIndexNotFound    = errors.New("index not found")
+	ErrMapping          = errors.New("mapping error")
+	ErrRejected         = errors.New("rejected execution")
+	ErrIndexReadOnly    = errors.New("index read-only")
+	ErrScriptFailure    = errors.New("script failure")
+	ErrPipelineFailure  = errors.New("ingest pipeline failure")
+	ErrCircuitBreaking  = errors.New("circuit breaking")
+	ErrShardUnavailable = errors.New("shard unavailable")
+)
+
+// errorClasses maps the Elasticsearch error types to the error classes.
+var errorClasses = map[string]error{
+	"version_conflict_engine_exception":   ErrVersionConflict,
+	"document_missing_exception":          ErrDocumentMissing,
+	"index_not_found_exception":           ErrIndexNotFound,
+	"mapper_parsing_exception":            ErrMapping,
+	"strict_dynamic_mapping_exception":    ErrMapping,
+	"document_parsing_exception":          ErrMapping,
+	"es_rejected_execution_exception":     ErrRejected,
+	"cluster_block_exception":             ErrIndexReadOnly,
+	"script_exception":                    ErrScriptFailure,
+	"ingest_processor_exception":          ErrPipelineFailure,
+	"circuit_breaking_exception":          ErrCircuitBreaking,
+	"unavailable_shards_exception":        ErrShardUnavailable,
+	"no_shard_available_action_exception": ErrShardUnavailable,
+}
+
+// BulkIndexerErrorCause represents a cause of the item error.
+type BulkIndexerErrorCause struct {
+	Type      string `json:"type"`
+	Reason    string `json:"reason"`
+	Index     string `json:"index,omitempty"`
+	IndexUUID string `json:"index_uuid,omitempty"`
+	Shard     string `json:"shard,omitempty"`
+
+	Cause     *BulkIndexerErrorCause  `json:"caused_by,omitempty"`
+	RootCause []BulkIndexerErrorCause `json:"root_cause,omitempty"`
+}
+
+// BulkIndexerResponseItemError represents the error of a failed item.
+//
+// It implements the error interface, and can be matched with errors.Is
+// against the error classes, eg. ErrVersionConflict, and against
+// a types.ElasticsearchError with the same status and type.
+type BulkIndexerResponseItemError struct {
+	Type      string `json:"type"`
+	Reason    string `json:"reason"`
+	Index     string `json:"index,omitempty"`
+	IndexUUID string `json:"index_uuid,omitempty"`
+	Shard     string `json:"shard,omitempty"`
+
+	Cause     BulkIndexerErrorCause   `json:"caused_by"`
+	RootCause []BulkIndexerErrorCause `json:"root_cause,omitempty"`
+
+	Status int `json:"-"` // The item status, set by BulkIndexerResponseItem.Err
+}
+
+// Error implements the error interface.
+func (e BulkIndexerResponseItemError) Error() string {
+	if e.Type == "" {
+		return fmt.Sprintf("status: %d, failed: [%s]", e.Status, strings.ToLower(http.StatusText(e.Status)))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "status: %d, failed: [%s], reason: %s", e.Status, e.Type, e.Reason)
+	for c := &e.Cause; c != nil && c.Type != ""; c = c.Cause {
+		fmt.Fprintf(&b, ", caused by: [%s] %s", c.Type, c.Reason)
+	}
+	return b.String()
+}
+
+// Is allows to match the error with errors.Is against the error classes
+// and against a types.ElasticsearchError with the same status and type.
+//
+// The causes are checked as well, so that eg. a mapping error
+// wrapped in an illegal_argument_exception is reported as ErrMapping.
+func (e BulkIndexerResponseItemError) Is(target error) bool {
+	switch t := target.(type) {
+	case types.ElasticsearchError:
+		return e.Status == t.Status && e.Type == t.ErrorCause.Type
+	case *types.ElasticsearchError:
+		return t != nil && e.Status == t.Status && e.Type == t.ErrorCause.Type
+	}
+
+	if class, ok := errorClasses[e.Type]; ok && class == target {
+		return true
+	}
+	if e.Status == http.StatusTooManyRequests && target == ErrRejected {
+		return true
+	}
+	for c := &e.Cause; c != nil && c.Type != ""; c = c.Cause {
+		if class, ok := errorClasses[c.Type]; ok && class == target {
+			return true
+		}
+	}
+	return false
+}
+
+// ElasticsearchError converts the item error into a types.ElasticsearchError,
+// for code which handles the errors of the typed API.
+func (e BulkIndexerResponseItemError) ElasticsearchError() *types.ElasticsearchError {
+	return &types.ElasticsearchError{
+		Status:     e.Status,
+		ErrorCause: e.errorCause(),
+	}
+}
+
+// errorCause converts the item error into a types.ErrorCause.
+func (e BulkIndexerResponseItemError) errorCause() types.ErrorCause {
+	c := BulkIndexerErrorCause{
+		Type:      e.Type,
+		Reason:    e.Reason,
+		RootCause: e.RootCause,
+	}
+	if e.Cause.Type != "" {
+		cause := e.Cause
+		c.Cause = &cause
+	}
+	ec := c.errorCause()
+	if e.Index != "" || e.IndexUUID != "" || e.Shard != "" {
+		ec.Metadata = make(map[string]json.RawMessage)
+		for k, v := range map[string]string{"index": e.Index, "index_uuid": e.IndexUUID, "shard": e.Shard} {
+			if v != "" {
+				ec.Metadata[k], _ = json.Marshal(v)
+			}
+		}
+	}
+	return ec
+}
+
+// errorCause converts the cause into a types.ErrorCause, recursively.
+func (c BulkIndexerErrorCause) errorCause() types.ErrorCause {
+	ec := types.ErrorCause{Type: c.Type}
+	if c.Reason != "" {
+		reason := c.Reason
+		ec.Reason = &reason
+	}
+	if c.Cause != nil && c.Cause.Type != "" {
+		cause := c.Cause.errorCause()
+		ec.CausedBy = &cause
+	}
+	for _, rc := range c.RootCause {
+		ec.RootCause = append(ec.RootCause, rc.errorCause())
+	}
+	return ec
+}
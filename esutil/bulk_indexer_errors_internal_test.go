@@ -0,0 +1,141 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package esutil
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+func TestBulkIndexerResponseItemError(t *testing.T) {
+	f, err := os.Open("testdata/bulk_response_3.json")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer f.Close()
+
+	var blk BulkIndexerResponse
+	if err := (defaultJSONDecoder{}).UnmarshalFromReader(f, &blk); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	items := make([]BulkIndexerResponseItem, 0, len(blk.Items))
+	for _, m := range blk.Items {
+		for _, v := range m {
+			items = append(items, v)
+		}
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		item := items[0]
+		if err := item.Err(); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+		if item.Get == nil || !item.Get.Found {
+			t.Fatalf("Expected get payload, got: %+v", item.Get)
+		}
+		if string(item.Get.Source) != `{
+            "title": "foo"
+          }` {
+			t.Errorf("Unexpected _source: %s", item.Get.Source)
+		}
+		if item.Get.SeqNo == nil || *item.Get.SeqNo != 3 {
+			t.Errorf("Unexpected _seq_no: %v", item.Get.SeqNo)
+		}
+		if !strings.Contains(string(item.Raw), `"result": "updated"`) {
+			t.Errorf("Unexpected raw JSON: %s", item.Raw)
+		}
+	})
+
+	t.Run("Version conflict", func(t *testing.T) {
+		err := items[1].Err()
+		if !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("Expected ErrVersionConflict, got: %s", err)
+		}
+		if errors.Is(err, ErrMapping) {
+			t.Errorf("Unexpected ErrMapping for: %s", err)
+		}
+
+		var itemErr BulkIndexerResponseItemError
+		if !errors.As(err, &itemErr) {
+			t.Fatalf("Expected BulkIndexerResponseItemError, got: %T", err)
+		}
+		if itemErr.Status != 409 || itemErr.Shard != "0" || itemErr.IndexUUID != "eZMQ7DUzT56RLaQcAjOlxg" {
+			t.Errorf("Unexpected error details: %+v", itemErr)
+		}
+
+		esErr := types.ElasticsearchError{Status: 409, ErrorCause: types.ErrorCause{Type: "version_conflict_engine_exception"}}
+		if !errors.Is(err, esErr) {
+			t.Errorf("Expected error to match %s", esErr)
+		}
+		if converted := itemErr.ElasticsearchError(); !esErr.Is(converted) {
+			t.Errorf("Unexpected conversion: %s", converted)
+		}
+	})
+
+	t.Run("Mapping error", func(t *testing.T) {
+		err := items[2].Err()
+		if !errors.Is(err, ErrMapping) {
+			t.Errorf("Expected ErrMapping, got: %s", err)
+		}
+
+		var itemErr BulkIndexerResponseItemError
+		errors.As(err, &itemErr)
+		if len(itemErr.RootCause) != 1 || itemErr.RootCause[0].Type != "illegal_argument_exception" {
+			t.Errorf("Unexpected root cause: %+v", itemErr.RootCause)
+		}
+		if itemErr.Cause.Cause == nil || itemErr.Cause.Cause.Type != "number_format_exception" {
+			t.Errorf("Unexpected nested cause: %+v", itemErr.Cause)
+		}
+
+		want := `status: 400, failed: [document_parsing_exception], reason: [1:10] failed to parse field [count] of type [long] in document with id '3'` +
+			`, caused by: [illegal_argument_exception] For input string: "foo"` +
+			`, caused by: [number_format_exception] For input string: "foo"`
+		if err.Error() != want {
+			t.Errorf("Unexpected error message:\n got: %s\nwant: %s", err, want)
+		}
+
+		ec := itemErr.ElasticsearchError().ErrorCause
+		if ec.CausedBy == nil || ec.CausedBy.CausedBy == nil || ec.CausedBy.CausedBy.Type != "number_format_exception" {
+			t.Errorf("Unexpected converted causes: %+v", ec)
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		if err := items[3].Err(); !errors.Is(err, ErrRejected) {
+			t.Errorf("Expected ErrRejected, got: %s", err)
+		}
+	})
+
+	t.Run("Status without error", func(t *testing.T) {
+		err := BulkIndexerResponseItem{Status: 404}.Err()
+		if err == nil {
+			t.Fatalf("Expected error")
+		}
+		if err.Error() != "status: 404, failed: [not found]" {
+			t.Errorf("Unexpected error message: %s", err)
+		}
+	})
+}
@@ -22,6 +22,7 @@ package esutil
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -40,6 +41,10 @@ import (
 	"time"
 
 	"github.com/elastic/elastic-transport-go/v8/elastictransport"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esapi"
@@ -60,6 +65,43 @@ func (t *mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return t.RoundTripFunc(req)
 }
 
+// mockResponse returns a response from Elasticsearch with the status and body.
+func mockResponse(status int, body string) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: status,
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+	}, nil
+}
+
+type mockInstrumentation struct {
+	mu      sync.Mutex
+	started []string
+	closed  int
+}
+
+func (i *mockInstrumentation) Start(ctx context.Context, name string) context.Context {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.started = append(i.started, name)
+	return ctx
+}
+
+func (i *mockInstrumentation) Close(ctx context.Context) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.closed++
+}
+
+func (i *mockInstrumentation) RecordError(ctx context.Context, err error)                 {}
+func (i *mockInstrumentation) RecordPathPart(ctx context.Context, pathPart, value string) {}
+func (i *mockInstrumentation) RecordRequestBody(ctx context.Context, endpoint string, query io.Reader) io.ReadCloser {
+	return nil
+}
+func (i *mockInstrumentation) BeforeRequest(req *http.Request, endpoint string)        {}
+func (i *mockInstrumentation) AfterRequest(req *http.Request, system, endpoint string) {}
+func (i *mockInstrumentation) AfterResponse(ctx context.Context, res *http.Response)   {}
+
 func TestBulkIndexer(t *testing.T) {
 	t.Run("Basic", func(t *testing.T) {
 		var (
@@ -208,6 +250,633 @@ func TestBulkIndexer(t *testing.T) {
 		}
 	})
 
+	t.Run("Close() Deadline", func(t *testing.T) {
+		unblock := make(chan struct{})
+		defer close(unblock)
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(*http.Request) (*http.Response, error) {
+				<-unblock
+				return &http.Response{Body: ioutil.NopCloser(strings.NewReader(`{}`))}, nil
+			},
+		}})
+		bi, _ := NewBulkIndexer(BulkIndexerConfig{NumWorkers: 1, Client: es})
+
+		bi.Add(context.Background(), BulkIndexerItem{Action: "delete", DocumentID: "1"})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err := bi.Close(ctx); err != context.DeadlineExceeded {
+			t.Errorf("Expected deadline exceeded error, but got: %v", err)
+		}
+	})
+
+	t.Run("Flush()", func(t *testing.T) {
+		var countReqs uint64
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(*http.Request) (*http.Response, error) {
+				atomic.AddUint64(&countReqs, 1)
+				return &http.Response{
+					Body:   ioutil.NopCloser(strings.NewReader(`{"items":[{"index":{"status":201}}]}`)),
+					Header: http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+				}, nil
+			},
+		}})
+		bi, _ := NewBulkIndexer(BulkIndexerConfig{NumWorkers: 2, FlushInterval: time.Hour, Client: es})
+
+		if err := bi.Add(context.Background(), BulkIndexerItem{
+			Action: "index",
+			Body:   strings.NewReader(`{"title":"foo"}`),
+		}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if err := bi.Flush(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if n := atomic.LoadUint64(&countReqs); n != 1 {
+			t.Errorf("Unexpected number of requests: want=1, got=%d", n)
+		}
+		if n := bi.Stats().NumFlushed; n != 1 {
+			t.Errorf("Unexpected NumFlushed: want=1, got=%d", n)
+		}
+
+		if err := bi.Close(context.Background()); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+		if err := bi.Flush(context.Background()); err == nil {
+			t.Errorf("Expected error for Flush() after Close()")
+		}
+	})
+
+	t.Run("Item Future", func(t *testing.T) {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(*http.Request) (*http.Response, error) {
+				return &http.Response{
+					Body: ioutil.NopCloser(strings.NewReader(
+						`{"errors":true,"items":[` +
+							`{"index":{"_id":"1","status":201}},` +
+							`{"create":{"_id":"2","status":409,"error":{"type":"version_conflict_engine_exception","reason":"conflict"}}}]}`)),
+					Header: http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+				}, nil
+			},
+		}})
+		bi, _ := NewBulkIndexer(BulkIndexerConfig{NumWorkers: 1, FlushInterval: time.Hour, Client: es})
+
+		f1, f2 := NewBulkIndexerFuture(), NewBulkIndexerFuture()
+		bi.Add(context.Background(), BulkIndexerItem{Action: "index", DocumentID: "1", Future: f1})
+		bi.Add(context.Background(), BulkIndexerItem{Action: "create", DocumentID: "2", Future: f2})
+
+		select {
+		case <-f1.Done():
+			t.Fatalf("Unexpected completion before flush")
+		default:
+		}
+
+		if err := bi.Flush(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		res, err := f1.Wait(context.Background())
+		if err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+		if res.Response.Status != 201 {
+			t.Errorf("Unexpected status: %d", res.Response.Status)
+		}
+
+		res, err = f2.Wait(context.Background())
+		if err == nil {
+			t.Errorf("Expected error for failed item")
+		}
+		if res.Response.Status != 409 {
+			t.Errorf("Unexpected status: %d", res.Response.Status)
+		}
+
+		bi.Close(context.Background())
+	})
+
+	t.Run("OrderByKey", func(t *testing.T) {
+		var (
+			mu   sync.Mutex
+			seqs = make(map[string][]int)
+		)
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				body, _ := ioutil.ReadAll(req.Body)
+				lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+				for i := 0; i < len(lines); i += 2 {
+					var (
+						meta map[string]struct {
+							ID string `json:"_id"`
+						}
+						doc struct {
+							Seq int `json:"seq"`
+						}
+					)
+					json.Unmarshal([]byte(lines[i]), &meta)
+					json.Unmarshal([]byte(lines[i+1]), &doc)
+					id := meta["update"].ID
+					seqs[id] = append(seqs[id], doc.Seq)
+				}
+				return &http.Response{Body: ioutil.NopCloser(strings.NewReader(`{}`))}, nil
+			},
+		}})
+		bi, _ := NewBulkIndexer(BulkIndexerConfig{
+			NumWorkers: 4,
+			FlushBytes: 200,
+			OrderByKey: true,
+			Client:     es,
+		})
+
+		for seq := 0; seq < 20; seq++ {
+			for id := 0; id < 8; id++ {
+				bi.Add(context.Background(), BulkIndexerItem{
+					Action:     "update",
+					Index:      "test",
+					DocumentID: strconv.Itoa(id),
+					Body:       strings.NewReader(fmt.Sprintf(`{"seq":%d}`, seq)),
+				})
+			}
+		}
+		if err := bi.Close(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if len(seqs) != 8 {
+			t.Fatalf("Unexpected number of documents: %d", len(seqs))
+		}
+		for id, s := range seqs {
+			if len(s) != 20 {
+				t.Errorf("Unexpected number of operations for [%s]: %d", id, len(s))
+			}
+			for i := range s {
+				if s[i] != i {
+					t.Errorf("Unexpected order of operations for [%s]: %v", id, s)
+					break
+				}
+			}
+		}
+	})
+
+	t.Run("CompressRequestBody", func(t *testing.T) {
+		for _, pool := range []bool{false, true} {
+			pool := pool
+
+			t.Run(fmt.Sprintf("PoolCompressor=%v", pool), func(t *testing.T) {
+				var (
+					mu     sync.Mutex
+					bodies []string
+				)
+
+				es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+					RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+						if ce := req.Header.Get("Content-Encoding"); ce != "gzip" {
+							t.Errorf("Unexpected Content-Encoding: %q", ce)
+						}
+						zr, err := gzip.NewReader(req.Body)
+						if err != nil {
+							t.Fatalf("Unexpected error: %s", err)
+						}
+						body, err := ioutil.ReadAll(zr)
+						if err != nil {
+							t.Fatalf("Unexpected error: %s", err)
+						}
+						mu.Lock()
+						bodies = append(bodies, string(body))
+						mu.Unlock()
+						return &http.Response{Body: ioutil.NopCloser(strings.NewReader(`{}`))}, nil
+					},
+				}})
+				bi, _ := NewBulkIndexer(BulkIndexerConfig{
+					NumWorkers:          1,
+					FlushBytes:          100,
+					Client:              es,
+					CompressRequestBody: true,
+					PoolCompressor:      pool,
+				})
+
+				for i := 1; i <= 4; i++ {
+					bi.Add(context.Background(), BulkIndexerItem{
+						Action:     "index",
+						DocumentID: strconv.Itoa(i),
+						Body:       strings.NewReader(fmt.Sprintf(`{"title":"foo-%d"}`, i)),
+					})
+				}
+				if err := bi.Close(context.Background()); err != nil {
+					t.Fatalf("Unexpected error: %s", err)
+				}
+
+				want := `{"index":{"_id":"1"}}` + "\n" + `{"title":"foo-1"}` + "\n" +
+					`{"index":{"_id":"2"}}` + "\n" + `{"title":"foo-2"}` + "\n" +
+					`{"index":{"_id":"3"}}` + "\n" + `{"title":"foo-3"}` + "\n" +
+					`{"index":{"_id":"4"}}` + "\n" + `{"title":"foo-4"}` + "\n"
+				if got := strings.Join(bodies, ""); got != want {
+					t.Errorf("Unexpected body:\n%s", got)
+				}
+				if len(bodies) != 2 {
+					t.Errorf("Unexpected number of requests: want=2, got=%d", len(bodies))
+				}
+			})
+		}
+	})
+
+	t.Run("CompressRequestBody With Client Compression", func(t *testing.T) {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{CompressRequestBody: true, Transport: &mockTransport{}})
+
+		_, err := NewBulkIndexer(BulkIndexerConfig{Client: es, CompressRequestBody: true})
+		if err == nil || !strings.Contains(err.Error(), "can't be combined") {
+			t.Errorf("Expected error for double compression, got: %v", err)
+		}
+
+		if _, err := NewBulkIndexer(BulkIndexerConfig{Client: es}); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	})
+
+	t.Run("Invalid compression level", func(t *testing.T) {
+		_, err := NewBulkIndexer(BulkIndexerConfig{CompressRequestBody: true, CompressRequestBodyLevel: 42})
+		if err == nil {
+			t.Errorf("Expected error for invalid compression level")
+		}
+	})
+
+	t.Run("OnFlushEnd Report", func(t *testing.T) {
+		var (
+			mu      sync.Mutex
+			reports []BulkIndexerFlushReport
+		)
+
+		instrument := &mockInstrumentation{}
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{
+			Transport: &mockTransport{
+				RoundTripFunc: func(*http.Request) (*http.Response, error) {
+					return &http.Response{
+						Body: ioutil.NopCloser(strings.NewReader(
+							`{"took":12,"errors":true,"items":[` +
+								`{"index":{"_id":"1","status":201}},` +
+								`{"create":{"_id":"2","status":409,"error":{"type":"version_conflict_engine_exception","reason":"conflict"}}}]}`)),
+						Header: http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					}, nil
+				},
+			},
+			Instrumentation: instrument,
+		})
+		bi, _ := NewBulkIndexer(BulkIndexerConfig{
+			NumWorkers:    1,
+			Client:        es,
+			MeterProvider: noop.NewMeterProvider(),
+			OnFlushEnd: func(ctx context.Context) {
+				report, ok := BulkIndexerFlushReportFromContext(ctx)
+				if !ok {
+					t.Errorf("Expected report in context")
+					return
+				}
+				mu.Lock()
+				reports = append(reports, report)
+				mu.Unlock()
+			},
+		})
+
+		bi.Add(context.Background(), BulkIndexerItem{Action: "index", DocumentID: "1", Body: strings.NewReader(`{"title":"foo"}`)})
+		bi.Add(context.Background(), BulkIndexerItem{Action: "create", DocumentID: "2", Body: strings.NewReader(`{"title":"bar"}`)})
+		if err := bi.Close(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if len(reports) != 1 {
+			t.Fatalf("Unexpected number of reports: %d", len(reports))
+		}
+		report := reports[0]
+		if report.NumItems != 2 || report.NumFailed != 1 {
+			t.Errorf("Unexpected item counts: items=%d, failed=%d", report.NumItems, report.NumFailed)
+		}
+		if want := len(`{"index":{"_id":"1"}}`+"\n"+`{"title":"foo"}`+"\n") +
+			len(`{"create":{"_id":"2"}}`+"\n"+`{"title":"bar"}`+"\n"); report.NumBytes != want {
+			t.Errorf("Unexpected NumBytes: want=%d, got=%d", want, report.NumBytes)
+		}
+		if report.Took != 12*time.Millisecond {
+			t.Errorf("Unexpected Took: %s", report.Took)
+		}
+		if !reflect.DeepEqual(report.StatusCounts, map[int]int{201: 1, 409: 1}) {
+			t.Errorf("Unexpected StatusCounts: %v", report.StatusCounts)
+		}
+		if !reflect.DeepEqual(report.ErrorCounts, map[string]int{"version_conflict_engine_exception": 1}) {
+			t.Errorf("Unexpected ErrorCounts: %v", report.ErrorCounts)
+		}
+		if report.Err != nil {
+			t.Errorf("Unexpected error: %s", report.Err)
+		}
+
+		if !reflect.DeepEqual(instrument.started, []string{"bulk_indexer.flush"}) {
+			t.Errorf("Unexpected spans: %v", instrument.started)
+		}
+		if instrument.closed != 1 {
+			t.Errorf("Unexpected number of closed spans: %d", instrument.closed)
+		}
+	})
+
+	t.Run("OnFlushEnd Report Request Error", func(t *testing.T) {
+		var report BulkIndexerFlushReport
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(*http.Request) (*http.Response, error) {
+				return nil, fmt.Errorf("Mock transport error")
+			},
+		}})
+		bi, _ := NewBulkIndexer(BulkIndexerConfig{
+			NumWorkers: 1,
+			Client:     es,
+			OnFlushEnd: func(ctx context.Context) { report, _ = BulkIndexerFlushReportFromContext(ctx) },
+		})
+
+		bi.Add(context.Background(), BulkIndexerItem{Action: "index", DocumentID: "1"})
+		bi.Close(context.Background())
+
+		if report.Err == nil {
+			t.Errorf("Expected error in report")
+		}
+		if report.NumItems != 1 || report.NumFailed != 1 {
+			t.Errorf("Unexpected item counts: items=%d, failed=%d", report.NumItems, report.NumFailed)
+		}
+	})
+
+	t.Run("Flush Span Failure Events", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{
+			Transport: &mockTransport{
+				RoundTripFunc: func(*http.Request) (*http.Response, error) {
+					return &http.Response{
+						Body: ioutil.NopCloser(strings.NewReader(
+							`{"took":3,"errors":true,"items":[` +
+								`{"index":{"_index":"test","_id":"1","status":201}},` +
+								`{"create":{"_index":"test","_id":"2","status":409,"error":{"type":"version_conflict_engine_exception","reason":"conflict"}}}]}`)),
+						Header: http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+					}, nil
+				},
+			},
+			Instrumentation: elasticsearch.NewOpenTelemetryInstrumentation(provider, false),
+		})
+		bi, _ := NewBulkIndexer(BulkIndexerConfig{NumWorkers: 1, Client: es, Index: "test"})
+
+		bi.Add(context.Background(), BulkIndexerItem{Action: "index", DocumentID: "1", Body: strings.NewReader(`{"title":"foo"}`)})
+		bi.Add(context.Background(), BulkIndexerItem{Action: "create", DocumentID: "2", Body: strings.NewReader(`{"title":"bar"}`)})
+		if err := bi.Close(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		var span sdktrace.ReadOnlySpan
+		for _, s := range recorder.Ended() {
+			if s.Name() == "bulk_indexer.flush" {
+				span = s
+			}
+		}
+		if span == nil {
+			t.Fatalf("Expected flush span, got: %v", recorder.Ended())
+		}
+
+		attrs := attribute.NewSet(span.Attributes()...)
+		if v, _ := attrs.Value(attrBulkItems); v.AsInt64() != 2 {
+			t.Errorf("Unexpected %s: %v", attrBulkItems, v.Emit())
+		}
+		if v, _ := attrs.Value(attrBulkFailed); v.AsInt64() != 1 {
+			t.Errorf("Unexpected %s: %v", attrBulkFailed, v.Emit())
+		}
+
+		var events []sdktrace.Event
+		for _, e := range span.Events() {
+			if e.Name == "bulk_indexer.item.failure" {
+				events = append(events, e)
+			}
+		}
+		if len(events) != 1 {
+			t.Fatalf("Expected 1 failure event, got: %+v", span.Events())
+		}
+		attrs = attribute.NewSet(events[0].Attributes...)
+		for key, want := range map[attribute.Key]string{
+			"db.elasticsearch.bulk.action": "create",
+			"db.elasticsearch.bulk.index":  "test",
+			"db.elasticsearch.bulk.id":     "2",
+			"db.elasticsearch.bulk.status": "409",
+			"error.type":                   "version_conflict_engine_exception",
+			"error.message":                "conflict",
+		} {
+			if v, _ := attrs.Value(key); v.Emit() != want {
+				t.Errorf("Unexpected event attribute %s: want=%s, got=%s", key, want, v.Emit())
+			}
+		}
+	})
+
+	t.Run("Item Future Request Error", func(t *testing.T) {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(*http.Request) (*http.Response, error) {
+				return nil, fmt.Errorf("Mock transport error")
+			},
+		}})
+		bi, _ := NewBulkIndexer(BulkIndexerConfig{NumWorkers: 1, Client: es})
+
+		f := NewBulkIndexerFuture()
+		bi.Add(context.Background(), BulkIndexerItem{Action: "index", DocumentID: "1", Future: f})
+		bi.Close(context.Background())
+
+		if _, err := f.Wait(context.Background()); err == nil {
+			t.Errorf("Expected error for item in failed request")
+		}
+	})
+
+	t.Run("Item Future Failed Flush Before Write", func(t *testing.T) {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(*http.Request) (*http.Response, error) {
+				return nil, fmt.Errorf("boom")
+			},
+		}})
+		bi, _ := NewBulkIndexer(BulkIndexerConfig{NumWorkers: 1, FlushBytes: 60, FlushInterval: time.Hour, Client: es})
+
+		var failures uint64
+		onFailure := func(context.Context, BulkIndexerItem, BulkIndexerResponseItem, error) {
+			atomic.AddUint64(&failures, 1)
+		}
+
+		f1, f2 := NewBulkIndexerFuture(), NewBulkIndexerFuture()
+		bi.Add(context.Background(), BulkIndexerItem{Action: "index", DocumentID: "1", Body: strings.NewReader(`{"title":"foo"}`), Future: f1})
+		bi.Add(context.Background(), BulkIndexerItem{Action: "index", DocumentID: "2", Body: strings.NewReader(`{"title":"bar"}`), Future: f2, OnFailure: onFailure})
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if _, err := f1.Wait(ctx); err == nil || !strings.Contains(err.Error(), "boom") {
+			t.Errorf("Expected flush error for the first item, got: %v", err)
+		}
+		if _, err := f2.Wait(ctx); err == nil || !strings.Contains(err.Error(), "boom") {
+			t.Errorf("Expected flush error for the second item, got: %v", err)
+		}
+		bi.Close(context.Background())
+
+		if n := atomic.LoadUint64(&failures); n != 1 {
+			t.Errorf("Expected OnFailure for the second item, got %d calls", n)
+		}
+		if stats := bi.Stats(); stats.NumAdded != 2 || stats.NumFailed != 2 {
+			t.Errorf("Unexpected stats: %+v", stats)
+		}
+	})
+
+	t.Run("Add() After Close", func(t *testing.T) {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{}})
+		bi, _ := NewBulkIndexer(BulkIndexerConfig{NumWorkers: 1, Client: es})
+		bi.Close(context.Background())
+
+		err := bi.Add(context.Background(), BulkIndexerItem{Action: "index", Body: strings.NewReader(`{"title":"foo"}`)})
+		if err == nil || !strings.Contains(err.Error(), "closed") {
+			t.Errorf("Expected error for Add after Close, got: %v", err)
+		}
+		if err := bi.Flush(context.Background()); err == nil {
+			t.Errorf("Expected error for Flush after Close")
+		}
+	})
+
+	t.Run("OnFailure Re-Add During Flush", func(t *testing.T) {
+		var calls uint64
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(*http.Request) (*http.Response, error) {
+				status := 429
+				if atomic.AddUint64(&calls, 1) > 1 {
+					status = 201
+				}
+				return &http.Response{
+					Body:   ioutil.NopCloser(strings.NewReader(fmt.Sprintf(`{"items":[{"index":{"_id":"1","status":%d}}]}`, status))),
+					Header: http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+				}, nil
+			},
+		}})
+		bi, _ := NewBulkIndexer(BulkIndexerConfig{NumWorkers: 1, FlushInterval: time.Hour, Client: es})
+
+		var (
+			retried   uint64
+			onFailure func(context.Context, BulkIndexerItem, BulkIndexerResponseItem, error)
+		)
+		onFailure = func(ctx context.Context, item BulkIndexerItem, res BulkIndexerResponseItem, err error) {
+			atomic.AddUint64(&retried, 1)
+			item.Body.Seek(0, io.SeekStart)
+			item.OnFailure = nil
+			if err := bi.Add(ctx, item); err != nil {
+				t.Errorf("Unexpected error: %s", err)
+			}
+		}
+		bi.Add(context.Background(), BulkIndexerItem{Action: "index", DocumentID: "1", Body: strings.NewReader(`{"title":"foo"}`), OnFailure: onFailure})
+
+		done := make(chan error, 1)
+		go func() {
+			if err := bi.Flush(context.Background()); err != nil {
+				done <- err
+				return
+			}
+			done <- bi.Close(context.Background())
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Flush and Close blocked by an OnFailure callback calling Add")
+		}
+
+		if n := atomic.LoadUint64(&retried); n != 1 {
+			t.Errorf("Expected 1 retry, got %d", n)
+		}
+		if stats := bi.Stats(); stats.NumAdded != 2 || stats.NumIndexed != 1 || stats.NumRequests != 2 {
+			t.Errorf("Unexpected stats: %+v", stats)
+		}
+	})
+
+	t.Run("Missing Item In Response", func(t *testing.T) {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(*http.Request) (*http.Response, error) {
+				return &http.Response{
+					Body:   ioutil.NopCloser(strings.NewReader(`{"items":[{"index":{"_id":"1","status":201}}]}`)),
+					Header: http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+				}, nil
+			},
+		}})
+
+		var report BulkIndexerFlushReport
+		bi, _ := NewBulkIndexer(BulkIndexerConfig{
+			NumWorkers: 1,
+			Client:     es,
+			OnFlushEnd: func(ctx context.Context) { report, _ = BulkIndexerFlushReportFromContext(ctx) },
+		})
+
+		var failed []string
+		f := NewBulkIndexerFuture()
+		bi.Add(context.Background(), BulkIndexerItem{Action: "index", DocumentID: "1", Body: strings.NewReader(`{}`)})
+		bi.Add(context.Background(), BulkIndexerItem{
+			Action:     "index",
+			DocumentID: "2",
+			Body:       strings.NewReader(`{}`),
+			Future:     f,
+			OnFailure: func(ctx context.Context, item BulkIndexerItem, res BulkIndexerResponseItem, err error) {
+				failed = append(failed, fmt.Sprintf("%s: %s", item.DocumentID, err))
+			},
+		})
+		bi.Close(context.Background())
+
+		if fmt.Sprint(failed) != "[2: flush: missing item in response]" {
+			t.Errorf("Unexpected failures: %v", failed)
+		}
+		if _, err := f.Wait(context.Background()); err == nil {
+			t.Errorf("Expected error for the missing item")
+		}
+		if stats := bi.Stats(); stats.NumFailed != 1 || stats.NumIndexed != 1 {
+			t.Errorf("Unexpected stats: %+v", stats)
+		}
+		if report.NumItems != 2 || report.NumFailed != 1 {
+			t.Errorf("Unexpected report: %+v", report)
+		}
+	})
+
+	t.Run("Flush() Cancel Continues In Background", func(t *testing.T) {
+		reqErr := make(chan error, 1)
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				time.Sleep(300 * time.Millisecond)
+				reqErr <- req.Context().Err()
+				return &http.Response{
+					Body:   ioutil.NopCloser(strings.NewReader(`{"items":[{"index":{"status":201}}]}`)),
+					Header: http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+				}, nil
+			},
+		}})
+		bi, _ := NewBulkIndexer(BulkIndexerConfig{NumWorkers: 1, FlushInterval: time.Hour, Client: es})
+
+		f := NewBulkIndexerFuture()
+		bi.Add(context.Background(), BulkIndexerItem{Action: "index", Body: strings.NewReader(`{"title":"foo"}`), Future: f})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err := bi.Flush(ctx); err != context.DeadlineExceeded {
+			t.Errorf("Expected deadline exceeded error, but got: %v", err)
+		}
+
+		if err := <-reqErr; err != nil {
+			t.Errorf("Unexpected canceled request: %s", err)
+		}
+		if _, err := f.Wait(context.Background()); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+		bi.Close(context.Background())
+
+		if stats := bi.Stats(); stats.NumFlushed != 1 || stats.NumFailed != 0 {
+			t.Errorf("Unexpected stats: %+v", stats)
+		}
+	})
+
 	t.Run("Indexer Callback", func(t *testing.T) {
 		esCfg := elasticsearch.Config{
 			Transport: &mockTransport{
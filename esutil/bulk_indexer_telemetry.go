@@ -0,0 +1,142 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+const meterName = "github.com/elastic/go-elasticsearch/v8/esutil"
+
+// Attributes recorded on the flush span and the metrics.
+const (
+	attrBulkItems   = "db.elasticsearch.bulk.items"
+	attrBulkFailed  = "db.elasticsearch.bulk.failed"
+	attrBulkBytes   = "db.elasticsearch.bulk.bytes"
+	attrBulkTook    = "db.elasticsearch.bulk.took"
+	attrBulkOutcome = "db.elasticsearch.bulk.outcome"
+)
+
+// bulkIndexerMetrics holds the OpenTelemetry instruments of the indexer.
+type bulkIndexerMetrics struct {
+	items    metric.Int64Counter
+	duration metric.Float64Histogram
+	size     metric.Int64Histogram
+}
+
+// newBulkIndexerMetrics creates the instruments with a meter from the provider.
+func newBulkIndexerMetrics(provider metric.MeterProvider) (*bulkIndexerMetrics, error) {
+	var (
+		m   bulkIndexerMetrics
+		err error
+	)
+
+	meter := provider.Meter(meterName, metric.WithInstrumentationVersion(elasticsearch.Version))
+
+	m.items, err = meter.Int64Counter(
+		"elasticsearch.bulk_indexer.items",
+		metric.WithDescription("The number of items flushed by the bulk indexer."),
+		metric.WithUnit("{item}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.duration, err = meter.Float64Histogram(
+		"elasticsearch.bulk_indexer.flush.duration",
+		metric.WithDescription("The duration of the bulk indexer flushes."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.size, err = meter.Int64Histogram(
+		"elasticsearch.bulk_indexer.flush.size",
+		metric.WithDescription("The uncompressed size of the bulk indexer requests."),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// flushReportKey is the context key of the flush report.
+type flushReportKey struct{}
+
+// BulkIndexerFlushReportFromContext returns the report of the flush
+// from the context passed to OnFlushEnd.
+//
+// It returns false when the flush didn't send a request, eg. with an empty buffer.
+func BulkIndexerFlushReportFromContext(ctx context.Context) (BulkIndexerFlushReport, bool) {
+	report, ok := ctx.Value(flushReportKey{}).(BulkIndexerFlushReport)
+	return report, ok
+}
+
+// report records the flush report on the span and the metrics,
+// and returns the context for the OnFlushEnd callback, with the report.
+func (w *worker) report(ctx context.Context, report BulkIndexerFlushReport) context.Context {
+	if instr := w.bi.config.Client.InstrumentationEnabled(); instr != nil && report.Err != nil {
+		instr.RecordError(ctx, report.Err)
+	}
+
+	if span := trace.SpanFromContext(ctx); span.IsRecording() {
+		span.SetAttributes(
+			attribute.Int(attrBulkItems, report.NumItems),
+			attribute.Int(attrBulkFailed, report.NumFailed),
+			attribute.Int(attrBulkBytes, report.NumBytes),
+			attribute.Int64(attrBulkTook, report.Took.Milliseconds()),
+		)
+	}
+
+	if m := w.bi.metrics; m != nil {
+		m.items.Add(ctx, int64(report.NumItems-report.NumFailed),
+			metric.WithAttributes(attribute.String(attrBulkOutcome, "success")))
+		m.items.Add(ctx, int64(report.NumFailed),
+			metric.WithAttributes(attribute.String(attrBulkOutcome, "failure")))
+		m.duration.Record(ctx, report.Duration.Seconds())
+		m.size.Record(ctx, int64(report.NumBytes))
+	}
+
+	return context.WithValue(ctx, flushReportKey{}, report)
+}
+
+// recordFailure adds an event for the failed item to the flush span.
+func (w *worker) recordFailure(ctx context.Context, item BulkIndexerItem, info BulkIndexerResponseItem) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	span.AddEvent("bulk_indexer.item.failure", trace.WithAttributes(
+		attribute.String("db.elasticsearch.bulk.action", item.Action),
+		attribute.String("db.elasticsearch.bulk.index", info.Index),
+		attribute.String("db.elasticsearch.bulk.id", info.DocumentID),
+		attribute.Int("db.elasticsearch.bulk.status", info.Status),
+		attribute.String("error.type", info.Error.Type),
+		attribute.String("error.message", info.Error.Reason),
+	))
+}
@@ -0,0 +1,295 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package ccs reports whether the results of a search, eg. across clusters, are complete.
+//
+// With cross-cluster search, a remote cluster configured with skip_unavailable
+// is skipped when it fails, and shards can fail or time out, while the response
+// is successful. The Completeness of a response tells which clusters and shards
+// failed, and why:
+//
+//	res, err := es.Search().Index("logs-*,remote1:logs-*").Request(req).Do(ctx)
+//	if err != nil { ... }
+//	if c := ccs.Inspect(res); !c.Complete {
+//		log.Printf("partial results: %s", c)
+//	}
+//
+// Strict consumers turn partial results into a *PartialResultsError:
+//
+//	res, err := ccs.Strict(es.Search().Index("logs-*,remote1:logs-*").Request(req).Do(ctx))
+package ccs
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/clustersearchstatus"
+)
+
+// LocalCluster is the alias of the local cluster in the cluster details.
+const LocalCluster = "(local)"
+
+// Completeness represents the completeness of the results of a search.
+type Completeness struct {
+	// Complete is true when every cluster and every shard returned its results in time.
+	Complete bool
+	TimedOut bool
+
+	// The shard statistics over all clusters.
+	TotalShards      uint
+	SuccessfulShards uint
+	SkippedShards    uint // The shards skipped as they cannot match, which is not a failure.
+	FailedShards     uint
+
+	// Clusters contains the status of every cluster, sorted by alias;
+	// it is empty when the search did not involve a remote cluster.
+	Clusters []Cluster
+
+	// Failures contains the failures of the shards and of the clusters.
+	Failures []Failure
+}
+
+// Cluster represents the status of a cluster in a cross-cluster search.
+type Cluster struct {
+	Alias    string
+	Indices  string
+	Status   clustersearchstatus.ClusterSearchStatus
+	TimedOut bool
+
+	TotalShards      uint
+	SuccessfulShards uint
+	SkippedShards    uint
+	FailedShards     uint
+}
+
+// Complete returns true when the cluster returned all of its results.
+func (c Cluster) Complete() bool {
+	return c.Status == clustersearchstatus.Successful && !c.TimedOut
+}
+
+// Failure represents the failure of a shard, or of a cluster as a whole, eg. when it is skipped.
+type Failure struct {
+	Cluster string // The alias of the cluster; empty for the local cluster of a search without remotes.
+	Index   string
+	Shard   int // The shard number, or -1 for the failure of a cluster.
+	Node    string
+	Type    string
+	Reason  string
+}
+
+// String returns a readable description of the failure.
+func (f Failure) String() string {
+	var b strings.Builder
+	if f.Cluster != "" && f.Cluster != LocalCluster {
+		b.WriteString(f.Cluster + ":")
+	}
+	b.WriteString(f.Index)
+	if f.Shard >= 0 {
+		fmt.Fprintf(&b, "[%d]", f.Shard)
+	}
+	fmt.Fprintf(&b, ": %s: %s", f.Type, f.Reason)
+	return b.String()
+}
+
+// Inspect returns the completeness of the search response.
+func Inspect(res *search.Response) *Completeness {
+	return InspectStats(res.Clusters_, res.Shards_, res.TimedOut)
+}
+
+// InspectStats returns the completeness of a response with the cluster and shard statistics,
+// eg. the items of a multi search or the response of a search template.
+func InspectStats(clusters *types.ClusterStatistics, shards types.ShardStatistics, timedOut bool) *Completeness {
+	c := Completeness{
+		TimedOut:         timedOut,
+		TotalShards:      shards.Total,
+		SuccessfulShards: shards.Successful,
+		FailedShards:     shards.Failed,
+	}
+	if shards.Skipped != nil {
+		c.SkippedShards = *shards.Skipped
+	}
+
+	seen := make(map[string]bool)
+	addFailure := func(cluster string, f types.ShardFailure) {
+		failure := newFailure(cluster, f)
+		key := fmt.Sprintf("%s/%s/%d/%s", failure.Cluster, failure.Index, failure.Shard, failure.Type)
+		if !seen[key] {
+			seen[key] = true
+			c.Failures = append(c.Failures, failure)
+		}
+	}
+
+	// The failures of the local cluster are reported without prefix.
+	var local string
+	if clusters != nil && len(clusters.Details) > 0 {
+		local = LocalCluster
+	}
+	for _, f := range shards.Failures {
+		addFailure(local, f)
+	}
+
+	incomplete := c.TimedOut || c.FailedShards > 0
+	if clusters != nil {
+		aliases := make([]string, 0, len(clusters.Details))
+		for alias := range clusters.Details {
+			aliases = append(aliases, alias)
+		}
+		sort.Strings(aliases)
+
+		for _, alias := range aliases {
+			d := clusters.Details[alias]
+			cl := Cluster{Alias: alias, Indices: d.Indices, Status: d.Status, TimedOut: d.TimedOut}
+			if d.Shards_ != nil {
+				cl.TotalShards = d.Shards_.Total
+				cl.SuccessfulShards = d.Shards_.Successful
+				cl.FailedShards = d.Shards_.Failed
+				if d.Shards_.Skipped != nil {
+					cl.SkippedShards = *d.Shards_.Skipped
+				}
+			}
+			c.Clusters = append(c.Clusters, cl)
+
+			for _, f := range d.Failures {
+				addFailure(alias, f)
+			}
+			if !cl.Complete() {
+				incomplete = true
+			}
+		}
+
+		// The details are missing with older versions of Elasticsearch.
+		if clusters.Skipped+clusters.Failed+clusters.Partial+clusters.Running > 0 {
+			incomplete = true
+		}
+	}
+
+	sort.SliceStable(c.Failures, func(i, j int) bool {
+		a, b := c.Failures[i], c.Failures[j]
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		if a.Index != b.Index {
+			return a.Index < b.Index
+		}
+		return a.Shard < b.Shard
+	})
+
+	c.Complete = !incomplete
+	return &c
+}
+
+// Incomplete returns the clusters which did not return all of their results.
+func (c *Completeness) Incomplete() []Cluster {
+	var clusters []Cluster
+	for _, cl := range c.Clusters {
+		if !cl.Complete() {
+			clusters = append(clusters, cl)
+		}
+	}
+	return clusters
+}
+
+// Err returns a *PartialResultsError when the results are not complete, or nil.
+func (c *Completeness) Err() error {
+	if c.Complete {
+		return nil
+	}
+	return &PartialResultsError{Completeness: c}
+}
+
+// String returns a summary of the completeness.
+func (c *Completeness) String() string {
+	if c.Complete {
+		return "complete"
+	}
+
+	var parts []string
+	if c.TimedOut {
+		parts = append(parts, "timed out")
+	}
+	for _, cl := range c.Incomplete() {
+		s := fmt.Sprintf("cluster [%s] %s", cl.Alias, cl.Status)
+		if cl.TimedOut {
+			s += " (timed out)"
+		}
+		parts = append(parts, s)
+	}
+	if c.FailedShards > 0 {
+		parts = append(parts, fmt.Sprintf("%d of %d shards failed", c.FailedShards, c.TotalShards))
+	}
+	if len(c.Failures) > 0 {
+		parts = append(parts, c.Failures[0].String())
+		if len(c.Failures) > 1 {
+			parts = append(parts, fmt.Sprintf("and %d more failures", len(c.Failures)-1))
+		}
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "incomplete")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// PartialResultsError is returned by Strict for the partial results of a search.
+type PartialResultsError struct {
+	Completeness *Completeness
+}
+
+// Error implements the error interface.
+func (e *PartialResultsError) Error() string {
+	return "ccs: partial results: " + e.Completeness.String()
+}
+
+// Strict returns a *PartialResultsError for the partial results of a search,
+// along with the response. It wraps the call of the search:
+//
+//	res, err := ccs.Strict(es.Search().Index(index).Request(req).Do(ctx))
+func Strict(res *search.Response, err error) (*search.Response, error) {
+	if err != nil {
+		return res, err
+	}
+	if res == nil {
+		return nil, errors.New("ccs: missing response")
+	}
+	return res, Inspect(res).Err()
+}
+
+// newFailure converts a shard failure of the cluster.
+func newFailure(cluster string, f types.ShardFailure) Failure {
+	failure := Failure{Cluster: cluster, Shard: f.Shard, Type: f.Reason.Type}
+	if f.Index != nil {
+		failure.Index = *f.Index
+	}
+	if f.Node != nil {
+		failure.Node = *f.Node
+	}
+	if f.Reason.Reason != nil {
+		failure.Reason = *f.Reason.Reason
+	}
+	if f.Reason.CausedBy != nil && f.Reason.CausedBy.Reason != nil {
+		failure.Reason += ": " + *f.Reason.CausedBy.Reason
+	}
+
+	// The indices of the remote clusters are prefixed with their alias.
+	if alias, index, ok := strings.Cut(failure.Index, ":"); ok {
+		failure.Cluster, failure.Index = alias, index
+	}
+	return failure
+}
@@ -0,0 +1,159 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package ccs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
+)
+
+const partialResponse = `{"took":10,"timed_out":false,
+	"_shards":{"total":6,"successful":4,"skipped":1,"failed":1,"failures":[
+		{"shard":2,"index":"remote1:logs-1","node":"n1","reason":{"type":"query_shard_exception","reason":"failed to create query","caused_by":{"type":"number_format_exception","reason":"For input string: \"x\""}}}]},
+	"_clusters":{"total":3,"successful":1,"skipped":1,"running":0,"partial":1,"failed":0,"details":{
+		"(local)":{"status":"successful","indices":"logs-*","took":5,"timed_out":false,"_shards":{"total":3,"successful":3,"skipped":1,"failed":0}},
+		"remote1":{"status":"partial","indices":"logs-*","took":8,"timed_out":false,"_shards":{"total":3,"successful":1,"skipped":0,"failed":1},"failures":[
+			{"shard":2,"index":"remote1:logs-1","node":"n1","reason":{"type":"query_shard_exception","reason":"failed to create query","caused_by":{"type":"number_format_exception","reason":"For input string: \"x\""}}}]},
+		"remote2":{"status":"skipped","indices":"logs-*","timed_out":false,"failures":[
+			{"shard":-1,"index":null,"reason":{"type":"connect_transport_exception","reason":"unable to connect to remote2"}}]}}},
+	"hits":{"hits":[]}}`
+
+const completeResponse = `{"took":10,"timed_out":false,
+	"_shards":{"total":2,"successful":2,"skipped":0,"failed":0},
+	"_clusters":{"total":2,"successful":2,"skipped":0,"running":0,"partial":0,"failed":0,"details":{
+		"(local)":{"status":"successful","indices":"logs-*","timed_out":false,"_shards":{"total":1,"successful":1,"skipped":0,"failed":0}},
+		"remote1":{"status":"successful","indices":"logs-*","timed_out":false,"_shards":{"total":1,"successful":1,"skipped":0,"failed":0}}}},
+	"hits":{"hits":[]}}`
+
+func decodeResponse(t *testing.T, body string) *search.Response {
+	t.Helper()
+	res := search.NewResponse()
+	if err := json.Unmarshal([]byte(body), res); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	return res
+}
+
+func TestInspect(t *testing.T) {
+	t.Run("Partial", func(t *testing.T) {
+		c := Inspect(decodeResponse(t, partialResponse))
+
+		if c.Complete || c.TimedOut {
+			t.Errorf("Unexpected completeness: %+v", c)
+		}
+		if c.TotalShards != 6 || c.FailedShards != 1 || c.SkippedShards != 1 {
+			t.Errorf("Unexpected shards: %+v", c)
+		}
+		if len(c.Clusters) != 3 || c.Clusters[0].Alias != LocalCluster || c.Clusters[1].FailedShards != 1 {
+			t.Errorf("Unexpected clusters: %+v", c.Clusters)
+		}
+
+		var incomplete []string
+		for _, cl := range c.Incomplete() {
+			incomplete = append(incomplete, cl.Alias+"="+cl.Status.String())
+		}
+		if fmt.Sprint(incomplete) != "[remote1=partial remote2=skipped]" {
+			t.Errorf("Unexpected incomplete clusters: %v", incomplete)
+		}
+
+		// The failure of remote1 is reported in the shards and in the cluster details.
+		if len(c.Failures) != 2 {
+			t.Fatalf("Unexpected failures: %+v", c.Failures)
+		}
+		if s := c.Failures[0].String(); s != `remote1:logs-1[2]: query_shard_exception: failed to create query: For input string: "x"` {
+			t.Errorf("Unexpected failure: %s", s)
+		}
+		if f := c.Failures[1]; f.Cluster != "remote2" || f.Shard != -1 || f.Type != "connect_transport_exception" {
+			t.Errorf("Unexpected failure: %+v", f)
+		}
+
+		if s := c.String(); !strings.HasPrefix(s, "cluster [remote1] partial, cluster [remote2] skipped, 1 of 6 shards failed") {
+			t.Errorf("Unexpected summary: %s", s)
+		}
+	})
+
+	t.Run("Complete", func(t *testing.T) {
+		c := Inspect(decodeResponse(t, completeResponse))
+		if !c.Complete || len(c.Clusters) != 2 || len(c.Failures) != 0 || c.Err() != nil {
+			t.Errorf("Unexpected completeness: %+v", c)
+		}
+	})
+
+	t.Run("Local shard failures", func(t *testing.T) {
+		c := Inspect(decodeResponse(t, `{"took":1,"timed_out":true,"_shards":{"total":2,"successful":1,"failed":1,"failures":[
+			{"shard":0,"index":"logs-1","reason":{"type":"es_rejected_execution_exception","reason":"rejected"}}]},"hits":{"hits":[]}}`))
+		if c.Complete || !c.TimedOut || len(c.Clusters) != 0 {
+			t.Errorf("Unexpected completeness: %+v", c)
+		}
+		if len(c.Failures) != 1 || c.Failures[0].Cluster != "" || c.Failures[0].String() != "logs-1[0]: es_rejected_execution_exception: rejected" {
+			t.Errorf("Unexpected failures: %+v", c.Failures)
+		}
+	})
+}
+
+// mockCluster responds to every request with the body.
+type mockCluster struct {
+	body string
+}
+
+func (m *mockCluster) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(strings.NewReader(m.body)),
+		Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+	}, nil
+}
+
+func TestStrict(t *testing.T) {
+	t.Run("Partial", func(t *testing.T) {
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: &mockCluster{body: partialResponse}})
+
+		res, err := Strict(es.Search().Index("logs-*,remote1:logs-*,remote2:logs-*").Do(context.Background()))
+		var perr *PartialResultsError
+		if !errors.As(err, &perr) {
+			t.Fatalf("Expected partial results error, got: %v", err)
+		}
+		if res == nil || len(perr.Completeness.Failures) != 2 {
+			t.Errorf("Unexpected result: %v, %+v", res, perr.Completeness)
+		}
+		if !strings.HasPrefix(err.Error(), "ccs: partial results: cluster [remote1] partial") {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	})
+
+	t.Run("Complete", func(t *testing.T) {
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: &mockCluster{body: completeResponse}})
+
+		res, err := Strict(es.Search().Index("logs-*,remote1:logs-*").Do(context.Background()))
+		if err != nil || res == nil {
+			t.Errorf("Unexpected result: %v, %v", res, err)
+		}
+	})
+}
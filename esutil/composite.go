@@ -0,0 +1,392 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/elastic/elastic-transport-go/v8/elastictransport"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/sortorder"
+)
+
+// compositeName is the name of the composite aggregation in the search requests.
+const compositeName = "composite"
+
+// CompositeConfig represents configuration of the composite aggregation scan.
+type CompositeConfig struct {
+	Index []string     // The indices to aggregate. Required.
+	Query *types.Query // The query of the documents to aggregate. Defaults to all documents.
+
+	// Aggregation is the composite aggregation, whose sources are required.
+	// Its size is the number of buckets per page, defaulting to 1000.
+	// Its after key resumes the scan after a bucket, eg. with the last
+	// key passed to OnPage; it is only meaningful with a single partition.
+	Aggregation types.CompositeAggregation
+
+	// Aggregations are the sub-aggregations computed for every bucket.
+	Aggregations map[string]types.Aggregations
+
+	// Partitions is the number of partitions scanned in parallel. Defaults to 1.
+	//
+	// The partitions are ranges of the values of the leading source, which must be
+	// a terms, histogram or date_histogram source on a field, without missing bucket.
+	// The values of the leading source are read first, and split into ranges
+	// of about the same number of documents.
+	//
+	// Reading the values is a full composite aggregation scan of the leading source,
+	// before any bucket is returned, and all the values are held in memory.
+	// Use partitions only when the sub-aggregations cost much more than that scan,
+	// and the leading source has a moderate number of values.
+	Partitions int
+
+	// OnPage is called with the after key of every page, once all its buckets
+	// have been passed to the callback, eg. to save a checkpoint.
+	// It is called concurrently with more than one partition.
+	OnPage func(ctx context.Context, partition int, after types.CompositeAggregateKey)
+}
+
+// CompositeBucket represents a single bucket of the composite aggregation scan.
+type CompositeBucket struct {
+	types.CompositeBucket
+
+	Partition int // The partition which returned the bucket
+}
+
+// ScanComposite pages through all buckets of a composite aggregation, and calls fn
+// for every bucket. With more than one partition, fn is called concurrently
+// from a goroutine per partition.
+//
+// The scan stops at the first error returned by fn or by Elasticsearch,
+// or when the context is done.
+//
+// The buckets can feed a BulkIndexer, eg. to build a rollup index, with IDs
+// derived from the keys, so that repeated runs overwrite the same documents:
+//
+//	err := esutil.ScanComposite(ctx, es, cfg, func(ctx context.Context, b esutil.CompositeBucket) error {
+//		doc, _ := json.Marshal(rollup(b))
+//		return bi.Add(ctx, esutil.BulkIndexerItem{
+//			Action:     "index",
+//			DocumentID: esutil.CompositeKeyID(b.Key),
+//			Body:       bytes.NewReader(doc),
+//		})
+//	})
+//
+// The client is a *elasticsearch.TypedClient or a *elasticsearch.Client.
+func ScanComposite(ctx context.Context, client elastictransport.Interface, cfg CompositeConfig, fn func(context.Context, CompositeBucket) error) error {
+	if len(cfg.Index) == 0 {
+		return errors.New("composite: index is required")
+	}
+	if len(cfg.Aggregation.Sources) == 0 {
+		return errors.New("composite: sources are required")
+	}
+	if cfg.Aggregation.Size == nil {
+		size := 1000
+		cfg.Aggregation.Size = &size
+	}
+	if cfg.Partitions == 0 {
+		cfg.Partitions = 1
+	}
+	if cfg.Partitions > 1 && len(cfg.Aggregation.After) > 0 {
+		return errors.New("composite: after key cannot be used with partitions")
+	}
+
+	c := compositeScanner{client: client, cfg: cfg, fn: fn}
+	return c.run(ctx)
+}
+
+// CompositeKeyID returns a stable document ID for the key of a bucket.
+func CompositeKeyID(key types.CompositeAggregateKey) string {
+	names := sortedKeys(key)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s=%v", name, key[name])
+	}
+	return fmt.Sprintf("%x", sha1.Sum([]byte(strings.Join(parts, "\x00"))))
+}
+
+// compositeScanner represents a single composite aggregation scan.
+type compositeScanner struct {
+	client elastictransport.Interface
+	cfg    CompositeConfig
+	fn     func(context.Context, CompositeBucket) error
+}
+
+// run scans the partitions.
+func (c *compositeScanner) run(ctx context.Context) (err error) {
+	queries := []*types.Query{c.cfg.Query}
+	if c.cfg.Partitions > 1 {
+		if queries, err = c.partitions(ctx); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+	)
+	for i, q := range queries {
+		wg.Add(1)
+		go func(partition int, q *types.Query) {
+			defer wg.Done()
+			if perr := c.scanPartition(ctx, partition, q); perr != nil {
+				once.Do(func() {
+					err = perr
+					cancel()
+				})
+			}
+		}(i, q)
+	}
+	wg.Wait()
+
+	return err
+}
+
+// scanPartition pages through the buckets of the documents matching the query.
+func (c *compositeScanner) scanPartition(ctx context.Context, partition int, query *types.Query) error {
+	agg := c.cfg.Aggregation
+
+	for {
+		comp, err := c.page(ctx, query, agg)
+		if err != nil {
+			return err
+		}
+
+		var buckets []types.CompositeBucket
+		switch b := comp.Buckets.(type) {
+		case []types.CompositeBucket:
+			buckets = b
+		case map[string]types.CompositeBucket:
+			for _, k := range sortedKeys(b) {
+				buckets = append(buckets, b[k])
+			}
+		}
+
+		for _, b := range buckets {
+			if err := c.fn(ctx, CompositeBucket{CompositeBucket: b, Partition: partition}); err != nil {
+				return err
+			}
+		}
+
+		if len(comp.AfterKey) == 0 || len(buckets) == 0 {
+			return nil
+		}
+		if c.cfg.OnPage != nil {
+			c.cfg.OnPage(ctx, partition, comp.AfterKey)
+		}
+		if len(buckets) < *agg.Size {
+			return nil
+		}
+		agg.After = comp.AfterKey
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// page returns a single page of the composite aggregation.
+func (c *compositeScanner) page(ctx context.Context, query *types.Query, agg types.CompositeAggregation) (*types.CompositeAggregate, error) {
+	size := 0
+	req := search.Request{
+		Size:           &size,
+		Query:          query,
+		TrackTotalHits: false,
+		Aggregations: map[string]types.Aggregations{
+			compositeName: {Composite: &agg, Aggregations: c.cfg.Aggregations},
+		},
+	}
+
+	res, err := search.NewSearchFunc(c.client)().Index(strings.Join(c.cfg.Index, ",")).Request(&req).Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("composite: search: %w", err)
+	}
+
+	comp, ok := res.Aggregations[compositeName].(*types.CompositeAggregate)
+	if !ok {
+		return nil, fmt.Errorf("composite: search: unexpected aggregate %T", res.Aggregations[compositeName])
+	}
+	return comp, nil
+}
+
+// partitions reads the values of the leading source, and returns a query
+// per range of values with about the same number of documents.
+func (c *compositeScanner) partitions(ctx context.Context) ([]*types.Query, error) {
+	if len(c.cfg.Aggregation.Sources[0]) != 1 {
+		return nil, errors.New("composite: partitions: unexpected leading source")
+	}
+
+	var (
+		name   string
+		source types.CompositeAggregationSource
+	)
+	for name, source = range c.cfg.Aggregation.Sources[0] {
+	}
+
+	var (
+		field   *string
+		missing *bool
+		order   *sortorder.SortOrder
+		format  string
+	)
+	switch {
+	case source.Terms != nil:
+		field, missing, order = source.Terms.Field, source.Terms.MissingBucket, source.Terms.Order
+	case source.Histogram != nil:
+		field, missing, order = source.Histogram.Field, source.Histogram.MissingBucket, source.Histogram.Order
+	case source.DateHistogram != nil:
+		field, missing, order = source.DateHistogram.Field, source.DateHistogram.MissingBucket, source.DateHistogram.Order
+		format = "epoch_millis"
+	default:
+		return nil, fmt.Errorf("composite: partitions: unsupported leading source [%s]", name)
+	}
+	if field == nil {
+		return nil, fmt.Errorf("composite: partitions: leading source [%s] has no field", name)
+	}
+	if missing != nil && *missing {
+		return nil, fmt.Errorf("composite: partitions: leading source [%s] has a missing bucket", name)
+	}
+
+	// Read the values of the leading source, with their number of documents.
+	var (
+		keys   []types.FieldValue
+		counts []int64
+		total  int64
+	)
+	lead := compositeScanner{
+		client: c.client,
+		cfg: CompositeConfig{
+			Index: c.cfg.Index,
+			Query: c.cfg.Query,
+			Aggregation: types.CompositeAggregation{
+				Sources: c.cfg.Aggregation.Sources[:1],
+				Size:    c.cfg.Aggregation.Size,
+			},
+			Partitions: 1,
+		},
+		fn: func(ctx context.Context, b CompositeBucket) error {
+			keys = append(keys, b.Key[name])
+			counts = append(counts, b.DocCount)
+			total += b.DocCount
+			return nil
+		},
+	}
+	if err := lead.run(ctx); err != nil {
+		return nil, err
+	}
+
+	if order != nil && *order == sortorder.Desc {
+		for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
+			keys[i], keys[j] = keys[j], keys[i]
+			counts[i], counts[j] = counts[j], counts[i]
+		}
+	}
+
+	// Split the ascending values where the running count reaches the next share of the total.
+	var (
+		bounds []types.FieldValue
+		sum    int64
+		n      = int64(c.cfg.Partitions)
+	)
+	for i, count := range counts {
+		if i > 0 && sum*n >= total*int64(len(bounds)+1) {
+			bounds = append(bounds, keys[i])
+		}
+		sum += count
+	}
+
+	queries := make([]*types.Query, 0, len(bounds)+1)
+	for i := 0; i <= len(bounds); i++ {
+		r := make(map[string]interface{})
+		if i > 0 {
+			r["gte"] = bounds[i-1]
+		}
+		if i < len(bounds) {
+			r["lt"] = bounds[i]
+		}
+		if format != "" {
+			r["format"] = format
+		}
+
+		q := types.Query{Bool: &types.BoolQuery{}}
+		if len(bounds) > 0 {
+			q.Bool.Filter = append(q.Bool.Filter, types.Query{Range: map[string]types.RangeQuery{*field: r}})
+		}
+		if c.cfg.Query != nil {
+			q.Bool.Filter = append(q.Bool.Filter, *c.cfg.Query)
+		}
+		queries = append(queries, &q)
+	}
+	return queries, nil
+}
+
+// CompositeIterator iterates over the buckets of a composite aggregation scan running in the background.
+//
+// It must be closed, to stop the scan, unless Next returned false.
+type CompositeIterator struct {
+	it *iterator[CompositeBucket]
+}
+
+// NewCompositeIterator starts the composite aggregation scan in the background
+// and returns an iterator over the buckets.
+func NewCompositeIterator(ctx context.Context, client elastictransport.Interface, cfg CompositeConfig) *CompositeIterator {
+	return &CompositeIterator{it: newIterator(ctx, 100, func(ctx context.Context, emit func(context.Context, CompositeBucket) error) error {
+		return ScanComposite(ctx, client, cfg, emit)
+	})}
+}
+
+// Next advances the iterator to the next bucket. It returns false when the scan ends.
+func (it *CompositeIterator) Next() bool {
+	return it.it.next()
+}
+
+// Bucket returns the current bucket.
+func (it *CompositeIterator) Bucket() CompositeBucket {
+	return it.it.item
+}
+
+// Err returns the error of the scan, once it has ended.
+func (it *CompositeIterator) Err() error {
+	return it.it.err()
+}
+
+// Close stops the scan.
+func (it *CompositeIterator) Close() error {
+	return it.it.close()
+}
+
+// sortedKeys returns the keys of the map, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,287 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/some"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// mockCompositeCluster serves composite aggregations over the tags t00 to t09,
+// with the days 1 to 3, and a document per tag and day.
+type mockCompositeCluster struct {
+	mu sync.Mutex
+
+	requests []map[string]interface{}
+}
+
+func (m *mockCompositeCluster) handle(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !strings.HasSuffix(req.URL.Path, "/_search") {
+		return mockResponse(404, `{}`)
+	}
+
+	var body struct {
+		Query struct {
+			Bool struct {
+				Filter []struct {
+					Range map[string]struct {
+						Gte string `json:"gte"`
+						Lt  string `json:"lt"`
+					} `json:"range"`
+				} `json:"filter"`
+			} `json:"bool"`
+		} `json:"query"`
+		Aggregations map[string]struct {
+			Composite struct {
+				Size    int                      `json:"size"`
+				After   map[string]interface{}   `json:"after"`
+				Sources []map[string]interface{} `json:"sources"`
+			} `json:"composite"`
+		} `json:"aggregations"`
+	}
+	raw, _ := ioutil.ReadAll(req.Body)
+	json.Unmarshal(raw, &body)
+	var generic map[string]interface{}
+	json.Unmarshal(raw, &generic)
+	m.requests = append(m.requests, generic)
+
+	agg := body.Aggregations["composite"].Composite
+	withDay := len(agg.Sources) > 1
+
+	gte, lt := "", "~"
+	for _, f := range body.Query.Bool.Filter {
+		if r, ok := f.Range["tag"]; ok {
+			gte = r.Gte
+			if r.Lt != "" {
+				lt = r.Lt
+			}
+		}
+	}
+
+	type key struct {
+		tag string
+		day int
+	}
+	var keys []key
+	for i := 0; i < 10; i++ {
+		tag := fmt.Sprintf("t%02d", i)
+		if tag < gte || tag >= lt {
+			continue
+		}
+		if !withDay {
+			keys = append(keys, key{tag: tag})
+			continue
+		}
+		for day := 1; day <= 3; day++ {
+			keys = append(keys, key{tag, day})
+		}
+	}
+
+	if agg.After != nil {
+		after := key{tag: agg.After["tag"].(string)}
+		if d, ok := agg.After["day"].(float64); ok {
+			after.day = int(d)
+		}
+		i := sort.Search(len(keys), func(i int) bool {
+			return keys[i].tag > after.tag || (keys[i].tag == after.tag && keys[i].day > after.day)
+		})
+		keys = keys[i:]
+	}
+	if len(keys) > agg.Size {
+		keys = keys[:agg.Size]
+	}
+
+	var (
+		buckets  []string
+		afterKey string
+	)
+	for _, k := range keys {
+		if withDay {
+			afterKey = fmt.Sprintf(`{"tag":%q,"day":%d}`, k.tag, k.day)
+			buckets = append(buckets, fmt.Sprintf(`{"key":%s,"doc_count":1,"sum#total":{"value":%d}}`, afterKey, k.day))
+		} else {
+			afterKey = fmt.Sprintf(`{"tag":%q}`, k.tag)
+			buckets = append(buckets, fmt.Sprintf(`{"key":%s,"doc_count":3}`, afterKey))
+		}
+	}
+	if afterKey != "" {
+		afterKey = `,"after_key":` + afterKey
+	}
+
+	return mockResponse(200, fmt.Sprintf(`{"took":1,"timed_out":false,"_shards":{"total":1,"successful":1,"failed":0},"hits":{"hits":[]},`+
+		`"aggregations":{"composite#composite":{"buckets":[%s]%s}}}`, strings.Join(buckets, ","), afterKey))
+}
+
+func testCompositeConfig() CompositeConfig {
+	return CompositeConfig{
+		Index: []string{"test"},
+		Aggregation: types.CompositeAggregation{
+			Size: some.Int(4),
+			Sources: []map[string]types.CompositeAggregationSource{
+				{"tag": {Terms: &types.CompositeTermsAggregation{Field: some.String("tag")}}},
+				{"day": {Histogram: &types.CompositeHistogramAggregation{Field: some.String("day"), Interval: 1}}},
+			},
+		},
+		Aggregations: map[string]types.Aggregations{
+			"total": {Sum: &types.SumAggregation{Field: some.String("n")}},
+		},
+	}
+}
+
+func TestScanComposite(t *testing.T) {
+	t.Run("Pages", func(t *testing.T) {
+		cluster := &mockCompositeCluster{}
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+
+		var (
+			keys  []string
+			pages []string
+		)
+		cfg := testCompositeConfig()
+		cfg.OnPage = func(ctx context.Context, partition int, after types.CompositeAggregateKey) {
+			pages = append(pages, fmt.Sprintf("%v/%v", after["tag"], after["day"]))
+		}
+		err := ScanComposite(context.Background(), es, cfg, func(ctx context.Context, b CompositeBucket) error {
+			if sum, ok := b.Aggregations["total"].(*types.SumAggregate); !ok || float64(sum.Value) != b.Key["day"] {
+				t.Errorf("Unexpected sub-aggregation: %#v", b.Aggregations["total"])
+			}
+			keys = append(keys, fmt.Sprintf("%v/%v", b.Key["tag"], b.Key["day"]))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if len(keys) != 30 || keys[0] != "t00/1" || keys[29] != "t09/3" {
+			t.Errorf("Unexpected buckets: %v", keys)
+		}
+		if len(cluster.requests) != 8 {
+			t.Errorf("Unexpected number of requests: %d", len(cluster.requests))
+		}
+		if len(pages) != 8 || pages[0] != "t01/1" {
+			t.Errorf("Unexpected pages: %v", pages)
+		}
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: (&mockCompositeCluster{}).handle}})
+
+		cfg := testCompositeConfig()
+		cfg.Aggregation.After = types.CompositeAggregateKey{"tag": "t08", "day": 2}
+		var keys []string
+		err := ScanComposite(context.Background(), es, cfg, func(ctx context.Context, b CompositeBucket) error {
+			keys = append(keys, fmt.Sprintf("%v/%v", b.Key["tag"], b.Key["day"]))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if fmt.Sprint(keys) != "[t08/3 t09/1 t09/2 t09/3]" {
+			t.Errorf("Unexpected buckets: %v", keys)
+		}
+	})
+
+	t.Run("Partitions", func(t *testing.T) {
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: (&mockCompositeCluster{}).handle}})
+
+		cfg := testCompositeConfig()
+		cfg.Partitions = 3
+		var (
+			mu         sync.Mutex
+			seen       = make(map[string]int)
+			partitions = make(map[int]int)
+		)
+		err := ScanComposite(context.Background(), es, cfg, func(ctx context.Context, b CompositeBucket) error {
+			mu.Lock()
+			defer mu.Unlock()
+			seen[fmt.Sprintf("%v/%v", b.Key["tag"], b.Key["day"])]++
+			partitions[b.Partition]++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if len(seen) != 30 {
+			t.Errorf("Unexpected number of buckets: %d", len(seen))
+		}
+		for k, n := range seen {
+			if n != 1 {
+				t.Errorf("Unexpected duplicate bucket [%s]: %d", k, n)
+			}
+		}
+		if len(partitions) != 3 {
+			t.Errorf("Unexpected partitions: %v", partitions)
+		}
+	})
+
+	t.Run("Partitions require a field", func(t *testing.T) {
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: (&mockCompositeCluster{}).handle}})
+
+		cfg := testCompositeConfig()
+		cfg.Partitions = 2
+		cfg.Aggregation.Sources[0]["tag"] = types.CompositeAggregationSource{
+			Terms: &types.CompositeTermsAggregation{Script: map[string]string{"source": "doc['tag'].value"}},
+		}
+		if err := ScanComposite(context.Background(), es, cfg, func(context.Context, CompositeBucket) error { return nil }); err == nil {
+			t.Errorf("Expected error")
+		}
+	})
+
+	t.Run("Iterator", func(t *testing.T) {
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: (&mockCompositeCluster{}).handle}})
+
+		it := NewCompositeIterator(context.Background(), es, testCompositeConfig())
+		var n int
+		for it.Next() {
+			n++
+		}
+		if err := it.Err(); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if n != 30 {
+			t.Errorf("Unexpected number of buckets: %d", n)
+		}
+	})
+}
+
+func TestCompositeKeyID(t *testing.T) {
+	a := CompositeKeyID(types.CompositeAggregateKey{"tag": "t01", "day": 1.0})
+	b := CompositeKeyID(types.CompositeAggregateKey{"day": 1.0, "tag": "t01"})
+	c := CompositeKeyID(types.CompositeAggregateKey{"tag": "t01", "day": 2.0})
+	if a != b || a == c || len(a) != 40 {
+		t.Errorf("Unexpected IDs: %s, %s, %s", a, b, c)
+	}
+}
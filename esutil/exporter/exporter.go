@@ -0,0 +1,319 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package exporter streams the documents matching a query to NDJSON or CSV files.
+package exporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/elastic/go-elasticsearch/v8/esutil"
+	"github.com/elastic/go-elasticsearch/v8/esutil/internal/dotpath"
+)
+
+// Format represents the output format.
+type Format string
+
+// The output formats.
+const (
+	FormatNDJSON Format = "ndjson" // One JSON document per line
+	FormatCSV    Format = "csv"    // One row per document, with a header row
+)
+
+// Config represents configuration of the export.
+type Config struct {
+	Client esapi.Transport // The client, a *elasticsearch.Client or a *elasticsearch.TypedClient. Required.
+
+	// Scan configures the point in time scan, eg. the indices, the query and the number of slices.
+	// Scan.Index is required. All slices read from the same point in time,
+	// so the export is a consistent snapshot, but the order of documents
+	// from different slices is undefined.
+	Scan esutil.ScanConfig
+
+	Format Format // The output format. Defaults to FormatNDJSON.
+	Gzip   bool   // Compress the output with gzip.
+
+	// Fields selects the fields to export, as dotted paths, eg. "user.name".
+	// It is required for FormatCSV, where it is the list of columns.
+	// With FormatNDJSON, it is used as the _source filtering, unless
+	// Scan.Source is set.
+	Fields []string
+
+	// IncludeMeta adds the index and ID of the documents: as the _index and _id
+	// columns with FormatCSV, and with FormatNDJSON by writing the hit with
+	// _index, _id and _source instead of the source.
+	IncludeMeta bool
+
+	OnProgress       func(context.Context, Report) // Called periodically and when the export ends.
+	ProgressInterval time.Duration                 // The interval of OnProgress calls. Defaults to 10sec.
+}
+
+// Report represents the outcome of the export.
+type Report struct {
+	NumDocs  uint64 // The number of documents written
+	NumBytes uint64 // The number of bytes written, before compression
+	Elapsed  time.Duration
+}
+
+// Export writes all documents matching the query to w.
+func Export(ctx context.Context, w io.Writer, cfg Config) (Report, error) {
+	if cfg.Client == nil {
+		return Report{}, errors.New("export: client is required")
+	}
+	if cfg.Format == "" {
+		cfg.Format = FormatNDJSON
+	}
+	if cfg.ProgressInterval == 0 {
+		cfg.ProgressInterval = 10 * time.Second
+	}
+	if cfg.Scan.Source == nil && len(cfg.Fields) > 0 {
+		cfg.Scan.Source = cfg.Fields
+	}
+
+	ex := exporter{cfg: cfg, start: time.Now()}
+
+	var zw *gzip.Writer
+	out := &countingWriter{w: w, n: &ex.numBytes}
+	if cfg.Gzip {
+		zw = gzip.NewWriter(w)
+		out.w = zw
+	}
+
+	switch cfg.Format {
+	case FormatNDJSON:
+		ex.enc = &ndjsonEncoder{w: out, meta: cfg.IncludeMeta}
+	case FormatCSV:
+		if len(cfg.Fields) == 0 {
+			return Report{}, errors.New("export: fields are required for CSV")
+		}
+		enc := &csvEncoder{w: csv.NewWriter(out), fields: cfg.Fields, meta: cfg.IncludeMeta}
+		if err := enc.header(); err != nil {
+			return Report{}, fmt.Errorf("export: %s", err)
+		}
+		ex.enc = enc
+	default:
+		return Report{}, fmt.Errorf("export: unknown format [%s]", cfg.Format)
+	}
+
+	r, err := ex.run(ctx)
+
+	if zw != nil {
+		if zerr := zw.Close(); zerr != nil && err == nil {
+			err = fmt.Errorf("export: %s", zerr)
+		}
+	}
+	return r, err
+}
+
+// exporter represents a single export.
+type exporter struct {
+	cfg   Config
+	start time.Time
+
+	mu  sync.Mutex // Serializes the writes from the slices
+	enc encoder
+
+	numDocs  uint64
+	numBytes uint64
+}
+
+// run scans the indices and writes the hits.
+func (ex *exporter) run(ctx context.Context) (Report, error) {
+	var (
+		stop    = make(chan struct{})
+		stopped = make(chan struct{})
+	)
+	go func() {
+		defer close(stopped)
+		if ex.cfg.OnProgress == nil {
+			return
+		}
+		ticker := time.NewTicker(ex.cfg.ProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				ex.cfg.OnProgress(ctx, ex.report())
+			}
+		}
+	}()
+
+	err := esutil.Scan(ctx, ex.cfg.Client, ex.cfg.Scan, func(ctx context.Context, hit esutil.ScanHit) error {
+		ex.mu.Lock()
+		defer ex.mu.Unlock()
+
+		if err := ex.enc.encode(hit); err != nil {
+			return fmt.Errorf("export: document [%s]: %s", hit.ID, err)
+		}
+		atomic.AddUint64(&ex.numDocs, 1)
+		return nil
+	})
+	if ferr := ex.enc.flush(); ferr != nil && err == nil {
+		err = fmt.Errorf("export: %s", ferr)
+	}
+
+	close(stop)
+	<-stopped
+
+	r := ex.report()
+	if ex.cfg.OnProgress != nil {
+		ex.cfg.OnProgress(ctx, r)
+	}
+	return r, err
+}
+
+// report returns the current report.
+func (ex *exporter) report() Report {
+	return Report{
+		NumDocs:  atomic.LoadUint64(&ex.numDocs),
+		NumBytes: atomic.LoadUint64(&ex.numBytes),
+		Elapsed:  time.Since(ex.start),
+	}
+}
+
+// encoder writes the hits in an output format.
+type encoder interface {
+	encode(esutil.ScanHit) error
+	flush() error
+}
+
+// ndjsonEncoder writes the source of the hits, one per line.
+type ndjsonEncoder struct {
+	w    io.Writer
+	meta bool
+	buf  bytes.Buffer
+}
+
+func (e *ndjsonEncoder) encode(hit esutil.ScanHit) error {
+	e.buf.Reset()
+	if e.meta {
+		b, err := json.Marshal(struct {
+			Index  string          `json:"_index"`
+			ID     string          `json:"_id"`
+			Source json.RawMessage `json:"_source,omitempty"`
+		}{hit.Index, hit.ID, hit.Source})
+		if err != nil {
+			return err
+		}
+		e.buf.Write(b)
+	} else {
+		source := hit.Source
+		if len(source) == 0 {
+			source = json.RawMessage(`{}`)
+		}
+		if err := json.Compact(&e.buf, source); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte('\n')
+	_, err := e.w.Write(e.buf.Bytes())
+	return err
+}
+
+func (e *ndjsonEncoder) flush() error { return nil }
+
+// csvEncoder writes the selected fields of the hits, one row per hit.
+type csvEncoder struct {
+	w      *csv.Writer
+	fields []string
+	meta   bool
+	row    []string
+}
+
+// header writes the header row.
+func (e *csvEncoder) header() error {
+	var row []string
+	if e.meta {
+		row = append(row, "_index", "_id")
+	}
+	return e.w.Write(append(row, e.fields...))
+}
+
+func (e *csvEncoder) encode(hit esutil.ScanHit) error {
+	var source map[string]interface{}
+	if len(hit.Source) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(hit.Source))
+		dec.UseNumber()
+		if err := dec.Decode(&source); err != nil {
+			return err
+		}
+	}
+
+	e.row = e.row[:0]
+	if e.meta {
+		e.row = append(e.row, hit.Index, hit.ID)
+	}
+	for _, field := range e.fields {
+		v, _ := dotpath.Lookup(source, field)
+		s, err := formatValue(v)
+		if err != nil {
+			return fmt.Errorf("field [%s]: %s", field, err)
+		}
+		e.row = append(e.row, s)
+	}
+	return e.w.Write(e.row)
+}
+
+func (e *csvEncoder) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// formatValue returns the CSV representation of a source value:
+// strings and numbers as is, arrays and objects as JSON.
+func formatValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		if v {
+			return "true", nil
+		}
+		return "false", nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n *uint64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	atomic.AddUint64(c.n, uint64(n))
+	return n, err
+}
@@ -0,0 +1,189 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package exporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esutil"
+	"github.com/elastic/go-elasticsearch/v8/esutil/internal/estest"
+)
+
+// mockScanCluster serves point in time and search requests over numDocs documents.
+type mockScanCluster struct {
+	mu sync.Mutex
+
+	numDocs  int
+	closed   int
+	searches []map[string]interface{}
+}
+
+func (m *mockScanCluster) handle(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/_pit") && req.Method == http.MethodPost:
+		return estest.Response(200, `{"id":"pit-1"}`)
+
+	case req.URL.Path == "/_pit" && req.Method == http.MethodDelete:
+		m.closed++
+		return estest.Response(200, `{"succeeded":true,"num_freed":1}`)
+
+	case req.URL.Path == "/_search":
+		var body map[string]interface{}
+		json.NewDecoder(req.Body).Decode(&body)
+		m.searches = append(m.searches, body)
+
+		var (
+			size  = int(body["size"].(float64))
+			after = -1
+			slice = 0
+			max   = 1
+		)
+		if sa, ok := body["search_after"].([]interface{}); ok {
+			after = int(sa[0].(float64))
+		}
+		if sl, ok := body["slice"].(map[string]interface{}); ok {
+			slice, max = int(sl["id"].(float64)), int(sl["max"].(float64))
+		}
+
+		var hits []string
+		for i := after + 1; i < m.numDocs && len(hits) < size; i++ {
+			if i%max != slice {
+				continue
+			}
+			hits = append(hits, fmt.Sprintf(
+				`{"_index":"test","_id":"%d","_source":{"n":%d,"user":{"name":"u,%d"},"tags":["a"]},"sort":[%d]}`, i, i, i, i))
+		}
+		return estest.Response(200, fmt.Sprintf(`{"pit_id":"pit-1","hits":{"hits":[%s]}}`, strings.Join(hits, ",")))
+	}
+
+	return estest.Response(404, `{}`)
+}
+
+func TestExport(t *testing.T) {
+	t.Run("NDJSON", func(t *testing.T) {
+		cluster := &mockScanCluster{numDocs: 5}
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: estest.Transport(cluster.handle)})
+
+		var buf bytes.Buffer
+		r, err := Export(context.Background(), &buf, Config{
+			Client: es,
+			Scan:   esutil.ScanConfig{Index: []string{"test"}, Size: 2},
+			Fields: []string{"n", "user.name"},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 5 || lines[1] != `{"n":1,"user":{"name":"u,1"},"tags":["a"]}` {
+			t.Errorf("Unexpected output:\n%s", buf.String())
+		}
+		if r.NumDocs != 5 || r.NumBytes != uint64(buf.Len()) {
+			t.Errorf("Unexpected report: %+v", r)
+		}
+		if source := fmt.Sprint(cluster.searches[0]["_source"]); source != "[n user.name]" {
+			t.Errorf("Unexpected _source: %s", source)
+		}
+		if cluster.closed != 1 {
+			t.Errorf("Expected the point in time to be closed")
+		}
+	})
+
+	t.Run("NDJSON IncludeMeta", func(t *testing.T) {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: estest.Transport((&mockScanCluster{numDocs: 1}).handle)})
+
+		var buf bytes.Buffer
+		_, err := Export(context.Background(), &buf, Config{
+			Client:      es,
+			Scan:        esutil.ScanConfig{Index: []string{"test"}},
+			IncludeMeta: true,
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if out := buf.String(); out != `{"_index":"test","_id":"0","_source":{"n":0,"user":{"name":"u,0"},"tags":["a"]}}`+"\n" {
+			t.Errorf("Unexpected output: %s", out)
+		}
+	})
+
+	t.Run("CSV Slices Gzip", func(t *testing.T) {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: estest.Transport((&mockScanCluster{numDocs: 10}).handle)})
+
+		var buf bytes.Buffer
+		r, err := Export(context.Background(), &buf, Config{
+			Client:      es,
+			Scan:        esutil.ScanConfig{Index: []string{"test"}, Size: 3, Slices: 3},
+			Format:      FormatCSV,
+			Gzip:        true,
+			Fields:      []string{"n", "user.name", "tags", "missing"},
+			IncludeMeta: true,
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		zr, err := gzip.NewReader(&buf)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		out, _ := ioutil.ReadAll(zr)
+
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+		if lines[0] != "_index,_id,n,user.name,tags,missing" {
+			t.Errorf("Unexpected header: %s", lines[0])
+		}
+		rows := lines[1:]
+		sort.Strings(rows)
+		if len(rows) != 10 || rows[0] != `test,0,0,"u,0","[""a""]",` {
+			t.Errorf("Unexpected rows:\n%s", strings.Join(rows, "\n"))
+		}
+		if r.NumDocs != 10 || r.NumBytes != uint64(len(out)) {
+			t.Errorf("Unexpected report: %+v", r)
+		}
+	})
+
+	t.Run("CSV without fields", func(t *testing.T) {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: estest.Transport((&mockScanCluster{}).handle)})
+
+		_, err := Export(context.Background(), &bytes.Buffer{}, Config{
+			Client: es,
+			Scan:   esutil.ScanConfig{Index: []string{"test"}},
+			Format: FormatCSV,
+		})
+		if err == nil || !strings.Contains(err.Error(), "fields are required") {
+			t.Errorf("Expected error, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,224 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// FieldType represents the type a CSV value is coerced to.
+type FieldType string
+
+// The types for CSV values.
+const (
+	TypeString  FieldType = "string"  // The value as is
+	TypeLong    FieldType = "long"    // An integer
+	TypeDouble  FieldType = "double"  // A floating point number
+	TypeBoolean FieldType = "boolean" // A boolean, as accepted by strconv.ParseBool
+	TypeDate    FieldType = "date"    // A time in CSVConfig.TimeLayout, encoded as RFC 3339
+	TypeJSON    FieldType = "json"    // A JSON value, eg. an array or an object
+)
+
+// CSVConfig represents configuration of the CSV decoder.
+type CSVConfig struct {
+	Comma   rune // The field delimiter. Defaults to ','.
+	Comment rune // The comment character, lines starting with it are skipped.
+
+	// Header is the list of column names for files without a header row.
+	// By default, the column names are read from the first row.
+	Header []string
+
+	// Fields maps column names to field names. Columns which aren't
+	// in the map use the column name, columns mapped to "" are dropped.
+	Fields map[string]string
+
+	// Types maps field names to the type of their values, fields which aren't
+	// in the map are strings. Empty values of other types are left out.
+	Types map[string]FieldType
+
+	TimeLayout string // The layout of TypeDate values. Defaults to time.RFC3339.
+}
+
+// CSVDecoder decodes the rows of a CSV file, one record per row.
+type CSVDecoder struct {
+	cfg    CSVConfig
+	r      io.Reader
+	cr     *csv.Reader
+	base   int64
+	fields []string
+}
+
+// NewCSVDecoder creates a decoder for r.
+func NewCSVDecoder(r io.Reader, cfg CSVConfig) *CSVDecoder {
+	if cfg.TimeLayout == "" {
+		cfg.TimeLayout = time.RFC3339
+	}
+
+	d := CSVDecoder{cfg: cfg, r: r}
+	d.cr = d.newReader(r)
+	return &d
+}
+
+// Decode implements the Decoder interface.
+func (d *CSVDecoder) Decode() (map[string]interface{}, error) {
+	if err := d.readHeader(); err != nil {
+		return nil, err
+	}
+
+	row, err := d.cr.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]interface{}, len(row))
+	for i, value := range row {
+		if i >= len(d.fields) {
+			line, _ := d.cr.FieldPos(i)
+			return nil, fmt.Errorf("line %d: unexpected column %d", line, i+1)
+		}
+		name := d.fields[i]
+		if name == "" {
+			continue
+		}
+
+		v, err := d.coerce(name, value)
+		if err != nil {
+			line, col := d.cr.FieldPos(i)
+			return nil, fmt.Errorf("line %d, column %d: field [%s]: %s", line, col, name, err)
+		}
+		if v != nil {
+			fields[name] = v
+		}
+	}
+	return fields, nil
+}
+
+// Offset implements the Decoder interface, it returns the byte offset after the last decoded row.
+func (d *CSVDecoder) Offset() int64 {
+	return d.base + d.cr.InputOffset()
+}
+
+// Resume implements the Resumer interface. It reads the header, and seeks to the offset
+// when the reader is an io.Seeker, or skips the rows before it otherwise.
+//
+// Line numbers in errors are counted from the offset when the reader is an io.Seeker.
+func (d *CSVDecoder) Resume(offset int64) error {
+	if err := d.readHeader(); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	if d.Offset() >= offset {
+		return nil
+	}
+
+	if s, ok := d.r.(io.Seeker); ok {
+		if _, err := s.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		d.cr = d.newReader(d.r)
+		d.base = offset
+		return nil
+	}
+
+	for d.Offset() < offset {
+		if _, err := d.cr.Read(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// readHeader sets the field names from the header, once.
+func (d *CSVDecoder) readHeader() error {
+	if d.fields != nil {
+		return nil
+	}
+
+	header := d.cfg.Header
+	if header == nil {
+		row, err := d.cr.Read()
+		if err != nil {
+			return err
+		}
+		header = row
+	}
+
+	d.fields = make([]string, len(header))
+	for i, column := range header {
+		d.fields[i] = column
+		if name, ok := d.cfg.Fields[column]; ok {
+			d.fields[i] = name
+		}
+	}
+	return nil
+}
+
+// coerce converts the value to the type of the field.
+func (d *CSVDecoder) coerce(name, value string) (interface{}, error) {
+	typ, ok := d.cfg.Types[name]
+	if !ok || typ == TypeString {
+		return value, nil
+	}
+	if value == "" {
+		return nil, nil
+	}
+
+	switch typ {
+	case TypeLong:
+		return strconv.ParseInt(value, 10, 64)
+	case TypeDouble:
+		return strconv.ParseFloat(value, 64)
+	case TypeBoolean:
+		return strconv.ParseBool(value)
+	case TypeDate:
+		t, err := time.Parse(d.cfg.TimeLayout, value)
+		if err != nil {
+			return nil, err
+		}
+		return t.Format(time.RFC3339Nano), nil
+	case TypeJSON:
+		var v interface{}
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("unknown type [%s]", typ)
+}
+
+// newReader returns a CSV reader for r, with the configured options.
+func (d *CSVDecoder) newReader(r io.Reader) *csv.Reader {
+	cr := csv.NewReader(r)
+	if d.cfg.Comma != 0 {
+		cr.Comma = d.cfg.Comma
+	}
+	cr.Comment = d.cfg.Comment
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	return cr
+}
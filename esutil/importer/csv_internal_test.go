@@ -0,0 +1,124 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package importer
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCSVDecoder(t *testing.T) {
+	const data = "Name,Age,Score,Active,Joined,Tags,Internal\n" +
+		"alice,30,1.5,true,2024-01-02T03:04:05Z,\"[\"\"a\"\",\"\"b\"\"]\",x\n" +
+		"bob,,2,false,,,y\n"
+
+	cfg := CSVConfig{
+		Fields: map[string]string{"Name": "name", "Age": "age", "Internal": ""},
+		Types: map[string]FieldType{
+			"age":    TypeLong,
+			"Score":  TypeDouble,
+			"Active": TypeBoolean,
+			"Joined": TypeDate,
+			"Tags":   TypeJSON,
+		},
+	}
+
+	t.Run("Decode", func(t *testing.T) {
+		dec := NewCSVDecoder(strings.NewReader(data), cfg)
+
+		var records []string
+		for {
+			fields, err := dec.Decode()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			b, _ := json.Marshal(fields)
+			records = append(records, string(b))
+		}
+
+		expected := []string{
+			`{"Active":true,"Joined":"2024-01-02T03:04:05Z","Score":1.5,"Tags":["a","b"],"age":30,"name":"alice"}`,
+			`{"Active":false,"Score":2,"name":"bob"}`,
+		}
+		if len(records) != 2 || records[0] != expected[0] || records[1] != expected[1] {
+			t.Errorf("Unexpected records:\n%s", strings.Join(records, "\n"))
+		}
+		if dec.Offset() != int64(len(data)) {
+			t.Errorf("Unexpected offset: %d", dec.Offset())
+		}
+	})
+
+	t.Run("Invalid value", func(t *testing.T) {
+		dec := NewCSVDecoder(strings.NewReader("Age\n30\nthirty\n"), cfg)
+
+		dec.Decode()
+		_, err := dec.Decode()
+		if err == nil || !strings.Contains(err.Error(), "line 3, column 1: field [age]") {
+			t.Errorf("Expected error for line 3, got: %v", err)
+		}
+	})
+
+	t.Run("Header", func(t *testing.T) {
+		dec := NewCSVDecoder(strings.NewReader("a;1\n"), CSVConfig{Comma: ';', Header: []string{"k", "v"}})
+
+		fields, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if fields["k"] != "a" || fields["v"] != "1" {
+			t.Errorf("Unexpected fields: %v", fields)
+		}
+	})
+
+	for name, wrap := range map[string]func(io.Reader) io.Reader{
+		"Resume Seeker":  func(r io.Reader) io.Reader { return r },
+		"Resume Skipped": func(r io.Reader) io.Reader { return noSeekReader{r} },
+	} {
+		wrap := wrap
+		t.Run(name, func(t *testing.T) {
+			dec := NewCSVDecoder(wrap(strings.NewReader(data)), cfg)
+
+			offset := int64(strings.Index(data, "bob"))
+			if err := dec.Resume(offset); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			fields, err := dec.Decode()
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if fields["name"] != "bob" {
+				t.Errorf("Unexpected fields: %v", fields)
+			}
+			if dec.Offset() != int64(len(data)) {
+				t.Errorf("Unexpected offset: %d", dec.Offset())
+			}
+			if _, err := dec.Decode(); err != io.EOF {
+				t.Errorf("Expected EOF, got: %v", err)
+			}
+		})
+	}
+}
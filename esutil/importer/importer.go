@@ -0,0 +1,341 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package importer streams records from NDJSON, CSV and other files into a bulk indexer.
+//
+// Parquet files are decoded by the separate esutil/importer/parquet module.
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esutil"
+	"github.com/elastic/go-elasticsearch/v8/esutil/internal/dotpath"
+	"github.com/elastic/go-elasticsearch/v8/esutil/internal/progress"
+)
+
+// Decoder reads records from a file.
+type Decoder interface {
+	// Decode returns the fields of the next record, or io.EOF at the end of the file.
+	Decode() (map[string]interface{}, error)
+
+	// Offset returns the position after the last decoded record,
+	// eg. the byte offset in the file.
+	Offset() int64
+}
+
+// Resumer is implemented by decoders which can skip to an offset
+// without decoding the records before it.
+type Resumer interface {
+	// Resume positions the decoder at the offset returned by Offset.
+	Resume(offset int64) error
+}
+
+// Config represents configuration of the import.
+type Config struct {
+	Indexer esutil.BulkIndexer // The bulk indexer for the records. Required.
+
+	Index   string // The index for the records, defaults to the index of the bulk indexer.
+	Action  string // The bulk action for the records. Defaults to "index".
+	IDField string // The field with the document ID, as a dotted path, eg. "user.id".
+
+	// IDTemplate is a text/template executed with the record fields
+	// to produce the document ID, eg. "{{.user}}-{{.timestamp}}".
+	// It takes precedence over IDField.
+	IDTemplate string
+
+	// Transform is called for every record before it is indexed.
+	// It can modify the record, or set Skip to leave it out.
+	Transform func(context.Context, *Record) error
+
+	// Checkpoint resumes an import from a checkpoint reported by OnProgress.
+	Checkpoint *Checkpoint
+
+	OnProgress       func(context.Context, Report) // Called periodically and when the import ends.
+	ProgressInterval time.Duration                 // The interval of OnProgress calls. Defaults to 10sec.
+
+	// OnFailure is called for records which could not be indexed.
+	OnFailure func(context.Context, Record, esutil.BulkIndexerResponseItem, error)
+}
+
+// Record represents a single record read from the file.
+type Record struct {
+	Index   string                 // The target index, defaults to Config.Index
+	ID      string                 // The document ID, from Config.IDTemplate or Config.IDField
+	Routing string                 // The routing
+	Source  map[string]interface{} // The document source, as decoded from the file
+	Skip    bool                   // Set to leave out the record
+
+	Offset int64 // The position after the record in the file
+}
+
+// Checkpoint represents a position in the file to resume the import from.
+// It is safe to persist as JSON.
+type Checkpoint struct {
+	Offset    int64  `json:"offset"`    // The position after the last processed record
+	Processed uint64 `json:"processed"` // The number of records processed up to the checkpoint
+}
+
+// Report represents the outcome of the import.
+type Report struct {
+	NumRead    uint64 // The number of records read from the file
+	NumIndexed uint64 // The number of records indexed
+	NumFailed  uint64 // The number of records which could not be indexed
+	NumSkipped uint64 // The number of records left out by Transform
+
+	// Checkpoint is the position up to which all records have been processed.
+	Checkpoint *Checkpoint
+
+	Elapsed time.Duration
+}
+
+// Import reads all records from the decoder and adds them to the bulk indexer.
+// It flushes the bulk indexer before it returns, but doesn't close it.
+//
+// It returns the report, also when the import has failed,
+// so that the last checkpoint can be persisted. Records of failed bulk
+// requests are counted as failed, and the import stops with an error.
+// The checkpoint doesn't move past them, so that a resume indexes them again.
+func Import(ctx context.Context, dec Decoder, cfg Config) (Report, error) {
+	if cfg.Indexer == nil {
+		return Report{}, errors.New("import: bulk indexer is required")
+	}
+	if cfg.Action == "" {
+		cfg.Action = "index"
+	}
+	if cfg.ProgressInterval == 0 {
+		cfg.ProgressInterval = 10 * time.Second
+	}
+
+	im := importer{cfg: cfg, start: time.Now(), tracker: progress.NewTracker[int64]()}
+
+	if cfg.IDTemplate != "" {
+		tmpl, err := template.New("id").Option("missingkey=error").Parse(cfg.IDTemplate)
+		if err != nil {
+			return Report{}, fmt.Errorf("import: ID template: %s", err)
+		}
+		im.idTemplate = tmpl
+	}
+
+	if cfg.Checkpoint != nil {
+		im.tracker.Resume(cfg.Checkpoint.Offset, cfg.Checkpoint.Processed)
+		if err := resume(dec, cfg.Checkpoint.Offset); err != nil {
+			return im.report(), fmt.Errorf("import: resume: %s", err)
+		}
+	}
+
+	return im.run(ctx, dec)
+}
+
+// importer represents a single import.
+type importer struct {
+	cfg        Config
+	start      time.Time
+	idTemplate *template.Template
+
+	numRead    uint64
+	numIndexed uint64
+	numFailed  uint64
+	numSkipped uint64
+
+	tracker *progress.Tracker[int64] // The offsets of the processed records
+}
+
+// run decodes the records and feeds the bulk indexer.
+func (im *importer) run(ctx context.Context, dec Decoder) (Report, error) {
+	stop := func() {}
+	if im.cfg.OnProgress != nil {
+		stop = progress.Every(im.cfg.ProgressInterval, func() { im.cfg.OnProgress(ctx, im.report()) })
+	}
+
+	readErr := im.read(ctx, dec)
+
+	// Records added before a failure are flushed, since the indexer is shared.
+	flushErr := im.cfg.Indexer.Flush(context.WithoutCancel(ctx))
+
+	stop()
+
+	r := im.report()
+	if im.cfg.OnProgress != nil {
+		im.cfg.OnProgress(ctx, r)
+	}
+
+	// The error of the failed bulk requests also stops the read,
+	// it is returned with the final number of records.
+	if err := im.requestErr(); err != nil {
+		return r, err
+	}
+	if readErr != nil {
+		return r, readErr
+	}
+	if flushErr != nil {
+		return r, fmt.Errorf("import: %s", flushErr)
+	}
+	return r, nil
+}
+
+// read adds the records to the bulk indexer until the end of the file.
+func (im *importer) read(ctx context.Context, dec Decoder) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// Stop feeding the indexer once the target has failed.
+		if err := im.requestErr(); err != nil {
+			return err
+		}
+
+		fields, err := dec.Decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("import: %w", err)
+		}
+		atomic.AddUint64(&im.numRead, 1)
+		seq := im.tracker.Next()
+
+		rec := Record{Index: im.cfg.Index, Source: fields, Offset: dec.Offset()}
+		if rec.ID, err = im.documentID(fields); err != nil {
+			return fmt.Errorf("import: offset %d: %s", rec.Offset, err)
+		}
+		if im.cfg.Transform != nil {
+			if err := im.cfg.Transform(ctx, &rec); err != nil {
+				return fmt.Errorf("import: transform: offset %d: %w", rec.Offset, err)
+			}
+		}
+		if rec.Skip {
+			atomic.AddUint64(&im.numSkipped, 1)
+			im.tracker.Done(seq, rec.Offset)
+			continue
+		}
+
+		body, err := json.Marshal(rec.Source)
+		if err != nil {
+			return fmt.Errorf("import: offset %d: error encoding record: %s", rec.Offset, err)
+		}
+
+		err = im.cfg.Indexer.Add(ctx, esutil.BulkIndexerItem{
+			Index:      rec.Index,
+			Action:     im.cfg.Action,
+			DocumentID: rec.ID,
+			Routing:    rec.Routing,
+			Body:       bytes.NewReader(body),
+			Future: esutil.NewBulkIndexerFutureFunc(func(res esutil.BulkIndexerItemResult) {
+				im.complete(ctx, rec, seq, res)
+			}),
+		})
+		if err != nil {
+			return fmt.Errorf("import: %w", err)
+		}
+	}
+}
+
+// complete records the result of the record.
+//
+// The future completes also when the whole bulk request has failed,
+// eg. with a flush triggered by FlushBytes or FlushInterval, which
+// is otherwise reported only to the OnError callback of the indexer.
+// Such a record was not indexed, and stops the checkpoint.
+func (im *importer) complete(ctx context.Context, rec Record, seq uint64, res esutil.BulkIndexerItemResult) {
+	if res.Err == nil {
+		atomic.AddUint64(&im.numIndexed, 1)
+		im.tracker.Done(seq, rec.Offset)
+		return
+	}
+
+	atomic.AddUint64(&im.numFailed, 1)
+	if im.cfg.OnFailure != nil {
+		im.cfg.OnFailure(ctx, rec, res.Response, res.Err)
+	}
+	if res.Response.Status == 0 {
+		im.tracker.Fail(seq, res.Err)
+	} else {
+		im.tracker.Done(seq, rec.Offset)
+	}
+}
+
+// requestErr returns the error for the records of failed bulk requests, if any.
+func (im *importer) requestErr() error {
+	n, err := im.tracker.RequestErr()
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("import: %d records failed with their bulk request: %s", n, err)
+}
+
+// documentID returns the document ID for the record fields.
+func (im *importer) documentID(fields map[string]interface{}) (string, error) {
+	switch {
+	case im.idTemplate != nil:
+		var b strings.Builder
+		if err := im.idTemplate.Execute(&b, fields); err != nil {
+			return "", fmt.Errorf("ID template: %s", err)
+		}
+		return b.String(), nil
+	case im.cfg.IDField != "":
+		v, ok := dotpath.Lookup(fields, im.cfg.IDField)
+		if !ok || v == nil {
+			return "", fmt.Errorf("missing ID field [%s]", im.cfg.IDField)
+		}
+		return fmt.Sprint(v), nil
+	}
+	return "", nil
+}
+
+// report returns the current report.
+func (im *importer) report() Report {
+	r := Report{
+		NumRead:    atomic.LoadUint64(&im.numRead),
+		NumIndexed: atomic.LoadUint64(&im.numIndexed),
+		NumFailed:  atomic.LoadUint64(&im.numFailed),
+		NumSkipped: atomic.LoadUint64(&im.numSkipped),
+		Elapsed:    time.Since(im.start),
+	}
+
+	if offset, processed, ok := im.tracker.Checkpoint(); ok {
+		r.Checkpoint = &Checkpoint{Offset: offset, Processed: processed}
+	}
+
+	return r
+}
+
+// resume positions the decoder after the offset, skipping
+// the records when the decoder doesn't implement Resumer.
+func resume(dec Decoder, offset int64) error {
+	if r, ok := dec.(Resumer); ok {
+		return r.Resume(offset)
+	}
+	for dec.Offset() < offset {
+		if _, err := dec.Decode(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esutil"
+	"github.com/elastic/go-elasticsearch/v8/esutil/internal/estest"
+)
+
+// mockBulkTarget serves bulk requests, failing the documents in failIDs,
+// or the whole requests with failBulk.
+type mockBulkTarget struct {
+	mu sync.Mutex
+
+	docs       map[string]string
+	failIDs    map[string]bool
+	failBulk   bool
+	failBulkAt int // Fail the n-th bulk request as a whole, when set
+	numBulk    int
+}
+
+func (m *mockBulkTarget) handle(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !strings.HasSuffix(req.URL.Path, "/_bulk") {
+		return estest.Response(404, `{}`)
+	}
+	m.numBulk++
+	if m.failBulk || m.numBulk == m.failBulkAt {
+		return estest.Response(500, `{"error":{"type":"internal_server_error","reason":"failed"},"status":500}`)
+	}
+
+	body, _ := ioutil.ReadAll(req.Body)
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+
+	var items []string
+	for i := 0; i < len(lines); i += 2 {
+		var meta map[string]struct {
+			ID    string `json:"_id"`
+			Index string `json:"_index"`
+		}
+		json.Unmarshal([]byte(lines[i]), &meta)
+		id := meta["index"].ID
+		if m.failIDs[id] {
+			items = append(items, fmt.Sprintf(`{"index":{"_id":%q,"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed"}}}`, id))
+			continue
+		}
+		if m.docs == nil {
+			m.docs = make(map[string]string)
+		}
+		index := meta["index"].Index
+		if index == "" {
+			index = strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/"), "/_bulk")
+		}
+		m.docs[index+"/"+id] = lines[i+1]
+		items = append(items, fmt.Sprintf(`{"index":{"_id":%q,"status":201}}`, id))
+	}
+	return estest.Response(200, fmt.Sprintf(`{"items":[%s]}`, strings.Join(items, ",")))
+}
+
+func newTestIndexer(t *testing.T, target *mockBulkTarget) esutil.BulkIndexer {
+	return newTestIndexerWorkers(t, target, 2)
+}
+
+func newTestIndexerWorkers(t *testing.T, target *mockBulkTarget, numWorkers int) esutil.BulkIndexer {
+	es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: estest.Transport(target.handle)})
+	bi, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{Client: es, Index: "test", NumWorkers: numWorkers, FlushBytes: 100})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	t.Cleanup(func() { bi.Close(context.Background()) })
+	return bi
+}
+
+// noSeekReader hides the io.Seeker implementation of the reader.
+type noSeekReader struct{ io.Reader }
+
+func TestImport(t *testing.T) {
+	var ndjson strings.Builder
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(&ndjson, `{"user":{"id":"u%d"},"n":%d}`+"\n", i, i)
+		if i == 4 {
+			ndjson.WriteString("\n")
+		}
+	}
+
+	t.Run("NDJSON", func(t *testing.T) {
+		target := &mockBulkTarget{failIDs: map[string]bool{"u7": true}}
+
+		var failed []string
+		r, err := Import(context.Background(), NewNDJSONDecoder(strings.NewReader(ndjson.String())), Config{
+			Indexer: newTestIndexer(t, target),
+			IDField: "user.id",
+			Transform: func(ctx context.Context, rec *Record) error {
+				if rec.ID == "u3" {
+					rec.Skip = true
+				}
+				rec.Source["imported"] = true
+				return nil
+			},
+			OnFailure: func(ctx context.Context, rec Record, res esutil.BulkIndexerResponseItem, err error) {
+				failed = append(failed, rec.ID)
+			},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if r.NumRead != 10 || r.NumIndexed != 8 || r.NumFailed != 1 || r.NumSkipped != 1 {
+			t.Errorf("Unexpected report: %+v", r)
+		}
+		if r.Checkpoint == nil || r.Checkpoint.Offset != int64(ndjson.Len()) || r.Checkpoint.Processed != 10 {
+			t.Errorf("Unexpected checkpoint: %+v", r.Checkpoint)
+		}
+		if fmt.Sprint(failed) != "[u7]" {
+			t.Errorf("Unexpected failures: %v", failed)
+		}
+		if doc := target.docs["test/u2"]; doc != `{"imported":true,"n":2,"user":{"id":"u2"}}` {
+			t.Errorf("Unexpected document: %s", doc)
+		}
+	})
+
+	t.Run("Bulk request failure", func(t *testing.T) {
+		target := &mockBulkTarget{failBulk: true}
+
+		var (
+			mu     sync.Mutex
+			failed int
+		)
+		r, err := Import(context.Background(), NewNDJSONDecoder(strings.NewReader(ndjson.String())), Config{
+			Indexer: newTestIndexer(t, target),
+			IDField: "user.id",
+			OnFailure: func(ctx context.Context, rec Record, res esutil.BulkIndexerResponseItem, err error) {
+				if err == nil {
+					t.Errorf("Expected error for record %s", rec.ID)
+				}
+				mu.Lock()
+				failed++
+				mu.Unlock()
+			},
+		})
+		if err == nil {
+			t.Fatalf("Expected error")
+		}
+
+		if r.NumIndexed != 0 || r.NumFailed != r.NumRead || failed != int(r.NumRead) {
+			t.Errorf("Unexpected report: %+v, failures: %d", r, failed)
+		}
+		if r.Checkpoint != nil {
+			t.Errorf("Unexpected checkpoint: %+v", r.Checkpoint)
+		}
+	})
+
+	t.Run("Resume after bulk request failure", func(t *testing.T) {
+		target := &mockBulkTarget{failBulkAt: 2}
+
+		r, err := Import(context.Background(), NewNDJSONDecoder(strings.NewReader(ndjson.String())), Config{
+			Indexer: newTestIndexerWorkers(t, target, 1),
+			IDField: "user.id",
+		})
+		if err == nil {
+			t.Fatalf("Expected error for the failed bulk request")
+		}
+		if r.Checkpoint == nil || r.Checkpoint.Processed == 0 || r.Checkpoint.Processed >= r.NumRead {
+			t.Fatalf("Expected checkpoint before the failed records, got: %+v, report: %+v", r.Checkpoint, r)
+		}
+		if len(target.docs) == 10 {
+			t.Fatalf("Expected documents missing from the target")
+		}
+
+		r, err = Import(context.Background(), NewNDJSONDecoder(strings.NewReader(ndjson.String())), Config{
+			Indexer:    newTestIndexerWorkers(t, target, 1),
+			IDField:    "user.id",
+			Checkpoint: r.Checkpoint,
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if r.Checkpoint == nil || r.Checkpoint.Processed != 10 || r.Checkpoint.Offset != int64(ndjson.Len()) {
+			t.Errorf("Unexpected checkpoint: %+v", r.Checkpoint)
+		}
+		for i := 0; i < 10; i++ {
+			if _, ok := target.docs[fmt.Sprintf("test/u%d", i)]; !ok {
+				t.Errorf("Record u%d skipped by the resume", i)
+			}
+		}
+	})
+
+	t.Run("ID template", func(t *testing.T) {
+		target := &mockBulkTarget{}
+
+		_, err := Import(context.Background(), NewNDJSONDecoder(strings.NewReader(ndjson.String())), Config{
+			Indexer:    newTestIndexer(t, target),
+			Index:      "other",
+			IDTemplate: "{{.user.id}}-{{.n}}",
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if _, ok := target.docs["other/u5-5"]; !ok || len(target.docs) != 10 {
+			t.Errorf("Unexpected documents: %v", target.docs)
+		}
+	})
+
+	t.Run("Missing ID field", func(t *testing.T) {
+		_, err := Import(context.Background(), NewNDJSONDecoder(strings.NewReader(ndjson.String())), Config{
+			Indexer: newTestIndexer(t, &mockBulkTarget{}),
+			IDField: "missing",
+		})
+		if err == nil || !strings.Contains(err.Error(), "missing ID field [missing]") {
+			t.Errorf("Expected missing ID field error, got: %v", err)
+		}
+	})
+
+	t.Run("Invalid line", func(t *testing.T) {
+		_, err := Import(context.Background(), NewNDJSONDecoder(strings.NewReader("{}\n[1]\n")), Config{
+			Indexer: newTestIndexer(t, &mockBulkTarget{}),
+		})
+		if err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("Expected error for line 2, got: %v", err)
+		}
+	})
+
+	for name, wrap := range map[string]func(io.Reader) io.Reader{
+		"Resume Seeker":    func(r io.Reader) io.Reader { return r },
+		"Resume Discarded": func(r io.Reader) io.Reader { return noSeekReader{r} },
+	} {
+		wrap := wrap
+		t.Run(name, func(t *testing.T) {
+			offset := int64(strings.Index(ndjson.String(), `{"user":{"id":"u6"}`))
+			target := &mockBulkTarget{}
+
+			r, err := Import(context.Background(), NewNDJSONDecoder(wrap(strings.NewReader(ndjson.String()))), Config{
+				Indexer:    newTestIndexer(t, target),
+				IDField:    "user.id",
+				Checkpoint: &Checkpoint{Offset: offset, Processed: 6},
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			var ids []string
+			for id := range target.docs {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			if fmt.Sprint(ids) != "[test/u6 test/u7 test/u8 test/u9]" {
+				t.Errorf("Unexpected documents: %v", ids)
+			}
+			if r.Checkpoint == nil || r.Checkpoint.Processed != 10 || r.Checkpoint.Offset != int64(ndjson.Len()) {
+				t.Errorf("Unexpected checkpoint: %+v", r.Checkpoint)
+			}
+		})
+	}
+
+	t.Run("Resume without Resumer", func(t *testing.T) {
+		dec := NewCSVDecoder(strings.NewReader("id\n1\n2\n3\n"), CSVConfig{})
+		target := &mockBulkTarget{}
+
+		_, err := Import(context.Background(), struct{ Decoder }{dec}, Config{
+			Indexer:    newTestIndexer(t, target),
+			IDField:    "id",
+			Checkpoint: &Checkpoint{Offset: 5, Processed: 1},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(target.docs) != 2 || target.docs["test/1"] != "" {
+			t.Errorf("Unexpected documents: %v", target.docs)
+		}
+	})
+}
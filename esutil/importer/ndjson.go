@@ -0,0 +1,95 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// NDJSONDecoder decodes newline-delimited JSON objects, one record per line.
+//
+// Numbers are decoded as json.Number, to preserve their precision.
+type NDJSONDecoder struct {
+	r      io.Reader
+	br     *bufio.Reader
+	offset int64
+	line   int
+}
+
+// NewNDJSONDecoder creates a decoder for r.
+func NewNDJSONDecoder(r io.Reader) *NDJSONDecoder {
+	return &NDJSONDecoder{r: r, br: bufio.NewReaderSize(r, 64*1024)}
+}
+
+// Decode implements the Decoder interface. Empty lines are skipped.
+func (d *NDJSONDecoder) Decode() (map[string]interface{}, error) {
+	for {
+		line, err := d.br.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return nil, err
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		d.offset += int64(len(line))
+		d.line++
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var fields map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.UseNumber()
+		if err := dec.Decode(&fields); err != nil {
+			return nil, fmt.Errorf("line %d: %s", d.line, err)
+		}
+		if fields == nil {
+			return nil, fmt.Errorf("line %d: not a JSON object", d.line)
+		}
+		return fields, nil
+	}
+}
+
+// Offset implements the Decoder interface, it returns the byte offset after the last decoded line.
+func (d *NDJSONDecoder) Offset() int64 {
+	return d.offset
+}
+
+// Resume implements the Resumer interface. It seeks to the offset
+// when the reader is an io.Seeker, and discards the bytes before it otherwise.
+//
+// Line numbers in errors are counted from the offset.
+func (d *NDJSONDecoder) Resume(offset int64) error {
+	if s, ok := d.r.(io.Seeker); ok && d.offset == 0 {
+		if _, err := s.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		d.br.Reset(d.r)
+	} else if _, err := io.CopyN(io.Discard, d.br, offset-d.offset); err != nil && err != io.EOF {
+		return err
+	}
+	d.offset = offset
+	d.line = 0
+	return nil
+}
@@ -0,0 +1,182 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package parquet provides a Parquet decoder for the importer package.
+//
+// It is a separate module, so that the client doesn't depend on the Parquet library.
+// The module doesn't depend on the client either: the Decoder implements the
+// importer.Decoder and importer.Resumer interfaces, which are mirrored below,
+// so that it works with any version of the client which has the importer package.
+package parquet
+
+import (
+	"fmt"
+	"io"
+
+	parquetgo "github.com/parquet-go/parquet-go"
+)
+
+// decoder and resumer mirror the importer.Decoder and importer.Resumer interfaces.
+type (
+	decoder interface {
+		Decode() (map[string]interface{}, error)
+		Offset() int64
+	}
+	resumer interface {
+		Resume(offset int64) error
+	}
+)
+
+var (
+	_ decoder = (*Decoder)(nil)
+	_ resumer = (*Decoder)(nil)
+)
+
+// Decoder decodes the rows of a Parquet file, one record per row.
+//
+// Groups are decoded as objects, and repeated fields as arrays.
+type Decoder struct {
+	r   *parquetgo.Reader
+	row int64
+}
+
+// NewDecoder creates a decoder for the Parquet file in r, of the given size.
+func NewDecoder(r io.ReaderAt, size int64) (*Decoder, error) {
+	f, err := parquetgo.OpenFile(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("parquet: %s", err)
+	}
+	return &Decoder{r: parquetgo.NewReader(f)}, nil
+}
+
+// Decode implements the importer.Decoder interface.
+func (d *Decoder) Decode() (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if err := d.r.Read(&fields); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("parquet: row %d: %s", d.row, err)
+	}
+	d.row++
+
+	for _, node := range d.r.Schema().Fields() {
+		if v, ok := fields[node.Name()]; ok {
+			fields[node.Name()] = unwrap(node, v)
+		}
+	}
+	return fields, nil
+}
+
+// Offset implements the importer.Decoder interface, it returns the index of the next row.
+func (d *Decoder) Offset() int64 {
+	return d.row
+}
+
+// Resume implements the importer.Resumer interface, it seeks to the row.
+func (d *Decoder) Resume(offset int64) error {
+	if offset >= d.r.NumRows() {
+		d.row = d.r.NumRows()
+		return d.r.SeekToRow(d.row)
+	}
+	if err := d.r.SeekToRow(offset); err != nil {
+		return fmt.Errorf("parquet: %s", err)
+	}
+	d.row = offset
+	return nil
+}
+
+// unwrap converts the value of the node, decoded as it is stored in the file,
+// into a document value: the LIST and MAP groups become arrays and objects.
+func unwrap(node parquetgo.Node, v interface{}) interface{} {
+	if node.Repeated() {
+		values, ok := v.([]interface{})
+		if !ok {
+			return v
+		}
+		for i := range values {
+			values[i] = unwrapValue(node, values[i])
+		}
+		return values
+	}
+	return unwrapValue(node, v)
+}
+
+// unwrapValue converts a single value of the node.
+func unwrapValue(node parquetgo.Node, v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok || node.Leaf() {
+		return v
+	}
+
+	switch {
+	case isList(node):
+		group := node.Fields()[0]
+		element := group.Fields()[0]
+		entries, _ := m[group.Name()].([]interface{})
+		values := make([]interface{}, 0, len(entries))
+		for _, e := range entries {
+			em, _ := e.(map[string]interface{})
+			values = append(values, unwrap(element, em[element.Name()]))
+		}
+		return values
+
+	case isMap(node):
+		group := node.Fields()[0]
+		entries, ok := m[group.Name()].([]interface{})
+		if !ok {
+			return m // Already decoded as a map
+		}
+		key, value := group.Fields()[0], group.Fields()[1]
+		values := make(map[string]interface{}, len(entries))
+		for _, e := range entries {
+			em, _ := e.(map[string]interface{})
+			values[fmt.Sprint(em[key.Name()])] = unwrap(value, em[value.Name()])
+		}
+		return values
+	}
+
+	for _, field := range node.Fields() {
+		if fv, ok := m[field.Name()]; ok {
+			m[field.Name()] = unwrap(field, fv)
+		}
+	}
+	return m
+}
+
+// isList returns true for the three-level LIST groups, also when they lack
+// the logical type annotation, as written by some libraries.
+func isList(node parquetgo.Node) bool {
+	fields := node.Fields()
+	if len(fields) != 1 || !fields[0].Repeated() || fields[0].Leaf() || len(fields[0].Fields()) != 1 {
+		return false
+	}
+	if lt := node.Type().LogicalType(); lt != nil && lt.List != nil {
+		return true
+	}
+	return fields[0].Name() == "list"
+}
+
+// isMap returns true for the MAP groups.
+func isMap(node parquetgo.Node) bool {
+	fields := node.Fields()
+	if len(fields) != 1 || !fields[0].Repeated() || fields[0].Leaf() || len(fields[0].Fields()) != 2 {
+		return false
+	}
+	lt := node.Type().LogicalType()
+	return lt != nil && lt.Map != nil
+}
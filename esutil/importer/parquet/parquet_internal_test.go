@@ -0,0 +1,100 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package parquet
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	parquetgo "github.com/parquet-go/parquet-go"
+)
+
+type testRow struct {
+	Name   string           `parquet:"name"`
+	Age    int64            `parquet:"age"`
+	Tags   []string         `parquet:"tags,list"`
+	Labels map[string]int64 `parquet:"labels"`
+	Owner  struct {
+		ID string `parquet:"id"`
+	} `parquet:"owner"`
+}
+
+func TestDecoder(t *testing.T) {
+	var rows []testRow
+	for _, name := range []string{"alice", "bob", "carol"} {
+		row := testRow{Name: name, Age: int64(len(name)), Tags: []string{"a", name}, Labels: map[string]int64{name: 1}}
+		row.Owner.ID = "o-" + name
+		rows = append(rows, row)
+	}
+
+	var buf bytes.Buffer
+	if err := parquetgo.Write(&buf, rows); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	t.Run("Decode", func(t *testing.T) {
+		dec, err := NewDecoder(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		var records []string
+		for {
+			fields, err := dec.Decode()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			b, _ := json.Marshal(fields)
+			records = append(records, string(b))
+		}
+
+		if len(records) != 3 || records[1] != `{"age":3,"labels":{"bob":1},"name":"bob","owner":{"id":"o-bob"},"tags":["a","bob"]}` {
+			t.Errorf("Unexpected records:\n%s", strings.Join(records, "\n"))
+		}
+		if dec.Offset() != 3 {
+			t.Errorf("Unexpected offset: %d", dec.Offset())
+		}
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		dec, _ := NewDecoder(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err := dec.Resume(2); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		fields, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if fields["name"] != "carol" || dec.Offset() != 3 {
+			t.Errorf("Unexpected fields at offset %d: %v", dec.Offset(), fields)
+		}
+		if _, err := dec.Decode(); err != io.EOF {
+			t.Errorf("Expected EOF, got: %v", err)
+		}
+	})
+}
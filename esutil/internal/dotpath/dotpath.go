@@ -0,0 +1,38 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package dotpath looks up fields of decoded JSON objects by dotted path.
+package dotpath
+
+import "strings"
+
+// Lookup returns the value at the dotted path in the fields, eg. "user.id",
+// which matches a field with dots in its name, or nested objects.
+func Lookup(fields map[string]interface{}, path string) (interface{}, bool) {
+	if v, ok := fields[path]; ok {
+		return v, true
+	}
+	head, tail, found := strings.Cut(path, ".")
+	if !found {
+		return nil, false
+	}
+	m, ok := fields[head].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return Lookup(m, tail)
+}
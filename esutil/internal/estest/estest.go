@@ -0,0 +1,42 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package estest provides a mock transport for the tests of the esutil packages.
+package estest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Transport is a mock transport, which serves the requests with the function.
+type Transport func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper.
+func (fn Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+// Response returns a response from Elasticsearch with the status and body.
+func Response(status int, body string) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: status,
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+	}, nil
+}
@@ -0,0 +1,155 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package progress tracks the progress of the helpers which feed a bulk indexer,
+// such as the reindex and the import, as a checkpoint to resume from.
+package progress
+
+import (
+	"sync"
+	"time"
+)
+
+// Tracker tracks the records fed to a bulk indexer, identified by sequence numbers.
+//
+// The checkpoint is the position of the last record in a contiguous run
+// of processed records, since the bulk indexer completes items out of order.
+//
+// A record which failed with its bulk request, eg. when the target is not available
+// or the context is canceled, was never written: the run stops before it,
+// so that a resume from the checkpoint processes it again.
+type Tracker[P any] struct {
+	mu       sync.Mutex
+	base     uint64       // The number of records processed before a resume
+	next     uint64       // The sequence number of the next record
+	acked    uint64       // All records below this sequence number are processed
+	done     map[uint64]P // Positions of processed records above the watermark
+	position P            // The position of the checkpoint
+	valid    bool         // Whether there is a checkpoint
+
+	failed     bool   // Whether a record failed with its bulk request
+	failedAt   uint64 // The lowest sequence number of such a record
+	numFailed  uint64 // The number of records which failed with their bulk request
+	requestErr error  // The error of the first failed bulk request
+}
+
+// NewTracker creates a tracker.
+func NewTracker[P any]() *Tracker[P] {
+	return &Tracker[P]{done: make(map[uint64]P)}
+}
+
+// Resume starts the tracker from a checkpoint.
+func (t *Tracker[P]) Resume(position P, processed uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.position, t.base, t.valid = position, processed, true
+}
+
+// Next returns the sequence number for the next record.
+func (t *Tracker[P]) Next() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	seq := t.next
+	t.next++
+	return seq
+}
+
+// Done marks the record as processed, and advances the checkpoint
+// over the contiguous run of processed records.
+func (t *Tracker[P]) Done(seq uint64, position P) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// The run can't reach past a failed record.
+	if t.failed && seq > t.failedAt {
+		return
+	}
+
+	t.done[seq] = position
+	for {
+		p, ok := t.done[t.acked]
+		if !ok {
+			break
+		}
+		delete(t.done, t.acked)
+		t.acked++
+		t.position, t.valid = p, true
+	}
+}
+
+// Fail marks the record as failed with its bulk request,
+// which stops the checkpoint before it.
+func (t *Tracker[P]) Fail(seq uint64, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.numFailed++
+	if t.requestErr == nil {
+		t.requestErr = err
+	}
+	if t.failed && seq > t.failedAt {
+		return
+	}
+	t.failed, t.failedAt = true, seq
+	for s := range t.done {
+		if s > seq {
+			delete(t.done, s)
+		}
+	}
+}
+
+// Checkpoint returns the position of the last record in the contiguous run,
+// and the number of records processed up to it, or false when there is none.
+func (t *Tracker[P]) Checkpoint() (P, uint64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.position, t.base + t.acked, t.valid
+}
+
+// RequestErr returns the number of records which failed with their bulk request,
+// and the error of the first one, or nil.
+func (t *Tracker[P]) RequestErr() (uint64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.numFailed, t.requestErr
+}
+
+// Every calls fn at every interval in a goroutine, until stop is called.
+// Stop waits for a call in progress to return.
+func Every(interval time.Duration, fn func()) (stop func()) {
+	var (
+		done    = make(chan struct{})
+		stopped = make(chan struct{})
+	)
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				fn()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
@@ -0,0 +1,71 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package progress
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTracker(t *testing.T) {
+	t.Run("Out of order", func(t *testing.T) {
+		tr := NewTracker[string]()
+		for i := 0; i < 4; i++ {
+			tr.Next()
+		}
+
+		tr.Done(1, "b")
+		if _, _, ok := tr.Checkpoint(); ok {
+			t.Errorf("Unexpected checkpoint before the first record")
+		}
+		tr.Done(0, "a")
+		tr.Done(3, "d")
+		if p, n, ok := tr.Checkpoint(); !ok || p != "b" || n != 2 {
+			t.Errorf("Unexpected checkpoint: %q, %d", p, n)
+		}
+		tr.Done(2, "c")
+		if p, n, ok := tr.Checkpoint(); !ok || p != "d" || n != 4 {
+			t.Errorf("Unexpected checkpoint: %q, %d", p, n)
+		}
+	})
+
+	t.Run("Failed request", func(t *testing.T) {
+		tr := NewTracker[string]()
+		tr.Resume("z", 10)
+		for i := 0; i < 4; i++ {
+			tr.Next()
+		}
+
+		tr.Done(0, "a")
+		tr.Done(3, "d")
+		tr.Fail(2, errors.New("boom"))
+		tr.Fail(1, errors.New("other"))
+		if p, n, ok := tr.Checkpoint(); !ok || p != "a" || n != 11 {
+			t.Errorf("Unexpected checkpoint: %q, %d", p, n)
+		}
+		if n, err := tr.RequestErr(); n != 2 || err == nil || err.Error() != "boom" {
+			t.Errorf("Unexpected request error: %d, %v", n, err)
+		}
+		if len(tr.done) != 0 {
+			t.Errorf("Unexpected records past the failure: %v", tr.done)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"context"
+	"errors"
+)
+
+// iterator iterates over the items passed to the callback of a producer running in the background,
+// eg. a scan.
+type iterator[T any] struct {
+	items   chan T
+	done    chan struct{}
+	cancel  context.CancelFunc
+	closing bool
+
+	item   T
+	runErr error
+}
+
+// newIterator starts the producer in the background, with a buffer of size items.
+// The producer passes every item to emit, which fails when the iterator is closed.
+func newIterator[T any](ctx context.Context, size int, produce func(ctx context.Context, emit func(context.Context, T) error) error) *iterator[T] {
+	ctx, cancel := context.WithCancel(ctx)
+	it := iterator[T]{
+		items:  make(chan T, size),
+		done:   make(chan struct{}),
+		cancel: cancel,
+	}
+
+	go func() {
+		defer close(it.items)
+		it.runErr = produce(ctx, func(ctx context.Context, item T) error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case it.items <- item:
+				return nil
+			}
+		})
+		close(it.done)
+	}()
+
+	return &it
+}
+
+// next advances the iterator to the next item. It returns false when the producer ends.
+func (it *iterator[T]) next() bool {
+	item, ok := <-it.items
+	it.item = item
+	return ok
+}
+
+// err returns the error of the producer, once it has ended.
+func (it *iterator[T]) err() error {
+	select {
+	case <-it.done:
+		// The cancellation by close is not an error.
+		if it.closing && errors.Is(it.runErr, context.Canceled) {
+			return nil
+		}
+		return it.runErr
+	default:
+		return nil
+	}
+}
+
+// close stops the producer and waits until it has returned.
+func (it *iterator[T]) close() error {
+	it.closing = true
+	it.cancel()
+	for range it.items {
+	}
+	<-it.done
+	return it.err()
+}
@@ -0,0 +1,172 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package mapping
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastic/elastic-transport-go/v8/elastictransport"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/indices/getmapping"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// TypeConflict is the type of the fields mapped with different types in the indices.
+const TypeConflict = "conflict"
+
+// Fields maps the paths of the fields of a mapping to their type,
+// eg. "title" to "text", "title.raw" to "keyword" and "author" to "object".
+type Fields map[string]string
+
+// Flatten returns the fields of the mapping, including objects, nested objects and multi-fields.
+func Flatten(m *types.TypeMapping) (Fields, error) {
+	props, err := propertiesOf(m)
+	if err != nil {
+		return nil, fmt.Errorf("mapping: %s", err)
+	}
+	fields := make(Fields)
+	flatten("", props, fields)
+	return fields, nil
+}
+
+// flatten adds the properties at the prefix to the fields.
+func flatten(prefix string, props map[string]interface{}, fields Fields) {
+	for name, v := range props {
+		p, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		path := prefix + name
+		t, _ := typeOf(p).(string)
+		fields.add(path, t)
+
+		if sub, ok := p["properties"].(map[string]interface{}); ok {
+			flatten(path+".", sub, fields)
+		}
+		if sub, ok := p["fields"].(map[string]interface{}); ok {
+			flatten(path+".", sub, fields)
+		}
+	}
+}
+
+// add adds the field, with the conflict type when it exists with another type.
+func (f Fields) add(path, t string) {
+	if prev, ok := f[path]; ok && prev != t {
+		t = TypeConflict
+	}
+	f[path] = t
+}
+
+// Cache caches the fields of indices, read with the Get Mapping API.
+//
+// It is safe for concurrent use.
+type Cache struct {
+	client elastictransport.Interface
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	fields  Fields
+	expires time.Time
+}
+
+// NewCache creates a mapping cache, whose entries expire after the TTL.
+// Entries never expire with a TTL of zero.
+//
+// The client is a *elasticsearch.TypedClient or a *elasticsearch.Client.
+func NewCache(client elastictransport.Interface, ttl time.Duration) *Cache {
+	return &Cache{client: client, ttl: ttl, entries: make(map[string]cacheEntry)}
+}
+
+// Fields returns the fields of the indices, which may be aliases or patterns.
+// The fields of all matching indices are merged; fields with different types
+// in different indices have the conflict type.
+func (c *Cache) Fields(ctx context.Context, index ...string) (Fields, error) {
+	key := cacheKey(index)
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && (c.ttl == 0 || time.Now().Before(e.expires)) {
+		return e.fields, nil
+	}
+
+	req := getmapping.NewGetMappingFunc(c.client)()
+	if key != "" {
+		req.Index(key)
+	}
+	res, err := req.Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("mapping: get mapping [%s]: %w", key, err)
+	}
+
+	fields := make(Fields)
+	for _, name := range sortedKeys(res) {
+		m := res[name].Mappings
+		f, err := Flatten(&m)
+		if err != nil {
+			return nil, err
+		}
+		for path, t := range f {
+			fields.add(path, t)
+		}
+	}
+
+	c.mu.Lock()
+	c.entries[key] = cacheEntry{fields: fields, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+
+	return fields, nil
+}
+
+// Invalidate removes the indices from the cache, or all entries without indices.
+func (c *Cache) Invalidate(index ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(index) == 0 {
+		c.entries = make(map[string]cacheEntry)
+		return
+	}
+	delete(c.entries, cacheKey(index))
+}
+
+// cacheKey returns the index expression of the indices, sorted.
+func cacheKey(index []string) string {
+	index = append([]string(nil), index...)
+	sort.Strings(index)
+	return strings.Join(index, ",")
+}
+
+// sortedKeys returns the keys of the map, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
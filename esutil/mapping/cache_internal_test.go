@@ -0,0 +1,91 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package mapping
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// mockMappingCluster serves the mappings of two indices.
+type mockMappingCluster struct {
+	paths []string
+}
+
+func (m *mockMappingCluster) RoundTrip(req *http.Request) (*http.Response, error) {
+	m.paths = append(m.paths, req.URL.Path)
+	return &http.Response{
+		StatusCode: 200,
+		Body: ioutil.NopCloser(strings.NewReader(`{
+			"a":{"mappings":{"properties":{"title":{"type":"text","fields":{"raw":{"type":"keyword"}}},"n":{"type":"long"},"author":{"properties":{"name":{"type":"keyword"}}}}}},
+			"b":{"mappings":{"properties":{"title":{"type":"text"},"n":{"type":"keyword"},"comments":{"type":"nested","properties":{"text":{"type":"text"}}}}}}
+		}`)),
+		Header: http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+	}, nil
+}
+
+func TestCache(t *testing.T) {
+	cluster := &mockMappingCluster{}
+	es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: cluster})
+	c := NewCache(es, time.Hour)
+
+	fields, err := c.Fields(context.Background(), "b", "a")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	want := Fields{
+		"title":         "text",
+		"title.raw":     "keyword",
+		"n":             TypeConflict,
+		"author":        "object",
+		"author.name":   "keyword",
+		"comments":      "nested",
+		"comments.text": "text",
+	}
+	if len(fields) != len(want) {
+		t.Errorf("Unexpected fields: %v", fields)
+	}
+	for path, typ := range want {
+		if fields[path] != typ {
+			t.Errorf("Unexpected type for [%s]: want=%s, got=%s", path, typ, fields[path])
+		}
+	}
+
+	if _, err := c.Fields(context.Background(), "a", "b"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(cluster.paths) != 1 || cluster.paths[0] != "/a,b/_mapping" {
+		t.Errorf("Expected a single cached request, got: %v", cluster.paths)
+	}
+
+	c.Invalidate("a", "b")
+	c.Fields(context.Background(), "a", "b")
+	if len(cluster.paths) != 2 {
+		t.Errorf("Expected a request after invalidation, got: %v", cluster.paths)
+	}
+}
@@ -0,0 +1,210 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package mapping
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// The kinds of a mapping change.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeType    = "type"
+	ChangeParam   = "parameter"
+)
+
+// updatableParams are the parameters which can be changed on an existing field
+// with the Put Mapping API. Changes to the other parameters need a reindex.
+var updatableParams = map[string]bool{
+	"ignore_above":          true,
+	"search_analyzer":       true,
+	"search_quote_analyzer": true,
+	"meta":                  true,
+	"dynamic":               true,
+	"ignore_malformed":      true,
+	"boost":                 true,
+}
+
+// Change represents a difference between two mappings.
+type Change struct {
+	Path    string      // The dotted path of the field, eg. "authors.name"
+	Kind    string      // One of the Change constants
+	Param   string      // The parameter, for ChangeParam
+	Old     interface{} // The old type or parameter value
+	New     interface{} // The new type or parameter value
+	Reindex bool        // Whether the change needs a reindex
+}
+
+// String returns a readable description of the change.
+func (c Change) String() string {
+	var s string
+	switch c.Kind {
+	case ChangeAdded:
+		s = fmt.Sprintf("%s: added as %v", c.Path, c.New)
+	case ChangeRemoved:
+		s = fmt.Sprintf("%s: removed, it remains in the index mapping", c.Path)
+	case ChangeType:
+		s = fmt.Sprintf("%s: type changed from %v to %v", c.Path, c.Old, c.New)
+	default:
+		s = fmt.Sprintf("%s: %s changed from %v to %v", c.Path, c.Param, c.Old, c.New)
+	}
+	if c.Reindex {
+		s += " (needs reindex)"
+	}
+	return s
+}
+
+// Compare returns the differences between the current mapping of an index
+// and the desired one, eg. generated by For, sorted by path.
+//
+// Added fields and multi-fields, and changes to updatable parameters such as
+// ignore_above, can be applied with the Put Mapping API. Changes of types and
+// of the other parameters need a reindex into a new index.
+func Compare(current, desired *types.TypeMapping) ([]Change, error) {
+	c, err := propertiesOf(current)
+	if err != nil {
+		return nil, fmt.Errorf("mapping: %s", err)
+	}
+	d, err := propertiesOf(desired)
+	if err != nil {
+		return nil, fmt.Errorf("mapping: %s", err)
+	}
+
+	var changes []Change
+	compareProperties("", c, d, &changes)
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// NeedsReindex returns true when any change needs a reindex.
+func NeedsReindex(changes []Change) bool {
+	for _, c := range changes {
+		if c.Reindex {
+			return true
+		}
+	}
+	return false
+}
+
+// compareProperties adds the changes between the properties under the prefix.
+func compareProperties(prefix string, current, desired map[string]interface{}, changes *[]Change) {
+	for _, name := range sortedNames(current, desired) {
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+
+		cp, inCurrent := current[name].(map[string]interface{})
+		dp, inDesired := desired[name].(map[string]interface{})
+		switch {
+		case !inCurrent:
+			*changes = append(*changes, Change{Path: path, Kind: ChangeAdded, New: typeOf(dp)})
+			continue
+		case !inDesired:
+			*changes = append(*changes, Change{Path: path, Kind: ChangeRemoved, Old: typeOf(cp)})
+			continue
+		}
+
+		if ct, dt := typeOf(cp), typeOf(dp); ct != dt {
+			*changes = append(*changes, Change{Path: path, Kind: ChangeType, Old: ct, New: dt, Reindex: true})
+			continue
+		}
+
+		for _, param := range sortedNames(cp, dp) {
+			switch param {
+			case "type":
+				continue
+			case "properties":
+				cprops, _ := cp[param].(map[string]interface{})
+				dprops, _ := dp[param].(map[string]interface{})
+				compareProperties(path, cprops, dprops, changes)
+				continue
+			case "fields":
+				cfields, _ := cp[param].(map[string]interface{})
+				dfields, _ := dp[param].(map[string]interface{})
+				compareProperties(path, cfields, dfields, changes)
+				continue
+			}
+
+			cv, dv := cp[param], dp[param]
+			if jsonEqual(cv, dv) {
+				continue
+			}
+			*changes = append(*changes, Change{
+				Path:    path,
+				Kind:    ChangeParam,
+				Param:   param,
+				Old:     cv,
+				New:     dv,
+				Reindex: !updatableParams[param],
+			})
+		}
+	}
+}
+
+// propertiesOf returns the properties of the mapping, as decoded JSON.
+func propertiesOf(m *types.TypeMapping) (map[string]interface{}, error) {
+	if m == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(m.Properties)
+	if err != nil {
+		return nil, err
+	}
+	var props map[string]interface{}
+	if err := json.Unmarshal(b, &props); err != nil {
+		return nil, err
+	}
+	return props, nil
+}
+
+// typeOf returns the type of the property, object when it has none.
+func typeOf(p map[string]interface{}) interface{} {
+	if t, ok := p["type"]; ok {
+		return t
+	}
+	return "object"
+}
+
+// jsonEqual compares two decoded JSON values.
+func jsonEqual(a, b interface{}) bool {
+	ab, _ := json.Marshal(a)
+	bb, _ := json.Marshal(b)
+	return string(ab) == string(bb)
+}
+
+// sortedNames returns the union of the keys of the maps, sorted.
+func sortedNames(a, b map[string]interface{}) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var names []string
+	for _, m := range []map[string]interface{}{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				names = append(names, k)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
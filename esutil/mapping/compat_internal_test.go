@@ -0,0 +1,88 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package mapping
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+func TestCompare(t *testing.T) {
+	var current types.TypeMapping
+	json.Unmarshal([]byte(`{"properties":{
+		"id":{"type":"keyword"},
+		"title":{"type":"text","analyzer":"standard"},
+		"code":{"type":"keyword","ignore_above":128},
+		"views":{"type":"integer"},
+		"legacy":{"type":"keyword"},
+		"authors":{"type":"nested","properties":{"name":{"type":"text"}}}
+	}}`), &current)
+
+	desired, err := For(struct {
+		ID      string `json:"id"`
+		Title   string `json:"title" es:"text,analyzer=english,fields=raw:keyword"`
+		Code    string `json:"code" es:"keyword,ignore_above=256"`
+		Views   int64  `json:"views"`
+		Summary string `json:"summary" es:"text"`
+		Authors []struct {
+			Name  string `json:"name" es:"text"`
+			Email string `json:"email"`
+		} `json:"authors" es:"nested"`
+	}{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	changes, err := Compare(&current, desired)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var descriptions []string
+	for _, c := range changes {
+		descriptions = append(descriptions, c.String())
+	}
+	expected := []string{
+		"authors.email: added as keyword",
+		"code: ignore_above changed from 128 to 256",
+		"legacy: removed, it remains in the index mapping",
+		"summary: added as text",
+		"title: analyzer changed from standard to english (needs reindex)",
+		"title.raw: added as keyword",
+		"views: type changed from integer to long (needs reindex)",
+	}
+	if strings.Join(descriptions, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected changes:\n%s\nexpected:\n%s", strings.Join(descriptions, "\n"), strings.Join(expected, "\n"))
+	}
+	if !NeedsReindex(changes) {
+		t.Errorf("Expected changes to need a reindex")
+	}
+
+	t.Run("Compatible", func(t *testing.T) {
+		changes, _ := Compare(desired, desired)
+		if len(changes) != 0 || NeedsReindex(changes) {
+			t.Errorf("Unexpected changes: %v", changes)
+		}
+	})
+}
@@ -0,0 +1,333 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package mapping generates index mappings from Go types.
+//
+// The fields of a struct are mapped by their JSON name, and their type is
+// inferred from the Go type, or set with the "es" struct tag: the field type,
+// followed by its parameters.
+//
+//	type Article struct {
+//		ID       string    `json:"id" es:"keyword"`
+//		Title    string    `json:"title" es:"text,analyzer=english,fields=raw:keyword"`
+//		Tags     []string  `json:"tags"`
+//		Authors  []Author  `json:"authors" es:"nested"`
+//		Body     string    `json:"body" es:"text,index_options=offsets"`
+//		Internal string    `json:"internal" es:"-"`
+//		Created  time.Time `json:"created" es:",format=strict_date_optional_time"`
+//	}
+//
+// The inferred types are: keyword for strings, long, integer, short, byte and
+// unsigned_long for integers, double and float for floats, boolean, date for
+// time.Time, binary for []byte and object for structs and maps. Slices and
+// pointers are mapped as their element type. Fields of embedded structs are
+// promoted, and interface fields without a tag are left to dynamic mapping.
+//
+// The fields of existing indices and their types are read with a Cache,
+// eg. to check queries against the mapping.
+package mapping
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// For returns the mapping for the type of v, a struct or a pointer to a struct.
+func For(v interface{}) (*types.TypeMapping, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("mapping: expected a struct, got %T", v)
+	}
+
+	g := generator{visiting: make(map[reflect.Type]bool)}
+	props, err := g.properties(t)
+	if err != nil {
+		return nil, fmt.Errorf("mapping: %s: %s", t, err)
+	}
+
+	b, err := json.Marshal(map[string]interface{}{"properties": props})
+	if err != nil {
+		return nil, fmt.Errorf("mapping: %s: %s", t, err)
+	}
+	m := types.NewTypeMapping()
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("mapping: %s: %s", t, err)
+	}
+	return m, nil
+}
+
+// Properties returns the properties for the type of v, a struct or a pointer to a struct.
+func Properties(v interface{}) (map[string]types.Property, error) {
+	m, err := For(v)
+	if err != nil {
+		return nil, err
+	}
+	return m.Properties, nil
+}
+
+// generator builds the JSON representation of the properties.
+type generator struct {
+	visiting map[reflect.Type]bool // The struct types being mapped, to detect recursion
+}
+
+// properties returns the properties of the struct fields.
+func (g *generator) properties(t reflect.Type) (map[string]interface{}, error) {
+	if g.visiting[t] {
+		return nil, fmt.Errorf("recursive type %s", t)
+	}
+	g.visiting[t] = true
+	defer delete(g.visiting, t)
+
+	props := make(map[string]interface{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag, hasTag := f.Tag.Lookup("es")
+		if tag == "-" {
+			continue
+		}
+
+		name, skip := jsonName(f)
+		if skip {
+			continue
+		}
+
+		if f.Anonymous && !hasTag && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded, err := g.properties(ft)
+				if err != nil {
+					return nil, err
+				}
+				for k, v := range embedded {
+					if _, ok := props[k]; !ok {
+						props[k] = v
+					}
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop, err := g.property(f.Type, tag)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %s", f.Name, err)
+		}
+		if prop != nil {
+			props[name] = prop
+		}
+	}
+	return props, nil
+}
+
+// property returns the property for the type, with the parameters from the tag.
+// It returns nil for the types left to dynamic mapping.
+func (g *generator) property(t reflect.Type, tag string) (map[string]interface{}, error) {
+	typ, params, err := parseTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	// Map the element type of pointers and slices, except []byte.
+	for {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+			continue
+		}
+		if (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() != reflect.Uint8 {
+			t = t.Elem()
+			continue
+		}
+		break
+	}
+
+	if typ == "" {
+		typ = inferType(t)
+		if typ == "" {
+			if t.Kind() == reflect.Interface {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unsupported type %s", t)
+		}
+	}
+
+	prop := map[string]interface{}{"type": typ}
+	for k, v := range params {
+		if s, ok := v.(string); ok {
+			prop[k] = paramValue(typ, k, s)
+			continue
+		}
+		prop[k] = v
+	}
+
+	if (typ == "object" || typ == "nested") && t.Kind() == reflect.Struct && t != timeType {
+		props, err := g.properties(t)
+		if err != nil {
+			return nil, err
+		}
+		prop["properties"] = props
+	}
+	return prop, nil
+}
+
+// inferType returns the field type for the Go type, or an empty string when it can't be inferred.
+func inferType(t reflect.Type) string {
+	switch {
+	case t == timeType:
+		return "date"
+	case t == rawMessageType:
+		return "object"
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "keyword"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int64, reflect.Uint32:
+		return "long"
+	case reflect.Int32, reflect.Uint16:
+		return "integer"
+	case reflect.Int16, reflect.Uint8:
+		return "short"
+	case reflect.Int8:
+		return "byte"
+	case reflect.Uint, reflect.Uint64:
+		return "unsigned_long"
+	case reflect.Float64:
+		return "double"
+	case reflect.Float32:
+		return "float"
+	case reflect.Slice, reflect.Array:
+		return "binary" // Only []byte reaches here
+	case reflect.Struct, reflect.Map:
+		return "object"
+	}
+	return ""
+}
+
+// parseTag returns the type and parameters of the tag,
+// eg. "text,analyzer=english,fields=raw:keyword".
+// The values of the parameters are strings, see paramValue.
+func parseTag(tag string) (string, map[string]interface{}, error) {
+	if tag == "" {
+		return "", nil, nil
+	}
+
+	parts := strings.Split(tag, ",")
+	params := make(map[string]interface{})
+	for _, p := range parts[1:] {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok || k == "" {
+			return "", nil, fmt.Errorf("invalid tag parameter %q", p)
+		}
+		if k == "fields" {
+			name, typ, ok := strings.Cut(v, ":")
+			if !ok || name == "" || typ == "" {
+				return "", nil, fmt.Errorf("invalid multi-field %q, expected name:type", v)
+			}
+			fields, _ := params["fields"].(map[string]interface{})
+			if fields == nil {
+				fields = make(map[string]interface{})
+				params["fields"] = fields
+			}
+			fields[name] = map[string]interface{}{"type": typ}
+			continue
+		}
+		params[k] = v
+	}
+	return parts[0], params, nil
+}
+
+var (
+	// numberParams are the mapping parameters with a numeric value.
+	numberParams = map[string]bool{
+		"boost": true, "depth_limit": true, "dims": true, "ignore_above": true,
+		"max_shingle_size": true, "position_increment_gap": true, "scaling_factor": true,
+	}
+
+	// boolParams are the mapping parameters with a boolean value.
+	boolParams = map[string]bool{
+		"coerce": true, "doc_values": true, "dynamic": true, "eager_global_ordinals": true,
+		"enabled": true, "fielddata": true, "ignore_malformed": true, "ignore_z_value": true,
+		"include_in_parent": true, "include_in_root": true, "index": true, "index_phrases": true,
+		"norms": true, "positive_score_impact": true, "split_queries_on_whitespace": true,
+		"store": true, "subobjects": true,
+	}
+
+	// numberTypes are the field types with a numeric value.
+	numberTypes = map[string]bool{
+		"long": true, "integer": true, "short": true, "byte": true, "unsigned_long": true,
+		"double": true, "float": true, "half_float": true, "scaled_float": true,
+	}
+)
+
+// paramValue returns the value of a tag parameter by the expected type of the parameter,
+// and of the field for a null_value. The other parameters are kept as strings,
+// eg. a format or a keyword null_value of "123".
+func paramValue(typ, k, v string) interface{} {
+	switch {
+	case numberParams[k], k == "null_value" && numberTypes[typ]:
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return i
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	case boolParams[k], k == "null_value" && typ == "boolean":
+		// Only "true" and "false" are booleans, so that eg. "strict" is kept for dynamic.
+		switch v {
+		case "true":
+			return true
+		case "false":
+			return false
+		}
+	}
+	return v
+}
+
+// jsonName returns the name of the field from the json tag, and whether it is skipped.
+func jsonName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	return name, false
+}
@@ -0,0 +1,184 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package mapping
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+type testAuthor struct {
+	Name  string `json:"name" es:"text,fields=raw:keyword"`
+	Email string `json:"email"`
+}
+
+type testBase struct {
+	ID string `json:"id"`
+}
+
+type testArticle struct {
+	testBase
+	Title    string            `json:"title" es:"text,analyzer=english,fields=raw:keyword,fields=suggest:search_as_you_type"`
+	Tags     []string          `json:"tags,omitempty"`
+	Authors  []testAuthor      `json:"authors" es:"nested"`
+	Editor   *testAuthor       `json:"editor"`
+	Views    int64             `json:"views"`
+	Rating   float32           `json:"rating"`
+	Draft    bool              `json:"draft"`
+	Created  time.Time         `json:"created" es:",format=strict_date_optional_time"`
+	Code     string            `json:"code" es:"keyword,ignore_above=256,index=false"`
+	Thumb    []byte            `json:"thumb"`
+	Labels   map[string]string `json:"labels" es:"flattened"`
+	Extra    interface{}       `json:"extra"`
+	Internal string            `json:"internal" es:"-"`
+	Ignored  string            `json:"-"`
+	Untagged string
+	private  string
+	Meta     map[string]interface{} `json:"meta"`
+}
+
+type testRecursive struct {
+	Children []testRecursive `json:"children"`
+}
+
+func TestFor(t *testing.T) {
+	t.Run("Struct", func(t *testing.T) {
+		m, err := For(&testArticle{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if _, ok := m.Properties["title"].(*types.TextProperty); !ok {
+			t.Errorf("Expected *types.TextProperty, got %T", m.Properties["title"])
+		}
+		if _, ok := m.Properties["authors"].(*types.NestedProperty); !ok {
+			t.Errorf("Expected *types.NestedProperty, got %T", m.Properties["authors"])
+		}
+
+		b, _ := json.Marshal(m)
+		expected := `{"properties":{` +
+			`"Untagged":{"type":"keyword"},` +
+			`"authors":{"properties":{"email":{"type":"keyword"},"name":{"fields":{"raw":{"type":"keyword"}},"type":"text"}},"type":"nested"},` +
+			`"code":{"ignore_above":256,"index":false,"type":"keyword"},` +
+			`"created":{"format":"strict_date_optional_time","type":"date"},` +
+			`"draft":{"type":"boolean"},` +
+			`"editor":{"properties":{"email":{"type":"keyword"},"name":{"fields":{"raw":{"type":"keyword"}},"type":"text"}},"type":"object"},` +
+			`"id":{"type":"keyword"},` +
+			`"labels":{"type":"flattened"},` +
+			`"meta":{"type":"object"},` +
+			`"rating":{"type":"float"},` +
+			`"tags":{"type":"keyword"},` +
+			`"thumb":{"type":"binary"},` +
+			`"title":{"analyzer":"english","fields":{"raw":{"type":"keyword"},"suggest":{"type":"search_as_you_type"}},"type":"text"},` +
+			`"views":{"type":"long"}}}`
+		if string(b) != expected {
+			t.Errorf("Unexpected mapping:\n%s\nexpected:\n%s", b, expected)
+		}
+	})
+
+	t.Run("Not a struct", func(t *testing.T) {
+		if _, err := For("string"); err == nil {
+			t.Errorf("Expected error for a string")
+		}
+	})
+
+	t.Run("Recursive type", func(t *testing.T) {
+		_, err := For(testRecursive{})
+		if err == nil || !strings.Contains(err.Error(), "recursive type") {
+			t.Errorf("Expected recursive type error, got: %v", err)
+		}
+	})
+
+	t.Run("Invalid tag", func(t *testing.T) {
+		_, err := For(struct {
+			Name string `es:"text,fields=raw"`
+		}{})
+		if err == nil || !strings.Contains(err.Error(), "field Name: invalid multi-field") {
+			t.Errorf("Expected invalid multi-field error, got: %v", err)
+		}
+	})
+
+	t.Run("Parameter values", func(t *testing.T) {
+		for _, tt := range []struct {
+			typ, tag string
+			want     map[string]interface{}
+		}{
+			{
+				typ: "keyword",
+				tag: "keyword,null_value=123,normalizer=F,index=false,doc_values=true,ignore_above=256,boost=1.5",
+				want: map[string]interface{}{
+					"null_value":   "123",
+					"normalizer":   "F",
+					"index":        false,
+					"doc_values":   true,
+					"ignore_above": int64(256),
+					"boost":        1.5,
+				},
+			},
+			{
+				typ:  "long",
+				tag:  "long,null_value=0,coerce=false",
+				want: map[string]interface{}{"null_value": int64(0), "coerce": false},
+			},
+			{
+				typ:  "boolean",
+				tag:  "boolean,null_value=true",
+				want: map[string]interface{}{"null_value": true},
+			},
+			{
+				typ:  "date",
+				tag:  ",format=20060102,null_value=19700101",
+				want: map[string]interface{}{"format": "20060102", "null_value": "19700101"},
+			},
+			{
+				typ:  "object",
+				tag:  "object,dynamic=strict",
+				want: map[string]interface{}{"dynamic": "strict"},
+			},
+		} {
+			_, params, err := parseTag(tt.tag)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			got := make(map[string]interface{})
+			for k, v := range params {
+				got[k] = paramValue(tt.typ, k, v.(string))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unexpected parameters for %q: %#v", tt.tag, got)
+			}
+		}
+	})
+
+	t.Run("Unsupported type", func(t *testing.T) {
+		_, err := For(struct {
+			Ch chan int
+		}{})
+		if err == nil || !strings.Contains(err.Error(), "unsupported type chan int") {
+			t.Errorf("Expected unsupported type error, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,293 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package profile analyzes the profile of a search, eg. for slow query investigations.
+//
+// The profile of every shard is folded into a tree of components, the query clauses,
+// collectors, aggregations and fetch phases, with their timings summed across shards:
+//
+//	res, err := es.Search().Index("articles").Request(&search.Request{Profile: &profile, Query: q}).Do(ctx)
+//	a := profile.Analyze(res.Profile)
+//	for _, c := range a.Top(5) {
+//		fmt.Printf("%s: %s\n", c.Name(), time.Duration(c.SelfNanos))
+//	}
+//	a.WriteText(os.Stdout)
+//	a.WriteFolded(f) // eg. for flamegraph.pl or speedscope
+package profile
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// The kinds of components.
+const (
+	KindQuery       = "query"
+	KindRewrite     = "rewrite"
+	KindCollector   = "collector"
+	KindAggregation = "aggregation"
+	KindFetch       = "fetch"
+)
+
+// Component represents a query clause, collector, aggregation or fetch phase,
+// folded across the shards by its position in the tree.
+type Component struct {
+	Kind        string
+	Type        string // The type, eg. "BooleanQuery", or the name of a collector.
+	Description string // The description, eg. the Lucene query, or the reason of a collector.
+
+	TimeInNanos int64 // The time summed across shards, including the children.
+	SelfNanos   int64 // The time summed across shards, excluding the children.
+
+	Shards       int    // The number of shards which ran the component.
+	MaxNanos     int64  // The time of the slowest shard, including the children.
+	SlowestShard string // The ID of the slowest shard.
+
+	// Breakdown contains the timings and counts of the low level methods,
+	// eg. "next_doc" and "next_doc_count", summed across shards.
+	Breakdown map[string]int64
+
+	Parent   *Component
+	Children []*Component
+
+	key string
+}
+
+// Name returns the type and the description of the component.
+func (c *Component) Name() string {
+	if c.Description == "" {
+		return c.Type
+	}
+	return c.Type + " [" + c.Description + "]"
+}
+
+// Path returns the names of the component and of its parents, from the root.
+func (c *Component) Path() []string {
+	var path []string
+	for p := c; p != nil; p = p.Parent {
+		path = append([]string{p.Name()}, path...)
+	}
+	return path
+}
+
+// Depth returns the number of parents of the component.
+func (c *Component) Depth() int {
+	var n int
+	for p := c.Parent; p != nil; p = p.Parent {
+		n++
+	}
+	return n
+}
+
+// Shard represents the timings of a shard.
+type Shard struct {
+	ID string // The ID of the shard, eg. "[node][index][0]".
+
+	QueryNanos       int64
+	RewriteNanos     int64
+	CollectorNanos   int64
+	AggregationNanos int64
+	FetchNanos       int64
+}
+
+// TimeInNanos returns the total time of the shard.
+//
+// The time of the collectors includes the time of the query and of the aggregations,
+// which run as documents are collected; it is counted once.
+func (s Shard) TimeInNanos() int64 {
+	search := s.QueryNanos + s.AggregationNanos
+	if s.CollectorNanos > search {
+		search = s.CollectorNanos
+	}
+	return search + s.RewriteNanos + s.FetchNanos
+}
+
+// Analysis represents the profile folded across shards.
+type Analysis struct {
+	// Roots contains the root components, by kind: queries, rewrite, collectors,
+	// aggregations and fetch phases, in the order of the profile.
+	Roots []*Component
+
+	// Shards contains the timings of every shard, the slowest first.
+	Shards []Shard
+
+	index map[string]*Component
+}
+
+// Analyze folds the profile of the shards.
+func Analyze(p *types.Profile) *Analysis {
+	a := Analysis{index: make(map[string]*Component)}
+	if p == nil {
+		return &a
+	}
+
+	for _, sp := range p.Shards {
+		shard := Shard{ID: sp.Id}
+
+		for _, s := range sp.Searches {
+			for _, q := range s.Query {
+				shard.QueryNanos += q.TimeInNanos
+				a.addQuery(sp.Id, nil, q)
+			}
+			if s.RewriteTime > 0 {
+				shard.RewriteNanos += s.RewriteTime
+				a.add(sp.Id, nil, KindRewrite, "rewrite", "", s.RewriteTime, nil)
+			}
+			for _, c := range s.Collector {
+				shard.CollectorNanos += c.TimeInNanos
+				a.addCollector(sp.Id, nil, c)
+			}
+		}
+		for _, agg := range sp.Aggregations {
+			shard.AggregationNanos += agg.TimeInNanos
+			a.addAggregation(sp.Id, nil, agg)
+		}
+		if sp.Fetch != nil {
+			shard.FetchNanos += sp.Fetch.TimeInNanos
+			a.addFetch(sp.Id, nil, *sp.Fetch)
+		}
+
+		a.Shards = append(a.Shards, shard)
+	}
+
+	a.walk(func(c *Component) {
+		c.SelfNanos = c.TimeInNanos
+		for _, child := range c.Children {
+			c.SelfNanos -= child.TimeInNanos
+		}
+		// The timings of the children can overlap, eg. with concurrent segment search.
+		if c.SelfNanos < 0 {
+			c.SelfNanos = 0
+		}
+	})
+
+	sort.SliceStable(a.Shards, func(i, j int) bool { return a.Shards[i].TimeInNanos() > a.Shards[j].TimeInNanos() })
+	return &a
+}
+
+// Components returns all the components, depth first.
+func (a *Analysis) Components() []*Component {
+	var components []*Component
+	a.walk(func(c *Component) { components = append(components, c) })
+	return components
+}
+
+// Top returns the n most expensive components, by their own time, excluding their children.
+// It returns all the components when n is zero.
+func (a *Analysis) Top(n int) []*Component {
+	components := a.Components()
+	sort.SliceStable(components, func(i, j int) bool { return components[i].SelfNanos > components[j].SelfNanos })
+	if n > 0 && n < len(components) {
+		components = components[:n]
+	}
+	return components
+}
+
+// TimeInNanos returns the time of the components of the kind, summed across shards.
+func (a *Analysis) TimeInNanos(kind string) int64 {
+	var t int64
+	for _, c := range a.Roots {
+		if c.Kind == kind {
+			t += c.TimeInNanos
+		}
+	}
+	return t
+}
+
+// walk calls fn for every component, depth first.
+func (a *Analysis) walk(fn func(*Component)) {
+	var visit func(*Component)
+	visit = func(c *Component) {
+		fn(c)
+		for _, child := range c.Children {
+			visit(child)
+		}
+	}
+	for _, c := range a.Roots {
+		visit(c)
+	}
+}
+
+// add folds the timings of a shard into the component of the parent with the kind, type
+// and description, and returns it.
+func (a *Analysis) add(shard string, parent *Component, kind, typ, description string, nanos int64, breakdown interface{}) *Component {
+	key := kind + "\x00" + typ + "\x00" + description
+	if parent != nil {
+		key = parent.key + "\x01" + key
+	}
+
+	c, ok := a.index[key]
+	if !ok {
+		c = &Component{Kind: kind, Type: typ, Description: description, Parent: parent, key: key}
+		a.index[key] = c
+		if parent != nil {
+			parent.Children = append(parent.Children, c)
+		} else {
+			a.Roots = append(a.Roots, c)
+		}
+	}
+
+	c.TimeInNanos += nanos
+	c.Shards++
+	if nanos > c.MaxNanos || c.SlowestShard == "" {
+		c.MaxNanos = nanos
+		c.SlowestShard = shard
+	}
+
+	if breakdown != nil {
+		var m map[string]int64
+		if b, err := json.Marshal(breakdown); err == nil && json.Unmarshal(b, &m) == nil {
+			if c.Breakdown == nil {
+				c.Breakdown = make(map[string]int64, len(m))
+			}
+			for k, v := range m {
+				c.Breakdown[k] += v
+			}
+		}
+	}
+	return c
+}
+
+func (a *Analysis) addQuery(shard string, parent *Component, q types.QueryProfile) {
+	c := a.add(shard, parent, KindQuery, q.Type, q.Description, q.TimeInNanos, q.Breakdown)
+	for _, child := range q.Children {
+		a.addQuery(shard, c, child)
+	}
+}
+
+func (a *Analysis) addCollector(shard string, parent *Component, col types.Collector) {
+	c := a.add(shard, parent, KindCollector, col.Name, col.Reason, col.TimeInNanos, nil)
+	for _, child := range col.Children {
+		a.addCollector(shard, c, child)
+	}
+}
+
+func (a *Analysis) addAggregation(shard string, parent *Component, agg types.AggregationProfile) {
+	c := a.add(shard, parent, KindAggregation, agg.Type, agg.Description, agg.TimeInNanos, agg.Breakdown)
+	for _, child := range agg.Children {
+		a.addAggregation(shard, c, child)
+	}
+}
+
+func (a *Analysis) addFetch(shard string, parent *Component, f types.FetchProfile) {
+	c := a.add(shard, parent, KindFetch, f.Type, f.Description, f.TimeInNanos, f.Breakdown)
+	for _, child := range f.Children {
+		a.addFetch(shard, c, child)
+	}
+}
@@ -0,0 +1,159 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package profile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
+)
+
+const testProfile = `{"hits":{"hits":[]},"profile":{"shards":[
+	{"id":"[n1][articles][0]",
+	 "searches":[{"rewrite_time":10,
+		"query":[{"type":"BooleanQuery","description":"+a +b","time_in_nanos":100,"breakdown":{"next_doc":40,"next_doc_count":4,"score":10,"score_count":2},"children":[
+			{"type":"TermQuery","description":"a","time_in_nanos":30,"breakdown":{}},
+			{"type":"TermQuery","description":"b","time_in_nanos":50,"breakdown":{}}]}],
+		"collector":[{"name":"QueryPhaseCollector","reason":"search_query_phase","time_in_nanos":200,"children":[
+			{"name":"SimpleTopScoreDocCollector","reason":"search_top_hits","time_in_nanos":40}]}]}],
+	 "aggregations":[{"type":"StringTermsAggregator","description":"tags","time_in_nanos":60,"breakdown":{"collect":60,"collect_count":3}}],
+	 "fetch":{"type":"fetch","description":"","time_in_nanos":20,"breakdown":{},"children":[
+		{"type":"FetchSourcePhase","description":"","time_in_nanos":5,"breakdown":{}}]}},
+	{"id":"[n2][articles][1]",
+	 "searches":[{"rewrite_time":0,
+		"query":[{"type":"BooleanQuery","description":"+a +b","time_in_nanos":200,"breakdown":{"next_doc":100,"next_doc_count":6},"children":[
+			{"type":"TermQuery","description":"a","time_in_nanos":100,"breakdown":{}},
+			{"type":"TermQuery","description":"b","time_in_nanos":50,"breakdown":{}}]}],
+		"collector":[{"name":"QueryPhaseCollector","reason":"search_query_phase","time_in_nanos":100,"children":[
+			{"name":"SimpleTopScoreDocCollector","reason":"search_top_hits","time_in_nanos":20}]}]}],
+	 "aggregations":[]}]}}`
+
+func testAnalysis(t *testing.T) *Analysis {
+	t.Helper()
+	res := search.NewResponse()
+	if err := json.Unmarshal([]byte(testProfile), res); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	return Analyze(res.Profile)
+}
+
+func TestAnalyze(t *testing.T) {
+	a := testAnalysis(t)
+
+	t.Run("Fold", func(t *testing.T) {
+		var roots []string
+		for _, c := range a.Roots {
+			roots = append(roots, c.Kind)
+		}
+		if fmt.Sprint(roots) != "[query rewrite collector aggregation fetch]" {
+			t.Fatalf("Unexpected roots: %v", roots)
+		}
+
+		q := a.Roots[0]
+		if q.TimeInNanos != 300 || q.SelfNanos != 70 || q.Shards != 2 || q.MaxNanos != 200 || q.SlowestShard != "[n2][articles][1]" {
+			t.Errorf("Unexpected query: %+v", q)
+		}
+		if q.Breakdown["next_doc"] != 140 || q.Breakdown["next_doc_count"] != 10 || q.Breakdown["score"] != 10 {
+			t.Errorf("Unexpected breakdown: %v", q.Breakdown)
+		}
+		if len(q.Children) != 2 || q.Children[0].TimeInNanos != 130 {
+			t.Errorf("Unexpected children: %+v", q.Children)
+		}
+		if p := fmt.Sprint(q.Children[1].Path()); p != "[BooleanQuery [+a +b] TermQuery [b]]" {
+			t.Errorf("Unexpected path: %s", p)
+		}
+		if n := a.TimeInNanos(KindCollector); n != 300 {
+			t.Errorf("Unexpected collector time: %d", n)
+		}
+	})
+
+	t.Run("Top", func(t *testing.T) {
+		var top []string
+		for _, c := range a.Top(3) {
+			top = append(top, fmt.Sprintf("%s=%d", c.Type, c.SelfNanos))
+		}
+		if fmt.Sprint(top) != "[QueryPhaseCollector=240 TermQuery=130 TermQuery=100]" {
+			t.Errorf("Unexpected top components: %v", top)
+		}
+		if n := len(a.Top(0)); n != 9 {
+			t.Errorf("Unexpected number of components: %d", n)
+		}
+	})
+
+	t.Run("Shards", func(t *testing.T) {
+		if len(a.Shards) != 2 || a.Shards[0].ID != "[n1][articles][0]" || a.Shards[0].TimeInNanos() != 230 || a.Shards[1].TimeInNanos() != 200 {
+			t.Errorf("Unexpected shards: %+v", a.Shards)
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		if a := Analyze(nil); len(a.Roots) != 0 || len(a.Top(10)) != 0 {
+			t.Errorf("Unexpected analysis: %+v", a)
+		}
+	})
+}
+
+func TestRender(t *testing.T) {
+	a := testAnalysis(t)
+
+	t.Run("Folded", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := a.WriteFolded(&buf); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		expected := strings.Join([]string{
+			"query;BooleanQuery [+a +b] 70",
+			"query;BooleanQuery [+a +b];TermQuery [a] 130",
+			"query;BooleanQuery [+a +b];TermQuery [b] 100",
+			"rewrite;rewrite 10",
+			"collector;QueryPhaseCollector [search_query_phase] 240",
+			"collector;QueryPhaseCollector [search_query_phase];SimpleTopScoreDocCollector [search_top_hits] 60",
+			"aggregation;StringTermsAggregator [tags] 60",
+			"fetch;fetch 15",
+			"fetch;fetch;FetchSourcePhase 5",
+		}, "\n") + "\n"
+		if buf.String() != expected {
+			t.Errorf("Unexpected output:\n%s", buf.String())
+		}
+	})
+
+	t.Run("Text", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := a.WriteText(&buf); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		out := buf.String()
+		for _, s := range []string{
+			"query            300ns       70ns  BooleanQuery [+a +b] (2 shards, max 200ns on [n2][articles][1])",
+			"\n                                     next_doc=140ns/10 score=10ns/2\n",
+			"query            130ns      130ns    TermQuery [a]",
+			"1               230ns      100ns       10ns       60ns       20ns  [n1][articles][0]",
+		} {
+			if !strings.Contains(out, s) {
+				t.Errorf("Expected %q in output:\n%s", s, out)
+			}
+		}
+	})
+}
@@ -0,0 +1,140 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package profile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WriteText writes the tree of the components, with their total and own time,
+// and the slowest shards.
+func (a *Analysis) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	var visit func(*Component)
+	visit = func(c *Component) {
+		indent := strings.Repeat("  ", c.Depth())
+		fmt.Fprintf(bw, "%-11s %10s %10s  %s%s (%d shards, max %s on %s)\n",
+			c.Kind,
+			duration(c.TimeInNanos),
+			duration(c.SelfNanos),
+			indent,
+			c.Name(),
+			c.Shards,
+			duration(c.MaxNanos),
+			c.SlowestShard,
+		)
+		if s := breakdown(c.Breakdown); s != "" {
+			fmt.Fprintf(bw, "%-35s%s  %s\n", "", indent, s)
+		}
+		for _, child := range c.Children {
+			visit(child)
+		}
+	}
+
+	fmt.Fprintf(bw, "%-11s %10s %10s  %s\n", "KIND", "TOTAL", "SELF", "COMPONENT")
+	for _, c := range a.Roots {
+		visit(c)
+	}
+
+	if len(a.Shards) > 0 {
+		fmt.Fprintf(bw, "\n%-10s %10s %10s %10s %10s %10s  %s\n", "SHARD", "TOTAL", "QUERY", "REWRITE", "AGGS", "FETCH", "ID")
+		for i, s := range a.Shards {
+			fmt.Fprintf(bw, "%-10d %10s %10s %10s %10s %10s  %s\n",
+				i+1,
+				duration(s.TimeInNanos()),
+				duration(s.QueryNanos),
+				duration(s.RewriteNanos),
+				duration(s.AggregationNanos),
+				duration(s.FetchNanos),
+				s.ID,
+			)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// WriteFolded writes the components as folded stacks, one line per component
+// with the path from the root separated by semicolons and the own time in nanoseconds,
+// the input of flame graph tools such as flamegraph.pl, inferno or speedscope.
+func (a *Analysis) WriteFolded(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	var visit func(c *Component, stack string)
+	visit = func(c *Component, stack string) {
+		if stack == "" {
+			stack = c.Kind + ";" + frame(c)
+		} else {
+			stack += ";" + frame(c)
+		}
+		if c.SelfNanos > 0 {
+			fmt.Fprintf(bw, "%s %d\n", stack, c.SelfNanos)
+		}
+		for _, child := range c.Children {
+			visit(child, stack)
+		}
+	}
+	for _, c := range a.Roots {
+		visit(c, "")
+	}
+
+	return bw.Flush()
+}
+
+// frame returns the name of the component for a folded stack,
+// which cannot contain semicolons or line breaks.
+func frame(c *Component) string {
+	return strings.NewReplacer(";", ",", "\n", " ", "\r", " ").Replace(c.Name())
+}
+
+// breakdown formats the timings of the breakdown, the most expensive first, with their counts.
+func breakdown(b map[string]int64) string {
+	var keys []string
+	for k, v := range b {
+		if v > 0 && !strings.HasSuffix(k, "_count") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if b[keys[i]] != b[keys[j]] {
+			return b[keys[i]] > b[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%s/%d", k, duration(b[k]), b[k+"_count"]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// duration formats the nanoseconds with a precision of a microsecond.
+func duration(nanos int64) string {
+	d := time.Duration(nanos)
+	if d >= time.Microsecond {
+		d = d.Round(time.Microsecond)
+	}
+	return d.String()
+}
@@ -0,0 +1,137 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package qbe
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/esutil/mapping"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// Builder builds the queries by example of indices, with their cached mapping.
+type Builder struct {
+	cache *mapping.Cache
+	index []string
+}
+
+// New creates a builder for the indices, which may be aliases or patterns.
+func New(cache *mapping.Cache, index ...string) *Builder {
+	return &Builder{cache: cache, index: index}
+}
+
+// Query returns the query of the documents which look like the example, see FromExample.
+func (b *Builder) Query(ctx context.Context, example interface{}) (*types.Query, error) {
+	fields, err := b.cache.Fields(ctx, b.index...)
+	if err != nil {
+		return nil, fmt.Errorf("qbe: %w", err)
+	}
+	return FromExample(fields, example)
+}
+
+// MoreLikeThisConfig represents the configuration of a more_like_this query.
+type MoreLikeThisConfig struct {
+	// Fields are the fields to compare. Defaults to the text and keyword fields of the indices.
+	Fields []string
+
+	// Index is the index of the document. Defaults to the index of the builder,
+	// when it is a single index, or to the indices of the search.
+	Index string
+
+	// Parameters of the query; Elasticsearch uses its defaults when they are not set.
+	MinTermFreq        int    // The minimum frequency of a term in the document, 2 by default.
+	MinDocFreq         int    // The minimum number of documents with a term, 5 by default.
+	MaxQueryTerms      int    // The maximum number of terms of the query, 25 by default.
+	MinimumShouldMatch string // The minimum number of terms to match, 30% by default.
+
+	Include bool // Include the document in the results.
+}
+
+// MoreLikeThis returns a more_like_this query of the documents similar to the document with the ID.
+func (b *Builder) MoreLikeThis(ctx context.Context, id string, cfg MoreLikeThisConfig) (*types.Query, error) {
+	if len(cfg.Fields) == 0 {
+		fields, err := b.cache.Fields(ctx, b.index...)
+		if err != nil {
+			return nil, fmt.Errorf("qbe: %w", err)
+		}
+		cfg.Fields = TextFields(fields)
+		if len(cfg.Fields) == 0 {
+			return nil, fmt.Errorf("qbe: no text or keyword fields in [%s]", strings.Join(b.index, ","))
+		}
+	}
+	if cfg.Index == "" && len(b.index) == 1 && !strings.ContainsAny(b.index[0], "*,") {
+		cfg.Index = b.index[0]
+	}
+	return MoreLikeThis(id, cfg), nil
+}
+
+// MoreLikeThis returns a more_like_this query of the documents similar to the document with the ID.
+func MoreLikeThis(id string, cfg MoreLikeThisConfig) *types.Query {
+	doc := types.LikeDocument{Id_: &id}
+	if cfg.Index != "" {
+		doc.Index_ = &cfg.Index
+	}
+
+	q := types.MoreLikeThisQuery{Like: []types.Like{doc}, Fields: cfg.Fields}
+	if cfg.MinTermFreq > 0 {
+		q.MinTermFreq = &cfg.MinTermFreq
+	}
+	if cfg.MinDocFreq > 0 {
+		q.MinDocFreq = &cfg.MinDocFreq
+	}
+	if cfg.MaxQueryTerms > 0 {
+		q.MaxQueryTerms = &cfg.MaxQueryTerms
+	}
+	if cfg.MinimumShouldMatch != "" {
+		q.MinimumShouldMatch = cfg.MinimumShouldMatch
+	}
+	if cfg.Include {
+		q.Include = &cfg.Include
+	}
+	return &types.Query{MoreLikeThis: &q}
+}
+
+// TextFields returns the text and keyword fields of the mapping, sorted, excluding
+// the fields within nested objects, which more_like_this does not support.
+func TextFields(fields mapping.Fields) []string {
+	var text []string
+	for path, t := range fields {
+		if t != "text" && t != "keyword" && t != "match_only_text" {
+			continue
+		}
+		if inNested(fields, path) {
+			continue
+		}
+		text = append(text, path)
+	}
+	sort.Strings(text)
+	return text
+}
+
+// inNested returns true when a parent of the field is a nested object.
+func inNested(fields mapping.Fields, path string) bool {
+	for i := strings.LastIndexByte(path, '.'); i > 0; i = strings.LastIndexByte(path[:i], '.') {
+		if fields[path[:i]] == "nested" {
+			return true
+		}
+	}
+	return false
+}
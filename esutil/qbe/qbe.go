@@ -0,0 +1,403 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package qbe builds queries by example: the query of the documents which look like
+// a partially filled Go struct or map.
+//
+// The query of every field of the example is chosen by its type in the mapping of the indices:
+// a term query for keyword, numeric, date, boolean and ip fields, a match query for text fields,
+// a range query for the Range values, and a nested query for the nested objects:
+//
+//	type Article struct {
+//		Title    string     `json:"title"`
+//		Status   string     `json:"status"`
+//		Views    *qbe.Range `json:"views,omitempty"`
+//		Comments []Comment  `json:"comments"`
+//	}
+//
+//	b := qbe.New(mapping.NewCache(es, time.Minute), "articles")
+//	query, err := b.Query(ctx, Article{
+//		Title:    "go generics",
+//		Status:   "published",
+//		Views:    &qbe.Range{Min: 100},
+//		Comments: []Comment{{Author: "alice"}},
+//	})
+//	res, err := es.Search().Index("articles").Query(query).Do(ctx)
+//
+// The fields of a struct with a zero value are not part of the example; use a pointer,
+// eg. *bool or *int, or a map to match zero values.
+package qbe
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esutil/mapping"
+	"github.com/elastic/go-elasticsearch/v8/esutil/querydsl"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// Range represents the bounds of a range query, inclusive; a nil bound is unbounded.
+//
+// Objects of a map example with only "min" and "max" keys are ranges as well,
+// eg. {"views": {"min": 100, "max": 1000}}.
+type Range struct {
+	Min interface{} `json:"min,omitempty"`
+	Max interface{} `json:"max,omitempty"`
+}
+
+// FromExample returns the query of the documents which look like the example,
+// a struct, a pointer to a struct, or a map with string keys, with the fields of the mapping.
+//
+// Term and range queries are filters, match queries are scored. Every value of a list
+// must match. It returns an error for the fields which are not mapped, or mapped with
+// a type that is not supported.
+func FromExample(fields mapping.Fields, example interface{}) (*types.Query, error) {
+	value, ok := generic(reflect.ValueOf(example))
+	if !ok {
+		return querydsl.MatchAll().Query(), nil
+	}
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("qbe: example must be a struct or a map, got %T", example)
+	}
+
+	var b clauses
+	if err := b.object(fields, "", obj); err != nil {
+		return nil, err
+	}
+	return b.query(), nil
+}
+
+// clauses represents the clauses of a bool query.
+type clauses struct {
+	must   []querydsl.Builder
+	filter []querydsl.Builder
+}
+
+// query returns the bool query of the clauses, or a match_all query without clauses.
+func (c *clauses) query() *types.Query {
+	if len(c.must) == 0 && len(c.filter) == 0 {
+		return querydsl.MatchAll().Query()
+	}
+	return querydsl.Bool().Must(c.must...).Filter(c.filter...).Query()
+}
+
+// object adds the clauses of the fields of the object at the prefix.
+func (c *clauses) object(fields mapping.Fields, prefix string, obj map[string]interface{}) error {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if err := c.field(fields, prefix+k, obj[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// field adds the clauses of the value of the field at the path.
+func (c *clauses) field(fields mapping.Fields, path string, value interface{}) error {
+	t, ok := fields[path]
+	if !ok {
+		return fmt.Errorf("qbe: field [%s] is not mapped", path)
+	}
+
+	if r, ok := rangeOf(value); ok && t != "object" && t != "nested" {
+		q, err := rangeQuery(path, t, r)
+		if err != nil {
+			return err
+		}
+		c.filter = append(c.filter, q)
+		return nil
+	}
+
+	switch t {
+	case "object":
+		return c.each(value, func(v interface{}) error {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("qbe: field [%s]: expected an object, got %T", path, v)
+			}
+			return c.object(fields, path+".", obj)
+		})
+
+	case "nested":
+		return c.each(value, func(v interface{}) error {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("qbe: field [%s]: expected an object, got %T", path, v)
+			}
+			// Every object matches a single nested document.
+			var nested clauses
+			if err := nested.object(fields, path+".", obj); err != nil {
+				return err
+			}
+			c.filter = append(c.filter, querydsl.Nested(path, querydsl.Wrap(nested.query())))
+			return nil
+		})
+
+	case "text", "match_only_text", "search_as_you_type", "annotated_text":
+		return c.each(value, func(v interface{}) error {
+			if !isScalar(v) {
+				return fmt.Errorf("qbe: field [%s]: expected a value, got %T", path, v)
+			}
+			c.must = append(c.must, querydsl.Match(path, fmt.Sprint(v)))
+			return nil
+		})
+
+	case "keyword", "constant_keyword", "wildcard", "version", "ip", "boolean",
+		"long", "integer", "short", "byte", "double", "float", "half_float", "scaled_float", "unsigned_long",
+		"date", "date_nanos":
+		return c.each(value, func(v interface{}) error {
+			if !isScalar(v) {
+				return fmt.Errorf("qbe: field [%s]: expected a value, got %T", path, v)
+			}
+			c.filter = append(c.filter, querydsl.Wrap(&types.Query{Term: map[string]types.TermQuery{path: {Value: v}}}))
+			return nil
+		})
+
+	case mapping.TypeConflict:
+		return fmt.Errorf("qbe: field [%s] is mapped with different types", path)
+	}
+
+	return fmt.Errorf("qbe: field [%s]: unsupported type [%s]", path, t)
+}
+
+// each calls fn with the value, or with every element of a list.
+func (c *clauses) each(value interface{}, fn func(interface{}) error) error {
+	if list, ok := value.([]interface{}); ok {
+		for _, v := range list {
+			if err := fn(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fn(value)
+}
+
+// rangeQuery returns the range query of the field of the type.
+func rangeQuery(path, t string, r Range) (querydsl.Builder, error) {
+	switch t {
+	case "long", "integer", "short", "byte", "double", "float", "half_float", "scaled_float", "unsigned_long":
+		q := querydsl.NumberRange(path)
+		for _, b := range []struct {
+			v   interface{}
+			set func(float64) *querydsl.NumberRangeBuilder
+		}{{r.Min, q.Gte}, {r.Max, q.Lte}} {
+			if b.v == nil {
+				continue
+			}
+			f, ok := toFloat(b.v)
+			if !ok {
+				return nil, fmt.Errorf("qbe: field [%s]: expected a number for the range, got %T", path, b.v)
+			}
+			b.set(f)
+		}
+		return q, nil
+
+	case "date", "date_nanos", "keyword", "ip", "version":
+		q := querydsl.Range(path)
+		if r.Min != nil {
+			q.GteExpr(fmt.Sprint(r.Min))
+		}
+		if r.Max != nil {
+			q.LteExpr(fmt.Sprint(r.Max))
+		}
+		return q, nil
+	}
+	return nil, fmt.Errorf("qbe: field [%s]: unsupported type [%s] for a range", path, t)
+}
+
+// rangeOf returns the range of a Range value or of an object with only min and max.
+func rangeOf(value interface{}) (Range, bool) {
+	switch v := value.(type) {
+	case Range:
+		return v, true
+	case map[string]interface{}:
+		if len(v) == 0 || len(v) > 2 {
+			return Range{}, false
+		}
+		var r Range
+		for k, bound := range v {
+			switch k {
+			case "min":
+				r.Min = bound
+			case "max":
+				r.Max = bound
+			default:
+				return Range{}, false
+			}
+		}
+		return r, true
+	}
+	return Range{}, false
+}
+
+// generic converts the value to maps, lists and scalar values; it returns false for empty values,
+// eg. the zero values of the fields of a struct.
+func generic(v reflect.Value) (interface{}, bool) {
+	if !v.IsValid() {
+		return nil, false
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+
+	if !v.CanInterface() {
+		return nil, false
+	}
+	if v.Type() == reflect.TypeOf(Range{}) {
+		r := v.Interface().(Range)
+		if r.Min == nil && r.Max == nil {
+			return nil, false
+		}
+		if min, ok := generic(reflect.ValueOf(r.Min)); ok {
+			r.Min = min
+		} else {
+			r.Min = nil
+		}
+		if max, ok := generic(reflect.ValueOf(r.Max)); ok {
+			r.Max = max
+		} else {
+			r.Max = nil
+		}
+		return r, true
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339Nano), true
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok && v.Kind() != reflect.String {
+		b, err := m.MarshalText()
+		if err != nil {
+			return nil, false
+		}
+		return string(b), true
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return v.Bool(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint(), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+
+	case reflect.Slice, reflect.Array:
+		var list []interface{}
+		for i := 0; i < v.Len(); i++ {
+			if e, ok := generic(v.Index(i)); ok {
+				list = append(list, e)
+			}
+		}
+		return list, len(list) > 0
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		obj := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			if e, ok := generic(iter.Value()); ok {
+				obj[iter.Key().String()] = e
+			}
+		}
+		return obj, len(obj) > 0
+
+	case reflect.Struct:
+		obj := make(map[string]interface{})
+		structFields(v, obj)
+		return obj, len(obj) > 0
+	}
+	return nil, false
+}
+
+// structFields adds the fields of the struct with a value to the object,
+// by their JSON name, including the fields of the embedded structs.
+func structFields(v reflect.Value, obj map[string]interface{}) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		f := v.Field(i)
+		if sf.Anonymous && name == "" {
+			for f.Kind() == reflect.Ptr {
+				if f.IsNil() {
+					break
+				}
+				f = f.Elem()
+			}
+			if f.Kind() == reflect.Struct {
+				structFields(f, obj)
+				continue
+			}
+		}
+		if !sf.IsExported() || f.IsZero() {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+		if e, ok := generic(f); ok {
+			obj[name] = e
+		}
+	}
+}
+
+// isScalar returns true when the value is not an object or a list.
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}, Range:
+		return false
+	}
+	return true
+}
+
+// toFloat returns the number as a float64.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,211 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package qbe
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esutil/mapping"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+var testFields = mapping.Fields{
+	"title":          "text",
+	"title.raw":      "keyword",
+	"status":         "keyword",
+	"views":          "long",
+	"published":      "date",
+	"draft":          "boolean",
+	"tags":           "keyword",
+	"location":       "geo_point",
+	"author":         "object",
+	"author.name":    "keyword",
+	"comments":       "nested",
+	"comments.text":  "text",
+	"comments.stars": "integer",
+	"n":              mapping.TypeConflict,
+}
+
+type comment struct {
+	Text  string `json:"text,omitempty"`
+	Stars int    `json:"stars,omitempty"`
+}
+
+type author struct {
+	Name string `json:"name"`
+}
+
+type article struct {
+	Title     string    `json:"title"`
+	Status    string    `json:"status"`
+	Views     *Range    `json:"views,omitempty"`
+	Draft     *bool     `json:"draft,omitempty"`
+	Tags      []string  `json:"tags"`
+	Author    author    `json:"author"`
+	Comments  []comment `json:"comments"`
+	Published time.Time `json:"published"`
+	Internal  string    `json:"-"`
+}
+
+func encode(t *testing.T, q *types.Query) string {
+	t.Helper()
+	b, err := json.Marshal(q)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	return string(b)
+}
+
+func TestFromExample(t *testing.T) {
+	t.Run("Struct", func(t *testing.T) {
+		draft := false
+		q, err := FromExample(testFields, &article{
+			Title:    "go generics",
+			Status:   "published",
+			Views:    &Range{Min: 100},
+			Draft:    &draft,
+			Tags:     []string{"go", "generics"},
+			Author:   author{Name: "alice"},
+			Comments: []comment{{Stars: 5}, {Text: "great"}},
+			Internal: "x",
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		expected := `{"bool":{` +
+			`"filter":[` +
+			`{"term":{"author.name":{"value":"alice"}}},` +
+			`{"nested":{"path":"comments","query":{"bool":{"filter":[{"term":{"comments.stars":{"value":5}}}]}}}},` +
+			`{"nested":{"path":"comments","query":{"bool":{"must":[{"match":{"comments.text":{"query":"great"}}}]}}}},` +
+			`{"term":{"draft":{"value":false}}},` +
+			`{"term":{"status":{"value":"published"}}},` +
+			`{"term":{"tags":{"value":"go"}}},` +
+			`{"term":{"tags":{"value":"generics"}}},` +
+			`{"range":{"views":{"gte":100}}}],` +
+			`"must":[{"match":{"title":{"query":"go generics"}}}]}}`
+		if s := encode(t, q); s != expected {
+			t.Errorf("Unexpected query:\n%s\nexpected:\n%s", s, expected)
+		}
+	})
+
+	t.Run("Map", func(t *testing.T) {
+		q, err := FromExample(testFields, map[string]interface{}{
+			"title.raw": "Go Generics",
+			"published": map[string]interface{}{"min": "2024-01-01", "max": "now"},
+			"views":     Range{Max: 10.5},
+			"draft":     false,
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		expected := `{"bool":{"filter":[` +
+			`{"term":{"draft":{"value":false}}},` +
+			`{"range":{"published":{"gte":"2024-01-01","lte":"now"}}},` +
+			`{"term":{"title.raw":{"value":"Go Generics"}}},` +
+			`{"range":{"views":{"lte":10.5}}}]}}`
+		if s := encode(t, q); s != expected {
+			t.Errorf("Unexpected query:\n%s\nexpected:\n%s", s, expected)
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		q, err := FromExample(testFields, article{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if s := encode(t, q); s != `{"match_all":{}}` {
+			t.Errorf("Unexpected query: %s", s)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, tc := range []struct {
+			example interface{}
+			err     string
+		}{
+			{map[string]interface{}{"missing": "x"}, "qbe: field [missing] is not mapped"},
+			{map[string]interface{}{"n": 1}, "qbe: field [n] is mapped with different types"},
+			{map[string]interface{}{"location": "1,2"}, "qbe: field [location]: unsupported type [geo_point]"},
+			{map[string]interface{}{"views": Range{Min: "x"}}, "qbe: field [views]: expected a number for the range, got string"},
+			{map[string]interface{}{"author": "alice"}, "qbe: field [author]: expected an object, got string"},
+			{"title", "qbe: example must be a struct or a map, got string"},
+		} {
+			_, err := FromExample(testFields, tc.example)
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("Unexpected error: want=%q, got=%v", tc.err, err)
+			}
+		}
+	})
+}
+
+// mockMappingCluster serves the mapping of the articles.
+type mockMappingCluster struct{}
+
+func (m *mockMappingCluster) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: 200,
+		Body: ioutil.NopCloser(strings.NewReader(`{"articles":{"mappings":{"properties":{
+			"title":{"type":"text","fields":{"raw":{"type":"keyword"}}},
+			"views":{"type":"long"},
+			"comments":{"type":"nested","properties":{"text":{"type":"text"}}}}}}}`)),
+		Header: http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+	}, nil
+}
+
+func TestBuilder(t *testing.T) {
+	es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: &mockMappingCluster{}})
+	b := New(mapping.NewCache(es, time.Minute), "articles")
+
+	t.Run("Query", func(t *testing.T) {
+		q, err := b.Query(context.Background(), map[string]interface{}{"title": "go", "views": 10})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if s := encode(t, q); s != `{"bool":{"filter":[{"term":{"views":{"value":10}}}],"must":[{"match":{"title":{"query":"go"}}}]}}` {
+			t.Errorf("Unexpected query: %s", s)
+		}
+	})
+
+	t.Run("More like this", func(t *testing.T) {
+		q, err := b.MoreLikeThis(context.Background(), "1", MoreLikeThisConfig{MinTermFreq: 1})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if s := encode(t, q); s != `{"more_like_this":{"fields":["title","title.raw"],"like":[{"_id":"1","_index":"articles"}],"min_term_freq":1}}` {
+			t.Errorf("Unexpected query: %s", s)
+		}
+	})
+
+	t.Run("More like this with fields", func(t *testing.T) {
+		q := MoreLikeThis("1", MoreLikeThisConfig{Fields: []string{"title"}, MinimumShouldMatch: "50%", Include: true})
+		if s := encode(t, q); s != `{"more_like_this":{"fields":["title"],"include":true,"like":[{"_id":"1"}],"minimum_should_match":"50%"}}` {
+			t.Errorf("Unexpected query: %s", s)
+		}
+	})
+}
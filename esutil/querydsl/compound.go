@@ -0,0 +1,202 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package querydsl
+
+import (
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/childscoremode"
+)
+
+// Bool returns a bool query.
+func Bool() *BoolBuilder {
+	return &BoolBuilder{}
+}
+
+// BoolBuilder builds a bool query. The clauses are built when the query is.
+type BoolBuilder struct {
+	must, filter, should, mustNot []Builder
+
+	minimumShouldMatch *string
+	boost              *float32
+	name               *string
+}
+
+// Must adds clauses which must match, and contribute to the score.
+func (b *BoolBuilder) Must(queries ...Builder) *BoolBuilder {
+	b.must = append(b.must, queries...)
+	return b
+}
+
+// Filter adds clauses which must match, without contributing to the score.
+func (b *BoolBuilder) Filter(queries ...Builder) *BoolBuilder {
+	b.filter = append(b.filter, queries...)
+	return b
+}
+
+// Should adds clauses which should match.
+func (b *BoolBuilder) Should(queries ...Builder) *BoolBuilder {
+	b.should = append(b.should, queries...)
+	return b
+}
+
+// MustNot adds clauses which must not match.
+func (b *BoolBuilder) MustNot(queries ...Builder) *BoolBuilder {
+	b.mustNot = append(b.mustNot, queries...)
+	return b
+}
+
+// MinimumShouldMatch sets the number or percentage of should clauses which must match, eg. "1" or "75%".
+func (b *BoolBuilder) MinimumShouldMatch(v string) *BoolBuilder {
+	b.minimumShouldMatch = &v
+	return b
+}
+
+// Boost sets the boost of the query.
+func (b *BoolBuilder) Boost(boost float32) *BoolBuilder {
+	b.boost = &boost
+	return b
+}
+
+// Name sets the name of the query, returned in the matched queries of the hits.
+func (b *BoolBuilder) Name(name string) *BoolBuilder {
+	b.name = &name
+	return b
+}
+
+// Query returns the query.
+func (b *BoolBuilder) Query() *types.Query {
+	q := types.BoolQuery{
+		Must:       build(b.must),
+		Filter:     build(b.filter),
+		Should:     build(b.should),
+		MustNot:    build(b.mustNot),
+		Boost:      b.boost,
+		QueryName_: b.name,
+	}
+	if b.minimumShouldMatch != nil {
+		q.MinimumShouldMatch = *b.minimumShouldMatch
+	}
+	return &types.Query{Bool: &q}
+}
+
+// String returns the query encoded as JSON.
+func (b *BoolBuilder) String() string { return String(b) }
+
+// build returns the queries of the builders, skipping nil builders and queries.
+func build(builders []Builder) []types.Query {
+	var queries []types.Query
+	for _, b := range builders {
+		if b == nil {
+			continue
+		}
+		if q := b.Query(); q != nil {
+			queries = append(queries, *q)
+		}
+	}
+	return queries
+}
+
+// Nested returns a nested query over the objects at the path.
+func Nested(path string, query Builder) *NestedBuilder {
+	return &NestedBuilder{path: path, query: query}
+}
+
+// NestedBuilder builds a nested query.
+type NestedBuilder struct {
+	path  string
+	query Builder
+	q     types.NestedQuery
+}
+
+// ScoreMode sets how the scores of the matching objects are combined.
+func (b *NestedBuilder) ScoreMode(mode childscoremode.ChildScoreMode) *NestedBuilder {
+	b.q.ScoreMode = &mode
+	return b
+}
+
+// IgnoreUnmapped makes the query match no documents, instead of failing, when the path is not mapped.
+func (b *NestedBuilder) IgnoreUnmapped(v bool) *NestedBuilder {
+	b.q.IgnoreUnmapped = &v
+	return b
+}
+
+// InnerHits returns the matching objects with the hits.
+func (b *NestedBuilder) InnerHits(innerHits types.InnerHits) *NestedBuilder {
+	b.q.InnerHits = &innerHits
+	return b
+}
+
+// Boost sets the boost of the query.
+func (b *NestedBuilder) Boost(boost float32) *NestedBuilder {
+	b.q.Boost = &boost
+	return b
+}
+
+// Name sets the name of the query, returned in the matched queries of the hits.
+func (b *NestedBuilder) Name(name string) *NestedBuilder {
+	b.q.QueryName_ = &name
+	return b
+}
+
+// Query returns the query.
+func (b *NestedBuilder) Query() *types.Query {
+	q := b.q
+	q.Path = b.path
+	if b.query != nil {
+		q.Query = b.query.Query()
+	}
+	return &types.Query{Nested: &q}
+}
+
+// String returns the query encoded as JSON.
+func (b *NestedBuilder) String() string { return String(b) }
+
+// ConstantScore returns a constant_score query, which wraps the query as a filter.
+func ConstantScore(filter Builder) *ConstantScoreBuilder {
+	return &ConstantScoreBuilder{filter: filter}
+}
+
+// ConstantScoreBuilder builds a constant_score query.
+type ConstantScoreBuilder struct {
+	filter Builder
+	q      types.ConstantScoreQuery
+}
+
+// Boost sets the score of the matching documents.
+func (b *ConstantScoreBuilder) Boost(boost float32) *ConstantScoreBuilder {
+	b.q.Boost = &boost
+	return b
+}
+
+// Name sets the name of the query, returned in the matched queries of the hits.
+func (b *ConstantScoreBuilder) Name(name string) *ConstantScoreBuilder {
+	b.q.QueryName_ = &name
+	return b
+}
+
+// Query returns the query.
+func (b *ConstantScoreBuilder) Query() *types.Query {
+	q := b.q
+	if b.filter != nil {
+		q.Filter = b.filter.Query()
+	}
+	return &types.Query{ConstantScore: &q}
+}
+
+// String returns the query encoded as JSON.
+func (b *ConstantScoreBuilder) String() string { return String(b) }
@@ -0,0 +1,242 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package querydsl
+
+import (
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/operator"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/textquerytype"
+)
+
+// Match returns a match query for the text on the field.
+func Match(field, text string) *MatchBuilder {
+	return &MatchBuilder{field: field, q: types.MatchQuery{Query: text}}
+}
+
+// MatchBuilder builds a match query.
+type MatchBuilder struct {
+	field string
+	q     types.MatchQuery
+}
+
+// Operator sets how the terms of the text are combined, eg. operator.And.
+func (b *MatchBuilder) Operator(op operator.Operator) *MatchBuilder {
+	b.q.Operator = &op
+	return b
+}
+
+// MinimumShouldMatch sets the number or percentage of terms which must match, eg. "2" or "75%".
+func (b *MatchBuilder) MinimumShouldMatch(v string) *MatchBuilder {
+	b.q.MinimumShouldMatch = v
+	return b
+}
+
+// Fuzziness sets the allowed edit distance, eg. "AUTO" or "1".
+func (b *MatchBuilder) Fuzziness(v string) *MatchBuilder {
+	b.q.Fuzziness = v
+	return b
+}
+
+// Analyzer sets the analyzer of the text.
+func (b *MatchBuilder) Analyzer(analyzer string) *MatchBuilder {
+	b.q.Analyzer = &analyzer
+	return b
+}
+
+// Boost sets the boost of the query.
+func (b *MatchBuilder) Boost(boost float32) *MatchBuilder {
+	b.q.Boost = &boost
+	return b
+}
+
+// Name sets the name of the query, returned in the matched queries of the hits.
+func (b *MatchBuilder) Name(name string) *MatchBuilder {
+	b.q.QueryName_ = &name
+	return b
+}
+
+// Query returns the query.
+func (b *MatchBuilder) Query() *types.Query {
+	return &types.Query{Match: map[string]types.MatchQuery{b.field: b.q}}
+}
+
+// String returns the query encoded as JSON.
+func (b *MatchBuilder) String() string { return String(b) }
+
+// MatchPhrase returns a match_phrase query for the text on the field.
+func MatchPhrase(field, text string) *MatchPhraseBuilder {
+	return &MatchPhraseBuilder{field: field, q: types.MatchPhraseQuery{Query: text}}
+}
+
+// MatchPhraseBuilder builds a match_phrase query.
+type MatchPhraseBuilder struct {
+	field string
+	q     types.MatchPhraseQuery
+}
+
+// Slop sets the number of positions allowed between the terms.
+func (b *MatchPhraseBuilder) Slop(slop int) *MatchPhraseBuilder {
+	b.q.Slop = &slop
+	return b
+}
+
+// Analyzer sets the analyzer of the text.
+func (b *MatchPhraseBuilder) Analyzer(analyzer string) *MatchPhraseBuilder {
+	b.q.Analyzer = &analyzer
+	return b
+}
+
+// Boost sets the boost of the query.
+func (b *MatchPhraseBuilder) Boost(boost float32) *MatchPhraseBuilder {
+	b.q.Boost = &boost
+	return b
+}
+
+// Name sets the name of the query, returned in the matched queries of the hits.
+func (b *MatchPhraseBuilder) Name(name string) *MatchPhraseBuilder {
+	b.q.QueryName_ = &name
+	return b
+}
+
+// Query returns the query.
+func (b *MatchPhraseBuilder) Query() *types.Query {
+	return &types.Query{MatchPhrase: map[string]types.MatchPhraseQuery{b.field: b.q}}
+}
+
+// String returns the query encoded as JSON.
+func (b *MatchPhraseBuilder) String() string { return String(b) }
+
+// MultiMatch returns a multi_match query for the text on the fields,
+// which may have a boost suffix, eg. "title^2".
+func MultiMatch(text string, fields ...string) *MultiMatchBuilder {
+	return &MultiMatchBuilder{q: types.MultiMatchQuery{Query: text, Fields: fields}}
+}
+
+// MultiMatchBuilder builds a multi_match query.
+type MultiMatchBuilder struct {
+	q types.MultiMatchQuery
+}
+
+// Type sets how the fields are matched, eg. textquerytype.Bestfields.
+func (b *MultiMatchBuilder) Type(t textquerytype.TextQueryType) *MultiMatchBuilder {
+	b.q.Type = &t
+	return b
+}
+
+// Operator sets how the terms of the text are combined, eg. operator.And.
+func (b *MultiMatchBuilder) Operator(op operator.Operator) *MultiMatchBuilder {
+	b.q.Operator = &op
+	return b
+}
+
+// MinimumShouldMatch sets the number or percentage of terms which must match, eg. "2" or "75%".
+func (b *MultiMatchBuilder) MinimumShouldMatch(v string) *MultiMatchBuilder {
+	b.q.MinimumShouldMatch = v
+	return b
+}
+
+// Fuzziness sets the allowed edit distance, eg. "AUTO" or "1".
+func (b *MultiMatchBuilder) Fuzziness(v string) *MultiMatchBuilder {
+	b.q.Fuzziness = v
+	return b
+}
+
+// TieBreaker sets the weight of the scores of the other matching fields.
+func (b *MultiMatchBuilder) TieBreaker(v float64) *MultiMatchBuilder {
+	f := types.Float64(v)
+	b.q.TieBreaker = &f
+	return b
+}
+
+// Boost sets the boost of the query.
+func (b *MultiMatchBuilder) Boost(boost float32) *MultiMatchBuilder {
+	b.q.Boost = &boost
+	return b
+}
+
+// Name sets the name of the query, returned in the matched queries of the hits.
+func (b *MultiMatchBuilder) Name(name string) *MultiMatchBuilder {
+	b.q.QueryName_ = &name
+	return b
+}
+
+// Query returns the query.
+func (b *MultiMatchBuilder) Query() *types.Query {
+	q := b.q
+	q.Fields = append([]string(nil), b.q.Fields...)
+	return &types.Query{MultiMatch: &q}
+}
+
+// String returns the query encoded as JSON.
+func (b *MultiMatchBuilder) String() string { return String(b) }
+
+// QueryString returns a query_string query, in the Lucene query syntax.
+func QueryString(query string) *QueryStringBuilder {
+	return &QueryStringBuilder{q: types.QueryStringQuery{Query: query}}
+}
+
+// QueryStringBuilder builds a query_string query.
+type QueryStringBuilder struct {
+	q types.QueryStringQuery
+}
+
+// Fields sets the fields to search, instead of the default field.
+func (b *QueryStringBuilder) Fields(fields ...string) *QueryStringBuilder {
+	b.q.Fields = append(b.q.Fields, fields...)
+	return b
+}
+
+// DefaultField sets the field to search when no field is given in the query.
+func (b *QueryStringBuilder) DefaultField(field string) *QueryStringBuilder {
+	b.q.DefaultField = &field
+	return b
+}
+
+// DefaultOperator sets how the terms are combined, eg. operator.And.
+func (b *QueryStringBuilder) DefaultOperator(op operator.Operator) *QueryStringBuilder {
+	b.q.DefaultOperator = &op
+	return b
+}
+
+// AllowLeadingWildcard sets whether wildcards are allowed as the first character of a term.
+func (b *QueryStringBuilder) AllowLeadingWildcard(v bool) *QueryStringBuilder {
+	b.q.AllowLeadingWildcard = &v
+	return b
+}
+
+// Boost sets the boost of the query.
+func (b *QueryStringBuilder) Boost(boost float32) *QueryStringBuilder {
+	b.q.Boost = &boost
+	return b
+}
+
+// Name sets the name of the query, returned in the matched queries of the hits.
+func (b *QueryStringBuilder) Name(name string) *QueryStringBuilder {
+	b.q.QueryName_ = &name
+	return b
+}
+
+// Query returns the query.
+func (b *QueryStringBuilder) Query() *types.Query {
+	q := b.q
+	q.Fields = append([]string(nil), b.q.Fields...)
+	return &types.Query{QueryString: &q}
+}
+
+// String returns the query encoded as JSON.
+func (b *QueryStringBuilder) String() string { return String(b) }
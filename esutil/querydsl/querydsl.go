@@ -0,0 +1,118 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package querydsl provides fluent, composable constructors for the queries of the typed API.
+//
+// Every builder produces a *types.Query, so that it can be used anywhere the typed API expects one:
+//
+//	import q "github.com/elastic/go-elasticsearch/v8/esutil/querydsl"
+//
+//	query := q.Bool().
+//		Must(q.Match("title", "go")).
+//		Filter(q.Term("status", "published"), q.Range("ts").Gte(since)).
+//		Query()
+//
+//	res, err := es.Search().Index("articles").Query(query).Do(ctx)
+//
+// Existing *types.Query values can be combined with builders with Wrap.
+package querydsl
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// Builder is implemented by all query builders.
+type Builder interface {
+	// Query returns a new query from the current state of the builder.
+	Query() *types.Query
+}
+
+// Value represents the values accepted by term level queries.
+// Time values are encoded in RFC 3339 format.
+type Value interface {
+	~string | ~bool |
+		~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 |
+		~float32 | ~float64 |
+		time.Time
+}
+
+// fieldValue returns the value as encoded in the query.
+func fieldValue[V Value](v V) types.FieldValue {
+	if t, ok := any(v).(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	return v
+}
+
+// Wrap returns a builder for an existing query, eg. to add it to a bool query.
+func Wrap(q *types.Query) Builder {
+	return wrapped{q: q}
+}
+
+type wrapped struct{ q *types.Query }
+
+func (w wrapped) Query() *types.Query { return w.q }
+
+// String returns the query encoded as JSON.
+func String(b Builder) string {
+	out, err := json.Marshal(b.Query())
+	if err != nil {
+		return fmt.Sprintf("<error encoding query: %s>", err)
+	}
+	return string(out)
+}
+
+// MatchAll returns a match_all query.
+func MatchAll() *MatchAllBuilder {
+	return &MatchAllBuilder{}
+}
+
+// MatchAllBuilder builds a match_all query.
+type MatchAllBuilder struct {
+	q types.MatchAllQuery
+}
+
+// Boost sets the boost of the query.
+func (b *MatchAllBuilder) Boost(boost float32) *MatchAllBuilder {
+	b.q.Boost = &boost
+	return b
+}
+
+// Name sets the name of the query, returned in the matched queries of the hits.
+func (b *MatchAllBuilder) Name(name string) *MatchAllBuilder {
+	b.q.QueryName_ = &name
+	return b
+}
+
+// Query returns the query.
+func (b *MatchAllBuilder) Query() *types.Query {
+	q := b.q
+	return &types.Query{MatchAll: &q}
+}
+
+// String returns the query encoded as JSON.
+func (b *MatchAllBuilder) String() string { return String(b) }
+
+// MatchNone returns a match_none query.
+func MatchNone() Builder {
+	return Wrap(&types.Query{MatchNone: &types.MatchNoneQuery{}})
+}
@@ -0,0 +1,114 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package querydsl
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/operator"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/textquerytype"
+)
+
+type status string
+
+func TestBuilders(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tt := []struct {
+		name  string
+		query Builder
+		want  string
+	}{
+		{"MatchAll", MatchAll().Boost(2), `{"match_all":{"boost":2}}`},
+		{"MatchNone", MatchNone(), `{"match_none":{}}`},
+		{"Match", Match("title", "go client").Operator(operator.And).Name("m"),
+			`{"match":{"title":{"operator":"and","query":"go client","_name":"m"}}}`},
+		{"MatchPhrase", MatchPhrase("title", "go client").Slop(1),
+			`{"match_phrase":{"title":{"query":"go client","slop":1}}}`},
+		{"MultiMatch", MultiMatch("go", "title^2", "body").Type(textquerytype.Bestfields).TieBreaker(0.3),
+			`{"multi_match":{"fields":["title^2","body"],"query":"go","tie_breaker":0.3,"type":"best_fields"}}`},
+		{"QueryString", QueryString("go AND client").Fields("title").DefaultOperator(operator.Or),
+			`{"query_string":{"default_operator":"or","fields":["title"],"query":"go AND client"}}`},
+		{"Term string", Term("status", "published"), `{"term":{"status":{"value":"published"}}}`},
+		{"Term named type", Term("status", status("draft")).CaseInsensitive(true),
+			`{"term":{"status":{"case_insensitive":true,"value":"draft"}}}`},
+		{"Term number", Term("n", 42), `{"term":{"n":{"value":42}}}`},
+		{"Term bool", Term("ok", true), `{"term":{"ok":{"value":true}}}`},
+		{"Term time", Term("ts", ts), `{"term":{"ts":{"value":"2024-01-02T03:04:05Z"}}}`},
+		{"Terms", Terms("tags", "a", "b"), `{"terms":{"tags":["a","b"]}}`},
+		{"Range", Range("ts").Gte(ts).LtExpr("now/d").TimeZone("+01:00"),
+			`{"range":{"ts":{"gte":"2024-01-02T03:04:05Z","lt":"now/d","time_zone":"+01:00"}}}`},
+		{"NumberRange", NumberRange("price").Gt(10).Lte(99.5),
+			`{"range":{"price":{"gt":10,"lte":99.5}}}`},
+		{"Exists", Exists("title"), `{"exists":{"field":"title"}}`},
+		{"IDs", IDs("1", "2"), `{"ids":{"values":["1","2"]}}`},
+		{"Prefix", Prefix("name", "jo"), `{"prefix":{"name":{"value":"jo"}}}`},
+		{"Wildcard", Wildcard("name", "jo*n"), `{"wildcard":{"name":{"value":"jo*n"}}}`},
+		{"Nested", Nested("comments", Match("comments.text", "nice")).IgnoreUnmapped(true),
+			`{"nested":{"ignore_unmapped":true,"path":"comments","query":{"match":{"comments.text":{"query":"nice"}}}}}`},
+		{"ConstantScore", ConstantScore(Term("n", 1)).Boost(1.5),
+			`{"constant_score":{"boost":1.5,"filter":{"term":{"n":{"value":1}}}}}`},
+		{"Wrap", Wrap(&types.Query{Exists: &types.ExistsQuery{Field: "x"}}), `{"exists":{"field":"x"}}`},
+		{"Bool",
+			Bool().
+				Must(Match("title", "go")).
+				Filter(Range("ts").Gte(ts), nil).
+				Should(Term("a", 1), Term("b", 2)).
+				MinimumShouldMatch("1").
+				MustNot(Exists("deleted")),
+			`{"bool":{"filter":[{"range":{"ts":{"gte":"2024-01-02T03:04:05Z"}}}],"minimum_should_match":"1",` +
+				`"must":[{"match":{"title":{"query":"go"}}}],"must_not":[{"exists":{"field":"deleted"}}],` +
+				`"should":[{"term":{"a":{"value":1}}},{"term":{"b":{"value":2}}}]}}`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := String(tc.query); got != tc.want {
+				t.Errorf("Unexpected query:\nwant: %s\ngot:  %s", tc.want, got)
+			}
+
+			// The query must be decoded back by the typed API.
+			var q types.Query
+			if err := json.Unmarshal([]byte(String(tc.query)), &q); err != nil {
+				t.Errorf("Unexpected error decoding query: %s", err)
+			}
+		})
+	}
+}
+
+func TestBuilderReuse(t *testing.T) {
+	b := Bool().Filter(Term("a", 1))
+	first := b.Query()
+	b.Filter(Term("b", 2))
+
+	if len(first.Bool.Filter) != 1 {
+		t.Errorf("Expected the built query to be unaffected by the builder, got: %d clauses", len(first.Bool.Filter))
+	}
+	if len(b.Query().Bool.Filter) != 2 {
+		t.Errorf("Unexpected number of clauses: %d", len(b.Query().Bool.Filter))
+	}
+	if b.String() != String(b) {
+		t.Errorf("Unexpected string: %s", b.String())
+	}
+}
@@ -0,0 +1,278 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package querydsl
+
+import (
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/rangerelation"
+)
+
+// Term returns a term query for the exact value of the field.
+func Term[V Value](field string, value V) *TermBuilder {
+	return &TermBuilder{field: field, q: types.TermQuery{Value: fieldValue(value)}}
+}
+
+// TermBuilder builds a term query.
+type TermBuilder struct {
+	field string
+	q     types.TermQuery
+}
+
+// CaseInsensitive sets whether the value is matched case insensitively.
+func (b *TermBuilder) CaseInsensitive(v bool) *TermBuilder {
+	b.q.CaseInsensitive = &v
+	return b
+}
+
+// Boost sets the boost of the query.
+func (b *TermBuilder) Boost(boost float32) *TermBuilder {
+	b.q.Boost = &boost
+	return b
+}
+
+// Name sets the name of the query, returned in the matched queries of the hits.
+func (b *TermBuilder) Name(name string) *TermBuilder {
+	b.q.QueryName_ = &name
+	return b
+}
+
+// Query returns the query.
+func (b *TermBuilder) Query() *types.Query {
+	return &types.Query{Term: map[string]types.TermQuery{b.field: b.q}}
+}
+
+// String returns the query encoded as JSON.
+func (b *TermBuilder) String() string { return String(b) }
+
+// Terms returns a terms query for any of the exact values of the field.
+func Terms[V Value](field string, values ...V) *TermsBuilder {
+	vv := make([]types.FieldValue, len(values))
+	for i, v := range values {
+		vv[i] = fieldValue(v)
+	}
+	return &TermsBuilder{field: field, values: vv}
+}
+
+// TermsBuilder builds a terms query.
+type TermsBuilder struct {
+	field  string
+	values []types.FieldValue
+	boost  *float32
+	name   *string
+}
+
+// Boost sets the boost of the query.
+func (b *TermsBuilder) Boost(boost float32) *TermsBuilder {
+	b.boost = &boost
+	return b
+}
+
+// Name sets the name of the query, returned in the matched queries of the hits.
+func (b *TermsBuilder) Name(name string) *TermsBuilder {
+	b.name = &name
+	return b
+}
+
+// Query returns the query.
+func (b *TermsBuilder) Query() *types.Query {
+	return &types.Query{Terms: &types.TermsQuery{
+		TermsQuery: map[string]types.TermsQueryField{b.field: append([]types.FieldValue(nil), b.values...)},
+		Boost:      b.boost,
+		QueryName_: b.name,
+	}}
+}
+
+// String returns the query encoded as JSON.
+func (b *TermsBuilder) String() string { return String(b) }
+
+// Range returns a range query over dates on the field.
+//
+// The bounds are either times, encoded in RFC 3339 format, or date math
+// expressions with the Expr methods, eg. GteExpr("now-1d/d").
+// Use NumberRange for numeric fields.
+func Range(field string) *RangeBuilder {
+	return &RangeBuilder{field: field}
+}
+
+// RangeBuilder builds a range query over dates.
+type RangeBuilder struct {
+	field string
+	q     types.DateRangeQuery
+}
+
+// Gt sets the exclusive lower bound.
+func (b *RangeBuilder) Gt(t time.Time) *RangeBuilder { return b.GtExpr(t.Format(time.RFC3339Nano)) }
+
+// Gte sets the inclusive lower bound.
+func (b *RangeBuilder) Gte(t time.Time) *RangeBuilder { return b.GteExpr(t.Format(time.RFC3339Nano)) }
+
+// Lt sets the exclusive upper bound.
+func (b *RangeBuilder) Lt(t time.Time) *RangeBuilder { return b.LtExpr(t.Format(time.RFC3339Nano)) }
+
+// Lte sets the inclusive upper bound.
+func (b *RangeBuilder) Lte(t time.Time) *RangeBuilder { return b.LteExpr(t.Format(time.RFC3339Nano)) }
+
+// GtExpr sets the exclusive lower bound as a date or date math expression.
+func (b *RangeBuilder) GtExpr(expr string) *RangeBuilder {
+	b.q.Gt = &expr
+	return b
+}
+
+// GteExpr sets the inclusive lower bound as a date or date math expression.
+func (b *RangeBuilder) GteExpr(expr string) *RangeBuilder {
+	b.q.Gte = &expr
+	return b
+}
+
+// LtExpr sets the exclusive upper bound as a date or date math expression.
+func (b *RangeBuilder) LtExpr(expr string) *RangeBuilder {
+	b.q.Lt = &expr
+	return b
+}
+
+// LteExpr sets the inclusive upper bound as a date or date math expression.
+func (b *RangeBuilder) LteExpr(expr string) *RangeBuilder {
+	b.q.Lte = &expr
+	return b
+}
+
+// Format sets the date format of the bounds.
+func (b *RangeBuilder) Format(format string) *RangeBuilder {
+	b.q.Format = &format
+	return b
+}
+
+// TimeZone sets the time zone of the bounds without one, eg. "+01:00" or "Europe/Paris".
+func (b *RangeBuilder) TimeZone(tz string) *RangeBuilder {
+	b.q.TimeZone = &tz
+	return b
+}
+
+// Relation sets how the range matches range fields.
+func (b *RangeBuilder) Relation(r rangerelation.RangeRelation) *RangeBuilder {
+	b.q.Relation = &r
+	return b
+}
+
+// Boost sets the boost of the query.
+func (b *RangeBuilder) Boost(boost float32) *RangeBuilder {
+	b.q.Boost = &boost
+	return b
+}
+
+// Name sets the name of the query, returned in the matched queries of the hits.
+func (b *RangeBuilder) Name(name string) *RangeBuilder {
+	b.q.QueryName_ = &name
+	return b
+}
+
+// Query returns the query.
+func (b *RangeBuilder) Query() *types.Query {
+	return &types.Query{Range: map[string]types.RangeQuery{b.field: b.q}}
+}
+
+// String returns the query encoded as JSON.
+func (b *RangeBuilder) String() string { return String(b) }
+
+// NumberRange returns a range query over numbers on the field.
+func NumberRange(field string) *NumberRangeBuilder {
+	return &NumberRangeBuilder{field: field}
+}
+
+// NumberRangeBuilder builds a range query over numbers.
+type NumberRangeBuilder struct {
+	field string
+	q     types.NumberRangeQuery
+}
+
+// Gt sets the exclusive lower bound.
+func (b *NumberRangeBuilder) Gt(v float64) *NumberRangeBuilder {
+	f := types.Float64(v)
+	b.q.Gt = &f
+	return b
+}
+
+// Gte sets the inclusive lower bound.
+func (b *NumberRangeBuilder) Gte(v float64) *NumberRangeBuilder {
+	f := types.Float64(v)
+	b.q.Gte = &f
+	return b
+}
+
+// Lt sets the exclusive upper bound.
+func (b *NumberRangeBuilder) Lt(v float64) *NumberRangeBuilder {
+	f := types.Float64(v)
+	b.q.Lt = &f
+	return b
+}
+
+// Lte sets the inclusive upper bound.
+func (b *NumberRangeBuilder) Lte(v float64) *NumberRangeBuilder {
+	f := types.Float64(v)
+	b.q.Lte = &f
+	return b
+}
+
+// Relation sets how the range matches range fields.
+func (b *NumberRangeBuilder) Relation(r rangerelation.RangeRelation) *NumberRangeBuilder {
+	b.q.Relation = &r
+	return b
+}
+
+// Boost sets the boost of the query.
+func (b *NumberRangeBuilder) Boost(boost float32) *NumberRangeBuilder {
+	b.q.Boost = &boost
+	return b
+}
+
+// Name sets the name of the query, returned in the matched queries of the hits.
+func (b *NumberRangeBuilder) Name(name string) *NumberRangeBuilder {
+	b.q.QueryName_ = &name
+	return b
+}
+
+// Query returns the query.
+func (b *NumberRangeBuilder) Query() *types.Query {
+	return &types.Query{Range: map[string]types.RangeQuery{b.field: b.q}}
+}
+
+// String returns the query encoded as JSON.
+func (b *NumberRangeBuilder) String() string { return String(b) }
+
+// Exists returns an exists query, matching documents with a value for the field.
+func Exists(field string) Builder {
+	return Wrap(&types.Query{Exists: &types.ExistsQuery{Field: field}})
+}
+
+// IDs returns an ids query, matching the documents with the IDs.
+func IDs(ids ...string) Builder {
+	return Wrap(&types.Query{Ids: &types.IdsQuery{Values: ids}})
+}
+
+// Prefix returns a prefix query for the field.
+func Prefix(field, prefix string) Builder {
+	return Wrap(&types.Query{Prefix: map[string]types.PrefixQuery{field: {Value: prefix}}})
+}
+
+// Wildcard returns a wildcard query for the field, where * matches any characters
+// and ? a single one. Avoid patterns starting with a wildcard, which are slow.
+func Wildcard(field, pattern string) Builder {
+	return Wrap(&types.Query{Wildcard: map[string]types.WildcardQuery{field: {Value: &pattern}}})
+}
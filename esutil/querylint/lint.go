@@ -0,0 +1,465 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package querylint reports risky constructs in queries before they are sent,
+// such as a term query on a text field, a leading wildcard or a script query.
+//
+// Queries are checked on their own, or against the fields of the target indices,
+// eg. from a mapping.Cache, to find field type mismatches:
+//
+//	issues, err := querylint.Lint(query, querylint.Config{Fields: fields})
+//
+// Check returns the issues as an error, for tests, and NewTransport checks
+// the search requests of a client before sending them.
+package querylint
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/esutil/mapping"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// The rules of the linter.
+const (
+	RuleTermOnText      = "term-on-text"     // A term level query on a text field, which matches analyzed terms.
+	RuleLeadingWildcard = "leading-wildcard" // A wildcard, regexp or query_string starting with a wildcard, which visits every term.
+	RuleLargeTerms      = "large-terms"      // A terms query with more values than Config.MaxTerms.
+	RuleScript          = "script"           // A script evaluated for every document, in a query, sort or script field.
+	RuleUnmappedField   = "unmapped-field"   // A field missing from the mapping.
+	RuleFieldType       = "field-type"       // A value or query incompatible with the type of the field.
+	RuleNestedPath      = "nested-path"      // A nested query on a path which is not a nested field.
+	RuleDeepPagination  = "deep-pagination"  // A from and size beyond Config.MaxResultWindow.
+	RuleValidate        = "validate"         // An explanation of the Validate Query API.
+)
+
+// Severity represents the severity of an issue.
+type Severity int
+
+// The severities of the issues.
+const (
+	// SeverityWarning is the severity of a construct which is slow or likely not what was meant.
+	SeverityWarning Severity = iota
+	// SeverityError is the severity of a construct which Elasticsearch rejects.
+	SeverityError
+)
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Issue represents a single risky construct.
+type Issue struct {
+	Rule     string
+	Severity Severity
+	Path     string // The JSON path of the construct, eg. "query.bool.filter[0].term.title".
+	Message  string
+}
+
+// String returns a readable description of the issue.
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s: %s [%s]", i.Severity, i.Path, i.Message, i.Rule)
+}
+
+// Issues represents the issues of a query, as an error.
+type Issues []Issue
+
+// Error returns the issues, one per line.
+func (is Issues) Error() string {
+	lines := make([]string, len(is))
+	for i, issue := range is {
+		lines[i] = issue.String()
+	}
+	return "querylint: " + strings.Join(lines, "\n")
+}
+
+// Max returns the highest severity of the issues.
+func (is Issues) Max() Severity {
+	var max Severity
+	for _, i := range is {
+		if i.Severity > max {
+			max = i.Severity
+		}
+	}
+	return max
+}
+
+// Config represents configuration of the linter.
+type Config struct {
+	// Fields are the fields of the target indices. When set, queries are checked
+	// for unmapped fields and field type mismatches.
+	Fields mapping.Fields
+
+	MaxTerms        int // The number of values of a terms query reported as large. Defaults to 1024.
+	MaxResultWindow int // The maximum from and size of a search. Defaults to 10000, the index default.
+
+	Disabled []string // The rules not to check.
+}
+
+// Lint returns the issues of the query.
+func Lint(q *types.Query, cfg Config) (Issues, error) {
+	body, err := json.Marshal(map[string]interface{}{"query": q})
+	if err != nil {
+		return nil, fmt.Errorf("querylint: error encoding query: %s", err)
+	}
+	return LintJSON(body, cfg)
+}
+
+// LintRequest returns the issues of the search request.
+func LintRequest(req *search.Request, cfg Config) (Issues, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("querylint: error encoding request: %s", err)
+	}
+	return LintJSON(body, cfg)
+}
+
+// LintJSON returns the issues of the search request body.
+func LintJSON(body []byte, cfg Config) (Issues, error) {
+	var req map[string]interface{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("querylint: error parsing request body: %s", err)
+	}
+
+	if cfg.MaxTerms == 0 {
+		cfg.MaxTerms = 1024
+	}
+	if cfg.MaxResultWindow == 0 {
+		cfg.MaxResultWindow = 10000
+	}
+	l := linter{cfg: cfg, disabled: make(map[string]bool)}
+	for _, r := range cfg.Disabled {
+		l.disabled[r] = true
+	}
+
+	l.request(req)
+	sort.SliceStable(l.issues, func(i, j int) bool { return l.issues[i].Path < l.issues[j].Path })
+	return l.issues, nil
+}
+
+// Check returns the issues of the query as an error, or nil when there are none,
+// eg. to check the queries of an application in its tests.
+func Check(q *types.Query, cfg Config) error {
+	issues, err := Lint(q, cfg)
+	if err != nil {
+		return err
+	}
+	if len(issues) > 0 {
+		return issues
+	}
+	return nil
+}
+
+// linter walks the decoded JSON of a search request.
+type linter struct {
+	cfg      Config
+	disabled map[string]bool
+	issues   Issues
+}
+
+// report adds an issue, unless its rule is disabled.
+func (l *linter) report(rule string, severity Severity, path, format string, args ...interface{}) {
+	if l.disabled[rule] {
+		return
+	}
+	l.issues = append(l.issues, Issue{Rule: rule, Severity: severity, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// request checks the parts of the search request which contain queries or scripts.
+func (l *linter) request(req map[string]interface{}) {
+	for _, key := range []string{"query", "post_filter"} {
+		if q, ok := req[key].(map[string]interface{}); ok {
+			l.query(key, q)
+		}
+	}
+
+	switch knn := req["knn"].(type) {
+	case map[string]interface{}:
+		l.knn("knn", knn)
+	case []interface{}:
+		for i, k := range knn {
+			if k, ok := k.(map[string]interface{}); ok {
+				l.knn(fmt.Sprintf("knn[%d]", i), k)
+			}
+		}
+	}
+
+	from, _ := req["from"].(float64)
+	size, ok := req["size"].(float64)
+	if !ok {
+		size = 10
+	}
+	if int(from+size) > l.cfg.MaxResultWindow {
+		l.report(RuleDeepPagination, SeverityError, "from", "from and size of %d exceed the result window of %d, use search_after", int(from+size), l.cfg.MaxResultWindow)
+	}
+
+	if fields, ok := req["script_fields"].(map[string]interface{}); ok {
+		for name := range fields {
+			l.report(RuleScript, SeverityWarning, "script_fields."+name, "script field evaluated for every hit")
+		}
+	}
+	if sorts, ok := req["sort"].([]interface{}); ok {
+		for i, s := range sorts {
+			if s, ok := s.(map[string]interface{}); ok {
+				if _, ok := s["_script"]; ok {
+					l.report(RuleScript, SeverityWarning, fmt.Sprintf("sort[%d]._script", i), "script sort evaluated for every matching document")
+				}
+			}
+		}
+	}
+}
+
+// knn checks the filter of a kNN search.
+func (l *linter) knn(path string, knn map[string]interface{}) {
+	l.queries(path+".filter", knn["filter"])
+}
+
+// queries checks a single query or a list of queries.
+func (l *linter) queries(path string, v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		l.query(path, v)
+	case []interface{}:
+		for i, q := range v {
+			if q, ok := q.(map[string]interface{}); ok {
+				l.query(fmt.Sprintf("%s[%d]", path, i), q)
+			}
+		}
+	}
+}
+
+// query checks a query, and its sub-queries.
+func (l *linter) query(path string, q map[string]interface{}) {
+	for kind, v := range q {
+		body, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		p := path + "." + kind
+
+		switch kind {
+		case "bool":
+			for _, occur := range []string{"must", "filter", "should", "must_not"} {
+				l.queries(p+"."+occur, body[occur])
+			}
+		case "constant_score":
+			l.queries(p+".filter", body["filter"])
+		case "dis_max":
+			l.queries(p+".queries", body["queries"])
+		case "boosting":
+			l.queries(p+".positive", body["positive"])
+			l.queries(p+".negative", body["negative"])
+		case "has_child", "has_parent":
+			l.queries(p+".query", body["query"])
+		case "function_score":
+			l.queries(p+".query", body["query"])
+			if functions, ok := body["functions"].([]interface{}); ok {
+				for i, f := range functions {
+					f, _ := f.(map[string]interface{})
+					if _, ok := f["script_score"]; ok {
+						l.report(RuleScript, SeverityWarning, fmt.Sprintf("%s.functions[%d].script_score", p, i), "script score evaluated for every matching document")
+					}
+					l.queries(fmt.Sprintf("%s.functions[%d].filter", p, i), f["filter"])
+				}
+			}
+			if _, ok := body["script_score"]; ok {
+				l.report(RuleScript, SeverityWarning, p+".script_score", "script score evaluated for every matching document")
+			}
+		case "script_score":
+			l.report(RuleScript, SeverityWarning, p, "script score evaluated for every matching document")
+			l.queries(p+".query", body["query"])
+		case "script":
+			l.report(RuleScript, SeverityWarning, p, "script query evaluated for every document, consider a runtime or indexed field")
+		case "nested":
+			l.nested(p, body)
+		case "term", "prefix", "fuzzy":
+			for field, fv := range body {
+				l.termLevel(p, kind, field, fv)
+			}
+		case "terms":
+			l.terms(p, body)
+		case "wildcard", "regexp":
+			for field, fv := range body {
+				l.pattern(p, kind, field, fv)
+			}
+		case "range":
+			for field := range body {
+				l.rangeQuery(p+"."+field, field)
+			}
+		case "exists":
+			field, _ := body["field"].(string)
+			l.field(p+".field", field)
+		case "match", "match_phrase", "match_phrase_prefix", "match_bool_prefix":
+			for field := range body {
+				l.field(p+"."+field, field)
+			}
+		case "query_string":
+			l.queryString(p, body)
+		}
+	}
+}
+
+// field reports a field missing from the mapping, and returns its type.
+func (l *linter) field(path, field string) (string, bool) {
+	if l.cfg.Fields == nil || field == "" || strings.ContainsAny(field, "*") {
+		return "", false
+	}
+	t, ok := l.cfg.Fields[field]
+	if !ok {
+		l.report(RuleUnmappedField, SeverityWarning, path, "field [%s] is not mapped", field)
+		return "", false
+	}
+	if t == mapping.TypeConflict {
+		return "", false
+	}
+	return t, true
+}
+
+// termLevel checks a term, prefix or fuzzy query on a field.
+func (l *linter) termLevel(path, kind, field string, v interface{}) {
+	if field == "boost" || field == "_name" {
+		return
+	}
+	p := path + "." + field
+	t, ok := l.field(p, field)
+	if !ok {
+		return
+	}
+	if t == "text" {
+		l.report(RuleTermOnText, SeverityWarning, p, "%s query on text field [%s] matches analyzed terms, use a match query or a keyword field", kind, field)
+	}
+
+	value := v
+	if m, ok := v.(map[string]interface{}); ok {
+		value = m["value"]
+	}
+	l.value(p, field, t, value)
+}
+
+// value reports a value which cannot be parsed for the type of the field.
+func (l *linter) value(path, field, t string, v interface{}) {
+	s, ok := v.(string)
+	if !ok {
+		return
+	}
+	switch t {
+	case "long", "integer", "short", "byte", "double", "float", "half_float", "scaled_float", "unsigned_long":
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			l.report(RuleFieldType, SeverityError, path, "value %q is not a number for %s field [%s]", s, t, field)
+		}
+	case "boolean":
+		if s != "true" && s != "false" && s != "" {
+			l.report(RuleFieldType, SeverityError, path, "value %q is not a boolean for field [%s]", s, field)
+		}
+	}
+}
+
+// terms checks a terms query.
+func (l *linter) terms(path string, body map[string]interface{}) {
+	for field, v := range body {
+		if field == "boost" || field == "_name" {
+			continue
+		}
+		p := path + "." + field
+		t, mapped := l.field(p, field)
+
+		values, ok := v.([]interface{})
+		if !ok {
+			continue // A terms lookup
+		}
+		if len(values) > l.cfg.MaxTerms {
+			l.report(RuleLargeTerms, SeverityWarning, p, "terms query with %d values, consider a terms lookup or a join on the client", len(values))
+		}
+		if !mapped {
+			continue
+		}
+		if t == "text" {
+			l.report(RuleTermOnText, SeverityWarning, p, "terms query on text field [%s] matches analyzed terms, use a match query or a keyword field", field)
+		}
+		for i, value := range values {
+			l.value(fmt.Sprintf("%s[%d]", p, i), field, t, value)
+		}
+	}
+}
+
+// pattern checks a wildcard or regexp query.
+func (l *linter) pattern(path, kind, field string, v interface{}) {
+	if field == "boost" || field == "_name" {
+		return
+	}
+	p := path + "." + field
+	if t, ok := l.field(p, field); ok && t == "text" {
+		l.report(RuleTermOnText, SeverityWarning, p, "%s query on text field [%s] matches analyzed terms", kind, field)
+	}
+
+	pattern, _ := v.(string)
+	if m, ok := v.(map[string]interface{}); ok {
+		pattern, _ = m["value"].(string)
+		if w, ok := m["wildcard"].(string); ok && pattern == "" {
+			pattern = w
+		}
+	}
+	if kind == "wildcard" && (strings.HasPrefix(pattern, "*") || strings.HasPrefix(pattern, "?")) {
+		l.report(RuleLeadingWildcard, SeverityWarning, p, "pattern %q starts with a wildcard, which visits every term of the field", pattern)
+	}
+	if kind == "regexp" && strings.HasPrefix(pattern, ".*") {
+		l.report(RuleLeadingWildcard, SeverityWarning, p, "regular expression %q starts with a wildcard, which visits every term of the field", pattern)
+	}
+}
+
+// leadingWildcard matches a term starting with a wildcard in the query string syntax.
+var leadingWildcard = regexp.MustCompile(`(^|[\s:(])[*?]\w`)
+
+// queryString checks a query_string query.
+func (l *linter) queryString(path string, body map[string]interface{}) {
+	if allow, ok := body["allow_leading_wildcard"].(bool); ok && !allow {
+		return
+	}
+	if s, _ := body["query"].(string); leadingWildcard.MatchString(s) {
+		l.report(RuleLeadingWildcard, SeverityWarning, path, "query %q has a term starting with a wildcard, which visits every term of the field", s)
+	}
+}
+
+// rangeQuery checks a range query on a field.
+func (l *linter) rangeQuery(path, field string) {
+	t, ok := l.field(path, field)
+	if !ok {
+		return
+	}
+	if t == "text" {
+		l.report(RuleFieldType, SeverityWarning, path, "range query on text field [%s] compares analyzed terms", field)
+	}
+}
+
+// nested checks a nested query, and its query.
+func (l *linter) nested(path string, body map[string]interface{}) {
+	p, _ := body["path"].(string)
+	if t, ok := l.field(path+".path", p); ok && t != "nested" {
+		l.report(RuleNestedPath, SeverityError, path+".path", "path [%s] is a %s field, not a nested field", p, t)
+	}
+	l.queries(path+".query", body["query"])
+}
@@ -0,0 +1,157 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package querylint
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8/esutil/mapping"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/some"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+var testFields = mapping.Fields{
+	"title":         "text",
+	"title.raw":     "keyword",
+	"n":             "long",
+	"ok":            "boolean",
+	"author":        "object",
+	"author.name":   "keyword",
+	"comments":      "nested",
+	"comments.text": "text",
+	"mixed":         mapping.TypeConflict,
+}
+
+func TestLintJSON(t *testing.T) {
+	tt := []struct {
+		name   string
+		body   string
+		fields mapping.Fields
+		want   []string
+	}{
+		{"Clean", `{"query":{"bool":{"filter":[{"term":{"title.raw":"go"}},{"range":{"n":{"gte":1}}}]}}}`, testFields, nil},
+		{"Term on text", `{"query":{"bool":{"must":{"term":{"title":{"value":"Go"}}}}}}`, testFields,
+			[]string{"warning: query.bool.must.term.title: term query on text field [title] matches analyzed terms, use a match query or a keyword field [term-on-text]"}},
+		{"Terms on text", `{"query":{"terms":{"title":["a","b"]}}}`, testFields,
+			[]string{"warning: query.terms.title: terms query on text field [title] matches analyzed terms, use a match query or a keyword field [term-on-text]"}},
+		{"Number", `{"query":{"term":{"n":"abc"}}}`, testFields,
+			[]string{`error: query.term.n: value "abc" is not a number for long field [n] [field-type]`}},
+		{"Boolean", `{"query":{"terms":{"ok":["yes"]}}}`, testFields,
+			[]string{`error: query.terms.ok[0]: value "yes" is not a boolean for field [ok] [field-type]`}},
+		{"Conflict", `{"query":{"term":{"mixed":"abc"}}}`, testFields, nil},
+		{"Unmapped", `{"query":{"match":{"titel":"go"}},"post_filter":{"exists":{"field":"missing"}}}`, testFields,
+			[]string{
+				"warning: post_filter.exists.field: field [missing] is not mapped [unmapped-field]",
+				"warning: query.match.titel: field [titel] is not mapped [unmapped-field]",
+			}},
+		{"No fields", `{"query":{"term":{"titel":"go"}}}`, nil, nil},
+		{"Leading wildcard", `{"query":{"dis_max":{"queries":[{"wildcard":{"name":{"value":"*son"}}},{"regexp":{"name":".*son"}},{"wildcard":{"name":"jo*"}}]}}}`, nil,
+			[]string{
+				`warning: query.dis_max.queries[0].wildcard.name: pattern "*son" starts with a wildcard, which visits every term of the field [leading-wildcard]`,
+				`warning: query.dis_max.queries[1].regexp.name: regular expression ".*son" starts with a wildcard, which visits every term of the field [leading-wildcard]`,
+			}},
+		{"Query string", `{"query":{"query_string":{"query":"name:*son AND go"}}}`, nil,
+			[]string{`warning: query.query_string: query "name:*son AND go" has a term starting with a wildcard, which visits every term of the field [leading-wildcard]`}},
+		{"Query string without leading wildcards", `{"query":{"query_string":{"query":"*son","allow_leading_wildcard":false}}}`, nil, nil},
+		{"Large terms", fmt.Sprintf(`{"query":{"terms":{"id":[%s]}}}`, strings.Repeat(`"x",`, 1024)+`"y"`), nil,
+			[]string{"warning: query.terms.id: terms query with 1025 values, consider a terms lookup or a join on the client [large-terms]"}},
+		{"Scripts", `{"query":{"function_score":{"query":{"script":{"script":"true"}},"functions":[{"script_score":{"script":"1"}}]}},"sort":[{"_script":{}}],"script_fields":{"f":{}}}`, nil,
+			[]string{
+				"warning: query.function_score.functions[0].script_score: script score evaluated for every matching document [script]",
+				"warning: query.function_score.query.script: script query evaluated for every document, consider a runtime or indexed field [script]",
+				"warning: script_fields.f: script field evaluated for every hit [script]",
+				"warning: sort[0]._script: script sort evaluated for every matching document [script]",
+			}},
+		{"Nested", `{"query":{"nested":{"path":"author","query":{"term":{"comments.text":"x"}}}}}`, testFields,
+			[]string{
+				"error: query.nested.path: path [author] is a object field, not a nested field [nested-path]",
+				"warning: query.nested.query.term.comments.text: term query on text field [comments.text] matches analyzed terms, use a match query or a keyword field [term-on-text]",
+			}},
+		{"Range on text", `{"query":{"range":{"title":{"gte":"a"}}}}`, testFields,
+			[]string{"warning: query.range.title: range query on text field [title] compares analyzed terms [field-type]"}},
+		{"Deep pagination", `{"from":9995,"size":10}`, nil,
+			[]string{"error: from: from and size of 10005 exceed the result window of 10000, use search_after [deep-pagination]"}},
+		{"kNN filter", `{"knn":{"field":"v","filter":{"term":{"title":"x"}}}}`, testFields,
+			[]string{"warning: knn.filter.term.title: term query on text field [title] matches analyzed terms, use a match query or a keyword field [term-on-text]"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			issues, err := LintJSON([]byte(tc.body), Config{Fields: tc.fields})
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			var got []string
+			for _, i := range issues {
+				got = append(got, i.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("Unexpected issues:\nwant: %s\ngot:  %s", strings.Join(tc.want, "\n      "), strings.Join(got, "\n      "))
+			}
+		})
+	}
+}
+
+func TestLint(t *testing.T) {
+	q := &types.Query{Wildcard: map[string]types.WildcardQuery{"name": {Value: some.String("*son")}}}
+
+	t.Run("Query", func(t *testing.T) {
+		issues, err := Lint(q, Config{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(issues) != 1 || issues[0].Rule != RuleLeadingWildcard || issues.Max() != SeverityWarning {
+			t.Errorf("Unexpected issues: %v", issues)
+		}
+	})
+
+	t.Run("Request", func(t *testing.T) {
+		issues, err := LintRequest(&search.Request{Query: q, From: some.Int(10000)}, Config{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(issues) != 2 || issues.Max() != SeverityError {
+			t.Errorf("Unexpected issues: %v", issues)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		issues, _ := Lint(q, Config{Disabled: []string{RuleLeadingWildcard}})
+		if len(issues) != 0 {
+			t.Errorf("Unexpected issues: %v", issues)
+		}
+	})
+
+	t.Run("Check", func(t *testing.T) {
+		err := Check(q, Config{})
+		var issues Issues
+		if !errors.As(err, &issues) || !strings.HasPrefix(err.Error(), "querylint: warning: query.wildcard.name") {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if err := Check(&types.Query{MatchAll: &types.MatchAllQuery{}}, Config{}); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	})
+}
@@ -0,0 +1,176 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package querylint
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/esutil/mapping"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// TransportConfig represents configuration of the linting transport.
+type TransportConfig struct {
+	Transport http.RoundTripper // The transport sending the requests. Defaults to http.DefaultTransport.
+
+	Config Config // The configuration of the linter.
+
+	// Mappings provides the fields of the target indices of the searches,
+	// which replace Config.Fields. The search is checked without fields
+	// when the mapping cannot be read, or when it has no target indices.
+	Mappings *mapping.Cache
+
+	// Reject fails the searches with issues of error severity without sending them,
+	// with a 400 response of the RejectedType error, see RejectedIssues.
+	//
+	// The rejection is a response rather than a transport error, which the client
+	// would retry on the other nodes and count as a failure of the connection.
+	Reject bool
+
+	OnIssues func(*http.Request, Issues) // Called with the issues of a search, before it is sent.
+}
+
+// NewTransport returns a transport which checks the search requests before sending them,
+// to set as the transport of the client:
+//
+//	es, err := elasticsearch.NewClient(elasticsearch.Config{
+//		Transport: querylint.NewTransport(querylint.TransportConfig{
+//			OnIssues: func(req *http.Request, issues querylint.Issues) { log.Println(req.URL.Path, issues) },
+//		}),
+//	})
+func NewTransport(cfg TransportConfig) http.RoundTripper {
+	if cfg.Transport == nil {
+		cfg.Transport = http.DefaultTransport
+	}
+	return &transport{cfg: cfg}
+}
+
+type transport struct {
+	cfg TransportConfig
+}
+
+// RoundTrip checks the search requests, and sends the requests.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || !strings.HasSuffix(req.URL.Path, "/_search") {
+		return t.cfg.Transport.RoundTrip(req)
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader(body)), nil }
+
+	cfg := t.cfg.Config
+	if t.cfg.Mappings != nil {
+		cfg.Fields = nil
+		if index := strings.Trim(strings.TrimSuffix(req.URL.Path, "/_search"), "/"); index != "" {
+			if fields, err := t.cfg.Mappings.Fields(req.Context(), strings.Split(index, ",")...); err == nil {
+				cfg.Fields = fields
+			}
+		}
+	}
+
+	issues, err := LintJSON(body, cfg)
+	if err != nil || len(issues) == 0 {
+		return t.cfg.Transport.RoundTrip(req)
+	}
+	if t.cfg.OnIssues != nil {
+		t.cfg.OnIssues(req, issues)
+	}
+	if t.cfg.Reject && issues.Max() == SeverityError {
+		return rejected(req, issues)
+	}
+	return t.cfg.Transport.RoundTrip(req)
+}
+
+// RejectedType is the type of the error of the searches rejected by the transport.
+const RejectedType = "querylint_exception"
+
+// rejectedIssue is the encoding of an issue in the error of a rejected search.
+type rejectedIssue struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Path     string `json:"path"`
+	Message  string `json:"message"`
+}
+
+// rejected returns the response of a search rejected with the issues.
+func rejected(req *http.Request, issues Issues) (*http.Response, error) {
+	encoded := make([]rejectedIssue, len(issues))
+	for i, issue := range issues {
+		encoded[i] = rejectedIssue{Rule: issue.Rule, Severity: issue.Severity.String(), Path: issue.Path, Message: issue.Message}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{
+			"type":   RejectedType,
+			"reason": issues.Error(),
+			"issues": encoded,
+		},
+		"status": http.StatusBadRequest,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("querylint: error encoding issues: %s", err)
+	}
+
+	return &http.Response{
+		Status:        "400 Bad Request",
+		StatusCode:    http.StatusBadRequest,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}, "X-Elastic-Product": []string{"Elasticsearch"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// RejectedIssues returns the issues of a search rejected by the transport,
+// from the error returned by the typed API.
+//
+// With the esapi package, the response of a rejected search has the status 400,
+// and its body the issues in error.issues.
+func RejectedIssues(err error) (Issues, bool) {
+	var esErr *types.ElasticsearchError
+	if !errors.As(err, &esErr) || esErr.ErrorCause.Type != RejectedType {
+		return nil, false
+	}
+
+	var encoded []rejectedIssue
+	if err := json.Unmarshal(esErr.ErrorCause.Metadata["issues"], &encoded); err != nil {
+		return nil, false
+	}
+	issues := make(Issues, len(encoded))
+	for i, issue := range encoded {
+		issues[i] = Issue{Rule: issue.Rule, Path: issue.Path, Message: issue.Message}
+		if issue.Severity == SeverityError.String() {
+			issues[i].Severity = SeverityError
+		}
+	}
+	return issues, true
+}
@@ -0,0 +1,187 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package querylint
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/elastic/elastic-transport-go/v8/elastictransport"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esutil/internal/estest"
+	"github.com/elastic/go-elasticsearch/v8/esutil/mapping"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// mockCluster serves mapping, search and validate query requests.
+type mockCluster struct {
+	mu sync.Mutex
+
+	searches []string
+	mappings int
+}
+
+func (m *mockCluster) handle(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/_mapping"):
+		m.mappings++
+		return estest.Response(200, `{"test":{"mappings":{"properties":{"title":{"type":"text","fields":{"raw":{"type":"keyword"}}},"n":{"type":"long"}}}}}`)
+
+	case strings.HasSuffix(req.URL.Path, "/_search"):
+		body, _ := ioutil.ReadAll(req.Body)
+		m.searches = append(m.searches, string(body))
+		return estest.Response(200, `{"took":1,"timed_out":false,"_shards":{"total":1,"successful":1,"failed":0},"hits":{"hits":[]}}`)
+
+	case strings.HasSuffix(req.URL.Path, "/_validate/query"):
+		if req.URL.Query().Get("explain") != "true" {
+			return estest.Response(400, `{"error":{"type":"illegal_argument_exception","reason":"explain expected"},"status":400}`)
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		if strings.Contains(string(body), `"n"`) {
+			return estest.Response(200, `{"valid":false,"explanations":[{"index":"test","valid":false,"error":"NumberFormatException: For input string: \"abc\""}]}`)
+		}
+		return estest.Response(200, `{"valid":true}`)
+	}
+
+	return estest.Response(404, `{}`)
+}
+
+func TestTransport(t *testing.T) {
+	cluster := &mockCluster{}
+	typed, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: estest.Transport(cluster.handle)})
+
+	var reported []Issues
+	es, _ := elasticsearch.NewClient(elasticsearch.Config{
+		Transport: NewTransport(TransportConfig{
+			Transport: estest.Transport(cluster.handle),
+			Mappings:  mapping.NewCache(typed, 0),
+			Reject:    true,
+			OnIssues:  func(req *http.Request, issues Issues) { reported = append(reported, issues) },
+		}),
+	})
+
+	t.Run("Warning", func(t *testing.T) {
+		res, err := es.Search(es.Search.WithIndex("test"), es.Search.WithBody(strings.NewReader(`{"query":{"term":{"title":"Go"}}}`)))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		res.Body.Close()
+
+		if len(cluster.searches) != 1 || cluster.searches[0] != `{"query":{"term":{"title":"Go"}}}` {
+			t.Errorf("Expected the search to be sent, got: %v", cluster.searches)
+		}
+		if len(reported) != 1 || reported[0][0].Rule != RuleTermOnText {
+			t.Errorf("Unexpected issues: %v", reported)
+		}
+	})
+
+	t.Run("Reject", func(t *testing.T) {
+		res, err := es.Search(es.Search.WithIndex("test"), es.Search.WithBody(strings.NewReader(`{"query":{"term":{"n":"abc"}}}`)))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != 400 || !strings.Contains(res.String(), `"type":"querylint_exception"`) || !strings.Contains(res.String(), `"rule":"field-type"`) {
+			t.Errorf("Unexpected response: %s", res)
+		}
+		if len(cluster.searches) != 1 {
+			t.Errorf("Expected the search not to be sent, got: %v", cluster.searches)
+		}
+		if cluster.mappings != 1 {
+			t.Errorf("Expected the mapping to be cached, got %d requests", cluster.mappings)
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: estest.Transport((&mockCluster{}).handle)})
+
+	issues, err := Validate(context.Background(), es, "test", &types.Query{Term: map[string]types.TermQuery{"n": {Value: "abc"}}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(issues) != 1 || issues[0].Rule != RuleValidate || !strings.Contains(issues[0].Message, "[test] NumberFormatException") {
+		t.Errorf("Unexpected issues: %v", issues)
+	}
+
+	issues, err = Validate(context.Background(), es, "test", &types.Query{MatchAll: &types.MatchAllQuery{}})
+	if err != nil || len(issues) != 0 {
+		t.Errorf("Unexpected result: %v, %v", issues, err)
+	}
+}
+
+func TestTransportRejectKeepsConnections(t *testing.T) {
+	cluster := &mockCluster{}
+
+	var calls int
+	es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{
+		Addresses:     []string{"http://node1:9200", "http://node2:9200"},
+		EnableMetrics: true,
+		Transport: NewTransport(TransportConfig{
+			Transport: estest.Transport(cluster.handle),
+			Config:    Config{Fields: map[string]string{"n": "long"}},
+			Reject:    true,
+			OnIssues:  func(req *http.Request, issues Issues) { calls++ },
+		}),
+	})
+
+	_, err := es.Search().Index("test").Query(&types.Query{Term: map[string]types.TermQuery{"n": {Value: "abc"}}}).Do(context.Background())
+	var esErr *types.ElasticsearchError
+	if !errors.As(err, &esErr) || esErr.Status != 400 {
+		t.Fatalf("Expected a 400 error, got: %v", err)
+	}
+	issues, ok := RejectedIssues(err)
+	if !ok || len(issues) != 1 || issues[0].Rule != RuleFieldType || issues[0].Severity != SeverityError || issues[0].Path != "query.term.n" {
+		t.Errorf("Unexpected issues: %v", issues)
+	}
+	if calls != 1 {
+		t.Errorf("Expected the search to be linted once, got %d", calls)
+	}
+	if len(cluster.searches) != 0 {
+		t.Errorf("Expected the search not to be sent, got: %v", cluster.searches)
+	}
+
+	metrics, err := es.Metrics()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(metrics.Connections) != 2 {
+		t.Fatalf("Unexpected connections: %v", metrics.Connections)
+	}
+	for _, c := range metrics.Connections {
+		if cm, ok := c.(elastictransport.ConnectionMetric); !ok || cm.IsDead || cm.Failures > 0 {
+			t.Errorf("Unexpected connection state: %s", c)
+		}
+	}
+
+	if _, ok := RejectedIssues(errors.New("other")); ok {
+		t.Errorf("Unexpected issues for another error")
+	}
+}
@@ -0,0 +1,66 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package querylint
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/elastic/elastic-transport-go/v8/elastictransport"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/indices/validatequery"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// Validate checks the query against the indices with the Validate Query API,
+// and returns an issue for every index where the query is invalid.
+//
+// The client is a *elasticsearch.TypedClient or a *elasticsearch.Client.
+func Validate(ctx context.Context, client elastictransport.Interface, index string, q *types.Query) (Issues, error) {
+	res, err := validatequery.NewValidateQueryFunc(client)().
+		Index(index).
+		Explain(true).
+		Request(&validatequery.Request{Query: q}).
+		Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("querylint: validate query: %w", err)
+	}
+	if res.Valid {
+		return nil, nil
+	}
+
+	var issues Issues
+	for _, e := range res.Explanations {
+		if e.Valid {
+			continue
+		}
+		msg := "invalid query"
+		if e.Error != nil {
+			msg = *e.Error
+		}
+		issues = append(issues, Issue{Rule: RuleValidate, Severity: SeverityError, Path: "query", Message: fmt.Sprintf("[%s] %s", e.Index, msg)})
+	}
+	if len(issues) == 0 {
+		msg := "invalid query"
+		if res.Error != nil {
+			msg = *res.Error
+		}
+		issues = append(issues, Issue{Rule: RuleValidate, Severity: SeverityError, Path: "query", Message: msg})
+	}
+	return issues, nil
+}
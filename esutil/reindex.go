@@ -0,0 +1,321 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/elastic/go-elasticsearch/v8/esutil/internal/progress"
+)
+
+// ReindexConfig represents configuration of the reindex.
+type ReindexConfig struct {
+	Source esapi.Transport       // The source client, a *elasticsearch.Client or a *elasticsearch.TypedClient. Required.
+	Target *elasticsearch.Client // The target client. Required.
+
+	// Scan configures the scan of the source indices. Scan.Index is required.
+	//
+	// To resume from a checkpoint, Scan.Sort must be a total order over
+	// stable fields, eg. a unique keyword field, since the _shard_doc
+	// default is only valid within a single point in time.
+	Scan ScanConfig
+
+	// BulkIndexer configures the indexer for the target index.
+	// The Client and Index fields are set from Target and TargetIndex.
+	BulkIndexer BulkIndexerConfig
+
+	TargetIndex string // The target index. Required.
+	Action      string // The bulk action for the documents. Defaults to "index".
+
+	// TargetIndexBody, when set, is encoded as the body of the create index request,
+	// eg. the settings and mappings. The target index is only created when it
+	// doesn't exist.
+	TargetIndexBody interface{}
+
+	// Transform is called for every document before it is indexed.
+	// It can modify the document, or set Skip to leave it out.
+	Transform func(context.Context, *ReindexDocument) error
+
+	// Checkpoint resumes a reindex from a checkpoint reported by OnProgress.
+	// It can't be combined with multiple slices.
+	Checkpoint *ReindexCheckpoint
+
+	OnProgress       func(context.Context, ReindexProgress) // Called periodically and when the reindex ends.
+	ProgressInterval time.Duration                          // The interval of OnProgress calls. Defaults to 10sec.
+
+	// OnFailure is called for documents which could not be indexed.
+	OnFailure func(context.Context, ReindexDocument, BulkIndexerResponseItem, error)
+}
+
+// ReindexDocument represents a document copied to the target index.
+type ReindexDocument struct {
+	Index   string          // The target index, defaults to ReindexConfig.TargetIndex
+	ID      string          // The document ID, defaults to the source document ID
+	Routing string          // The routing, defaults to the source routing
+	Source  json.RawMessage // The document source
+	Skip    bool            // Set to leave out the document
+
+	Hit ScanHit // The source hit
+}
+
+// ReindexCheckpoint represents a position in the source to resume the reindex from.
+// It is safe to persist as JSON.
+type ReindexCheckpoint struct {
+	SearchAfter []json.RawMessage `json:"search_after"` // The sort values of the last processed hit
+	Processed   uint64            `json:"processed"`    // The number of hits processed up to the checkpoint
+}
+
+// ReindexProgress represents the progress of the reindex.
+type ReindexProgress struct {
+	NumScanned uint64 // The number of hits read from the source
+	NumIndexed uint64 // The number of documents indexed in the target
+	NumFailed  uint64 // The number of documents which could not be indexed
+	NumSkipped uint64 // The number of documents left out by Transform
+
+	// Checkpoint is the position up to which all documents have been processed,
+	// nil when the checkpoint is not available, eg. with multiple slices.
+	Checkpoint *ReindexCheckpoint
+
+	Elapsed time.Duration
+}
+
+// Reindex copies documents from the source indices to the target index,
+// possibly across clusters, using a point in time scan on the source
+// and a bulk indexer on the target.
+//
+// It returns the final progress, also when the reindex has failed,
+// so that the last checkpoint can be persisted. The documents of bulk requests
+// which fail as a whole, eg. when the target is not available, are counted
+// as failed and reported with OnFailure, and Reindex stops with an error.
+// The checkpoint doesn't move past them, so that a resume indexes them again.
+func Reindex(ctx context.Context, cfg ReindexConfig) (ReindexProgress, error) {
+	if cfg.Source == nil || cfg.Target == nil {
+		return ReindexProgress{}, errors.New("reindex: source and target clients are required")
+	}
+	if cfg.TargetIndex == "" {
+		return ReindexProgress{}, errors.New("reindex: target index is required")
+	}
+	if cfg.Checkpoint != nil && cfg.Scan.Sort == nil {
+		return ReindexProgress{}, errors.New("reindex: resuming from a checkpoint requires a sort")
+	}
+	if cfg.Checkpoint != nil && cfg.Scan.Slices > 1 {
+		return ReindexProgress{}, errors.New("reindex: resuming from a checkpoint can't be combined with slices")
+	}
+	if cfg.Action == "" {
+		cfg.Action = "index"
+	}
+	if cfg.ProgressInterval == 0 {
+		cfg.ProgressInterval = 10 * time.Second
+	}
+
+	r := reindexer{cfg: cfg, start: time.Now(), tracker: progress.NewTracker[[]json.RawMessage]()}
+	if cfg.Checkpoint != nil {
+		r.tracker.Resume(cfg.Checkpoint.SearchAfter, cfg.Checkpoint.Processed)
+		r.cfg.Scan.SearchAfter = cfg.Checkpoint.SearchAfter
+	}
+	// The sort values of the hits of multiple slices are not in a single order.
+	r.trackCheckpoint = cfg.Scan.Slices <= 1
+
+	if cfg.TargetIndexBody != nil {
+		if err := r.createTargetIndex(ctx); err != nil {
+			return r.progress(), err
+		}
+	}
+
+	return r.run(ctx)
+}
+
+// reindexer represents a single reindex.
+type reindexer struct {
+	cfg   ReindexConfig
+	start time.Time
+
+	numScanned uint64
+	numIndexed uint64
+	numFailed  uint64
+	numSkipped uint64
+
+	tracker         *progress.Tracker[[]json.RawMessage] // The sort values of the processed hits
+	trackCheckpoint bool
+}
+
+// run scans the source and feeds the bulk indexer.
+func (r *reindexer) run(ctx context.Context) (ReindexProgress, error) {
+	biCfg := r.cfg.BulkIndexer
+	biCfg.Client = r.cfg.Target
+	biCfg.Index = r.cfg.TargetIndex
+
+	bi, err := NewBulkIndexer(biCfg)
+	if err != nil {
+		return r.progress(), fmt.Errorf("reindex: %s", err)
+	}
+
+	stop := func() {}
+	if r.cfg.OnProgress != nil {
+		stop = progress.Every(r.cfg.ProgressInterval, func() { r.cfg.OnProgress(ctx, r.progress()) })
+	}
+
+	scanErr := Scan(ctx, r.cfg.Source, r.cfg.Scan, func(ctx context.Context, hit ScanHit) error {
+		// Stop feeding the indexer once the target has failed.
+		if err := r.requestErr(); err != nil {
+			return err
+		}
+
+		atomic.AddUint64(&r.numScanned, 1)
+		seq := r.tracker.Next()
+
+		doc := ReindexDocument{
+			Index:   r.cfg.TargetIndex,
+			ID:      hit.ID,
+			Routing: hit.Routing,
+			Source:  hit.Source,
+			Hit:     hit,
+		}
+		if r.cfg.Transform != nil {
+			if err := r.cfg.Transform(ctx, &doc); err != nil {
+				return fmt.Errorf("reindex: transform [%s]: %w", hit.ID, err)
+			}
+		}
+		if doc.Skip {
+			atomic.AddUint64(&r.numSkipped, 1)
+			r.tracker.Done(seq, hit.Sort)
+			return nil
+		}
+
+		// The future completes also when the whole bulk request fails,
+		// which OnFailure does not report.
+		return bi.Add(ctx, BulkIndexerItem{
+			Index:      doc.Index,
+			Action:     r.cfg.Action,
+			DocumentID: doc.ID,
+			Routing:    doc.Routing,
+			Body:       bytes.NewReader(doc.Source),
+			Future: NewBulkIndexerFutureFunc(func(res BulkIndexerItemResult) {
+				r.complete(ctx, doc, seq, res)
+			}),
+		})
+	})
+
+	// Flush the added documents also when the scan has failed,
+	// so that the checkpoint covers as much as possible.
+	closeErr := bi.Close(context.WithoutCancel(ctx))
+
+	stop()
+
+	p := r.progress()
+	if r.cfg.OnProgress != nil {
+		r.cfg.OnProgress(ctx, p)
+	}
+
+	// The error of the failed bulk requests also stops the scan,
+	// it is returned with the final number of documents.
+	if err := r.requestErr(); err != nil {
+		return p, err
+	}
+	if scanErr != nil {
+		return p, scanErr
+	}
+	if closeErr != nil {
+		return p, fmt.Errorf("reindex: %s", closeErr)
+	}
+	return p, nil
+}
+
+// complete accounts for an indexed or failed document.
+//
+// A document rejected by the target is processed, while a document
+// of a failed bulk request, without an item response, stops the checkpoint.
+func (r *reindexer) complete(ctx context.Context, doc ReindexDocument, seq uint64, res BulkIndexerItemResult) {
+	if res.Err == nil {
+		atomic.AddUint64(&r.numIndexed, 1)
+		r.tracker.Done(seq, doc.Hit.Sort)
+		return
+	}
+
+	atomic.AddUint64(&r.numFailed, 1)
+	if r.cfg.OnFailure != nil {
+		r.cfg.OnFailure(ctx, doc, res.Response, res.Err)
+	}
+	if res.Response.Status == 0 {
+		r.tracker.Fail(seq, res.Err)
+	} else {
+		r.tracker.Done(seq, doc.Hit.Sort)
+	}
+}
+
+// requestErr returns the error for the documents of failed bulk requests, if any.
+func (r *reindexer) requestErr() error {
+	n, err := r.tracker.RequestErr()
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("reindex: %d documents failed with their bulk request: %s", n, err)
+}
+
+// progress returns the current progress.
+func (r *reindexer) progress() ReindexProgress {
+	p := ReindexProgress{
+		NumScanned: atomic.LoadUint64(&r.numScanned),
+		NumIndexed: atomic.LoadUint64(&r.numIndexed),
+		NumFailed:  atomic.LoadUint64(&r.numFailed),
+		NumSkipped: atomic.LoadUint64(&r.numSkipped),
+		Elapsed:    time.Since(r.start),
+	}
+
+	if searchAfter, processed, ok := r.tracker.Checkpoint(); ok && r.trackCheckpoint {
+		p.Checkpoint = &ReindexCheckpoint{SearchAfter: searchAfter, Processed: processed}
+	}
+
+	return p
+}
+
+// createTargetIndex creates the target index, unless it exists.
+func (r *reindexer) createTargetIndex(ctx context.Context) error {
+	res, err := esapi.IndicesExistsRequest{Index: []string{r.cfg.TargetIndex}}.Do(ctx, r.cfg.Target)
+	if err != nil {
+		return fmt.Errorf("reindex: target index: %s", err)
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	body, err := json.Marshal(r.cfg.TargetIndexBody)
+	if err != nil {
+		return fmt.Errorf("reindex: target index: error encoding request body: %s", err)
+	}
+
+	res, err = esapi.IndicesCreateRequest{Index: r.cfg.TargetIndex, Body: bytes.NewReader(body)}.Do(ctx, r.cfg.Target)
+	if err != nil {
+		return fmt.Errorf("reindex: target index: %s", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("reindex: target index: %s", res.String())
+	}
+	return nil
+}
@@ -0,0 +1,295 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// mockReindexTarget serves the index and bulk requests of the target cluster.
+type mockReindexTarget struct {
+	mu sync.Mutex
+
+	exists     bool
+	created    string
+	docs       map[string]string
+	failIDs    map[string]bool
+	failBulk   bool // Fail the bulk requests as a whole, when set
+	failBulkAt int  // Fail the n-th bulk request as a whole, when set
+	numBulk    int
+}
+
+func (m *mockReindexTarget) handle(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch {
+	case req.Method == http.MethodHead:
+		if m.exists {
+			return mockResponse(200, ``)
+		}
+		return mockResponse(404, ``)
+
+	case req.Method == http.MethodPut:
+		body, _ := ioutil.ReadAll(req.Body)
+		m.created = string(body)
+		return mockResponse(200, `{"acknowledged":true}`)
+
+	case strings.HasSuffix(req.URL.Path, "/_bulk"):
+		m.numBulk++
+		if m.failBulk || m.numBulk == m.failBulkAt {
+			return mockResponse(500, `{"error":{"type":"internal_server_error","reason":"boom"},"status":500}`)
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+
+		var items []string
+		for i := 0; i < len(lines); i += 2 {
+			var meta map[string]struct {
+				ID    string `json:"_id"`
+				Index string `json:"_index"`
+			}
+			json.Unmarshal([]byte(lines[i]), &meta)
+			id := meta["index"].ID
+			if m.failIDs[id] {
+				items = append(items, fmt.Sprintf(`{"index":{"_id":%q,"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed"}}}`, id))
+				continue
+			}
+			if m.docs == nil {
+				m.docs = make(map[string]string)
+			}
+			m.docs[meta["index"].Index+"/"+id] = lines[i+1]
+			items = append(items, fmt.Sprintf(`{"index":{"_id":%q,"status":201}}`, id))
+		}
+		return mockResponse(200, fmt.Sprintf(`{"items":[%s]}`, strings.Join(items, ",")))
+	}
+
+	return mockResponse(404, `{}`)
+}
+
+func TestReindex(t *testing.T) {
+	t.Run("Basic", func(t *testing.T) {
+		source, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: (&mockScanCluster{numDocs: 25}).handle}})
+		target := &mockReindexTarget{failIDs: map[string]bool{"7": true}}
+		targetClient, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: target.handle}})
+
+		var (
+			failed   []string
+			progress []ReindexProgress
+		)
+		p, err := Reindex(context.Background(), ReindexConfig{
+			Source:          source,
+			Target:          targetClient,
+			Scan:            ScanConfig{Index: []string{"test"}, Size: 10, Sort: []string{"n"}},
+			BulkIndexer:     BulkIndexerConfig{NumWorkers: 2, FlushBytes: 100},
+			TargetIndex:     "target",
+			TargetIndexBody: json.RawMessage(`{"mappings":{"properties":{"n":{"type":"long"}}}}`),
+			Transform: func(ctx context.Context, doc *ReindexDocument) error {
+				if doc.ID == "3" {
+					doc.Skip = true
+					return nil
+				}
+				var src struct {
+					N int `json:"n"`
+				}
+				json.Unmarshal(doc.Source, &src)
+				doc.Source = json.RawMessage(fmt.Sprintf(`{"n":%d,"double":%d}`, src.N, src.N*2))
+				return nil
+			},
+			OnProgress: func(ctx context.Context, p ReindexProgress) { progress = append(progress, p) },
+			OnFailure: func(ctx context.Context, doc ReindexDocument, res BulkIndexerResponseItem, err error) {
+				if err == nil {
+					t.Errorf("Expected error for failed document")
+				}
+				failed = append(failed, doc.ID)
+			},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if p.NumScanned != 25 || p.NumIndexed != 23 || p.NumFailed != 1 || p.NumSkipped != 1 {
+			t.Errorf("Unexpected progress: %+v", p)
+		}
+		if p.Checkpoint == nil || p.Checkpoint.Processed != 25 || string(p.Checkpoint.SearchAfter[0]) != "24" {
+			t.Errorf("Unexpected checkpoint: %+v", p.Checkpoint)
+		}
+		if len(progress) == 0 || progress[len(progress)-1].NumScanned != 25 {
+			t.Errorf("Expected final progress report, got: %+v", progress)
+		}
+		if fmt.Sprint(failed) != "[7]" {
+			t.Errorf("Unexpected failures: %v", failed)
+		}
+
+		if target.created != `{"mappings":{"properties":{"n":{"type":"long"}}}}` {
+			t.Errorf("Unexpected create index body: %s", target.created)
+		}
+		if doc := target.docs["target/5"]; doc != `{"n":5,"double":10}` {
+			t.Errorf("Unexpected document: %s", doc)
+		}
+		if _, ok := target.docs["target/3"]; ok {
+			t.Errorf("Unexpected skipped document in target")
+		}
+	})
+
+	t.Run("Resume from checkpoint", func(t *testing.T) {
+		cluster := &mockScanCluster{numDocs: 25}
+		source, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+		target := &mockReindexTarget{exists: true}
+		targetClient, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: target.handle}})
+
+		p, err := Reindex(context.Background(), ReindexConfig{
+			Source:      source,
+			Target:      targetClient,
+			Scan:        ScanConfig{Index: []string{"test"}, Size: 10, Sort: []string{"n"}},
+			TargetIndex: "target",
+			Checkpoint:  &ReindexCheckpoint{SearchAfter: []json.RawMessage{json.RawMessage("19")}, Processed: 20},
+
+			TargetIndexBody: map[string]interface{}{},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		var ids []string
+		for k := range target.docs {
+			ids = append(ids, k)
+		}
+		sort.Strings(ids)
+		if fmt.Sprint(ids) != "[target/20 target/21 target/22 target/23 target/24]" {
+			t.Errorf("Unexpected documents: %v", ids)
+		}
+		if p.Checkpoint == nil || p.Checkpoint.Processed != 25 {
+			t.Errorf("Unexpected checkpoint: %+v", p.Checkpoint)
+		}
+		if target.created != "" {
+			t.Errorf("Unexpected creation of existing index")
+		}
+	})
+
+	t.Run("Bulk request failure", func(t *testing.T) {
+		source, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: (&mockScanCluster{numDocs: 25}).handle}})
+		targetClient, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: (&mockReindexTarget{exists: true, failBulk: true}).handle}})
+
+		var failed uint64
+		p, err := Reindex(context.Background(), ReindexConfig{
+			Source:      source,
+			Target:      targetClient,
+			Scan:        ScanConfig{Index: []string{"test"}, Size: 10, Sort: []string{"n"}},
+			BulkIndexer: BulkIndexerConfig{NumWorkers: 2, FlushBytes: 100},
+			TargetIndex: "target",
+			OnFailure: func(ctx context.Context, doc ReindexDocument, res BulkIndexerResponseItem, err error) {
+				if err == nil {
+					t.Errorf("Expected error for failed document")
+				}
+				atomic.AddUint64(&failed, 1)
+			},
+		})
+		if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("%d documents failed", p.NumFailed)) {
+			t.Errorf("Expected error for the failed bulk requests, got: %v", err)
+		}
+		// The scan stops once the target has failed.
+		if p.NumScanned == 0 || p.NumScanned == 25 || p.NumIndexed != 0 || p.NumFailed != p.NumScanned || failed != p.NumFailed {
+			t.Errorf("Unexpected progress: %+v, OnFailure calls: %d", p, failed)
+		}
+		// The failed documents are not processed, the checkpoint doesn't move past them.
+		if p.Checkpoint != nil {
+			t.Errorf("Unexpected checkpoint: %+v", p.Checkpoint)
+		}
+	})
+
+	t.Run("Resume after bulk request failure", func(t *testing.T) {
+		source, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: (&mockScanCluster{numDocs: 25}).handle}})
+		target := &mockReindexTarget{exists: true, failBulkAt: 2}
+		targetClient, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: target.handle}})
+
+		cfg := ReindexConfig{
+			Source:      source,
+			Target:      targetClient,
+			Scan:        ScanConfig{Index: []string{"test"}, Size: 10, Sort: []string{"n"}},
+			BulkIndexer: BulkIndexerConfig{NumWorkers: 1, FlushBytes: 100},
+			TargetIndex: "target",
+		}
+		p, err := Reindex(context.Background(), cfg)
+		if err == nil {
+			t.Fatalf("Expected error for the failed bulk request")
+		}
+		if p.Checkpoint == nil || p.Checkpoint.Processed == 0 || p.Checkpoint.Processed >= p.NumScanned {
+			t.Fatalf("Expected checkpoint before the failed documents, got: %+v, progress: %+v", p.Checkpoint, p)
+		}
+		if len(target.docs) == 25 {
+			t.Fatalf("Expected documents missing from the target")
+		}
+
+		cfg.Checkpoint = p.Checkpoint
+		p, err = Reindex(context.Background(), cfg)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if p.Checkpoint == nil || p.Checkpoint.Processed != 25 {
+			t.Errorf("Unexpected checkpoint: %+v", p.Checkpoint)
+		}
+		for i := 0; i < 25; i++ {
+			if _, ok := target.docs[fmt.Sprintf("target/%d", i)]; !ok {
+				t.Errorf("Document %d skipped by the resume", i)
+			}
+		}
+	})
+
+	t.Run("Checkpoint with slices", func(t *testing.T) {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: (&mockScanCluster{}).handle}})
+		_, err := Reindex(context.Background(), ReindexConfig{
+			Source:      es,
+			Target:      es,
+			Scan:        ScanConfig{Index: []string{"test"}, Sort: []string{"n"}, Slices: 2},
+			TargetIndex: "target",
+			Checkpoint:  &ReindexCheckpoint{SearchAfter: []json.RawMessage{json.RawMessage("19")}, Processed: 20},
+		})
+		if err == nil || !strings.Contains(err.Error(), "slices") {
+			t.Errorf("Expected error for checkpoint with slices, got: %v", err)
+		}
+	})
+
+	t.Run("Checkpoint requires sort", func(t *testing.T) {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: (&mockScanCluster{}).handle}})
+		_, err := Reindex(context.Background(), ReindexConfig{
+			Source:      es,
+			Target:      es,
+			Scan:        ScanConfig{Index: []string{"test"}},
+			TargetIndex: "target",
+			Checkpoint:  &ReindexCheckpoint{},
+		})
+		if err == nil {
+			t.Errorf("Expected error")
+		}
+	})
+}
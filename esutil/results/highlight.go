@@ -0,0 +1,219 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package results
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// DefaultSeparator joins the fragments of a highlighted string field.
+const DefaultSeparator = " ... "
+
+// Highlight replaces the fields of the decoded source with their highlighted fragments,
+// eg. the highlight of a hit. The source is a pointer to a struct, matched by the JSON
+// name of the fields, or a map[string]interface{}.
+//
+// Highlighted fields are addressed with their path, eg. "author.name"; the highlight
+// of a multi-field, eg. "title.english", replaces its parent field. A string field is set
+// to the fragments joined with the separator, DefaultSeparator when empty,
+// and a []string field to the fragments. Fields which are not found in the source,
+// or are within arrays of objects, are skipped.
+func Highlight(source interface{}, highlight map[string][]string, sep string) error {
+	if len(highlight) == 0 {
+		return nil
+	}
+	if sep == "" {
+		sep = DefaultSeparator
+	}
+
+	v := reflect.ValueOf(source)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("highlight: source must be a non-nil pointer, got %T", source)
+	}
+	for _, field := range sortedKeys(highlight) {
+		setHighlight(v.Elem(), strings.Split(field, "."), highlight[field], sep)
+	}
+	return nil
+}
+
+// setHighlight walks the path in the value and sets the fragments at its end,
+// or at the first string field on the way, for multi-fields.
+func setHighlight(v reflect.Value, path []string, fragments []string, sep string) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		f, ok := structField(v, path[0])
+		if !ok {
+			return
+		}
+		if len(path) > 1 && !isTextValue(f) {
+			setHighlight(f, path[1:], fragments, sep)
+			return
+		}
+		setText(f, fragments, sep)
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return
+		}
+		key := reflect.ValueOf(path[0]).Convert(v.Type().Key())
+		elem := v.MapIndex(key)
+		if !elem.IsValid() {
+			return
+		}
+		if len(path) > 1 && !isTextValue(elem) {
+			setHighlight(elem, path[1:], fragments, sep)
+			return
+		}
+		// Set a copy, as the values of a map are not addressable.
+		cp := reflect.New(v.Type().Elem()).Elem()
+		cp.Set(elem)
+		if setText(cp, fragments, sep) {
+			v.SetMapIndex(key, cp)
+		}
+	}
+}
+
+// structField returns the field of the struct with the JSON name,
+// including the fields of the embedded structs.
+func structField(v reflect.Value, name string) (reflect.Value, bool) {
+	var fold reflect.Value
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() && !sf.Anonymous {
+			continue
+		}
+
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		fieldName, _, _ := strings.Cut(tag, ",")
+
+		if sf.Anonymous && fieldName == "" {
+			f := v.Field(i)
+			if f.Kind() == reflect.Ptr {
+				if f.IsNil() {
+					continue
+				}
+				f = f.Elem()
+			}
+			if f.Kind() == reflect.Struct {
+				if ef, ok := structField(f, name); ok {
+					return ef, true
+				}
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		if fieldName == "" {
+			fieldName = sf.Name
+		}
+		if fieldName == name {
+			return v.Field(i), true
+		}
+		// Like encoding/json, prefer an exact match.
+		if !fold.IsValid() && strings.EqualFold(fieldName, name) {
+			fold = v.Field(i)
+		}
+	}
+	return fold, fold.IsValid()
+}
+
+// isTextValue returns true when the value holds a string or a list of strings.
+func isTextValue(v reflect.Value) bool {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return true
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String {
+			return true
+		}
+		return v.Len() > 0 && isTextValue(v.Index(0))
+	}
+	return false
+}
+
+// setText sets the fragments to the string or the list of strings,
+// and returns false when the value is of another type.
+func setText(v reflect.Value, fragments []string, sep string) bool {
+	if !v.CanSet() {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(strings.Join(fragments, sep))
+		return true
+
+	case reflect.Ptr:
+		if v.Type().Elem().Kind() != reflect.String {
+			return false
+		}
+		s := reflect.New(v.Type().Elem())
+		s.Elem().SetString(strings.Join(fragments, sep))
+		v.Set(s)
+		return true
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return false
+		}
+		s := reflect.MakeSlice(v.Type(), len(fragments), len(fragments))
+		for i, fragment := range fragments {
+			s.Index(i).SetString(fragment)
+		}
+		v.Set(s)
+		return true
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return false
+		}
+		switch v.Elem().Kind() {
+		case reflect.String:
+			v.Set(reflect.ValueOf(strings.Join(fragments, sep)))
+			return true
+		case reflect.Slice:
+			s := make([]interface{}, len(fragments))
+			for i, fragment := range fragments {
+				s[i] = fragment
+			}
+			v.Set(reflect.ValueOf(s))
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package results
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	t.Run("Struct", func(t *testing.T) {
+		type author struct {
+			Name string `json:"name"`
+		}
+		type base struct {
+			Summary *string `json:"summary"`
+		}
+		type doc struct {
+			base
+			Title   string   `json:"title"`
+			Body    string   `json:"body"`
+			Tags    []string `json:"tags"`
+			Author  *author  `json:"author"`
+			Count   int      `json:"count"`
+			Ignored string   `json:"-"`
+			Name    string
+		}
+
+		summary := "summary"
+		d := doc{base: base{Summary: &summary}, Title: "title", Body: "body", Author: &author{Name: "name"}, Count: 1}
+		err := Highlight(&d, map[string][]string{
+			"title.english": {"<em>title</em>"},
+			"body":          {"a <em>b</em>", "c <em>b</em>"},
+			"tags":          {"<em>go</em>"},
+			"author.name":   {"<em>name</em>"},
+			"summary":       {"<em>summary</em>"},
+			"count":         {"<em>1</em>"},
+			"Ignored":       {"x"},
+			"name":          {"<em>Name</em>"},
+			"missing.field": {"x"},
+		}, " | ")
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if d.Title != "<em>title</em>" || d.Body != "a <em>b</em> | c <em>b</em>" || fmt.Sprint(d.Tags) != "[<em>go</em>]" {
+			t.Errorf("Unexpected fields: %+v", d)
+		}
+		if d.Author.Name != "<em>name</em>" || *d.Summary != "<em>summary</em>" || d.Name != "<em>Name</em>" {
+			t.Errorf("Unexpected fields: %+v, %s, %s", d.Author, *d.Summary, d.Name)
+		}
+		if d.Count != 1 || d.Ignored != "" {
+			t.Errorf("Unexpected fields: %+v", d)
+		}
+	})
+
+	t.Run("Map", func(t *testing.T) {
+		d := map[string]interface{}{
+			"title":  "title",
+			"tags":   []interface{}{"go"},
+			"author": map[string]interface{}{"name": "name"},
+			"count":  1.0,
+		}
+		err := Highlight(&d, map[string][]string{
+			"title":       {"<em>title</em>", "<em>other</em>"},
+			"tags":        {"<em>go</em>"},
+			"author.name": {"<em>name</em>"},
+			"count":       {"<em>1</em>"},
+		}, "")
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if s := fmt.Sprint(d); s != "map[author:map[name:<em>name</em>] count:1 tags:[<em>go</em>] title:<em>title</em> ... <em>other</em>]" {
+			t.Errorf("Unexpected document: %s", s)
+		}
+	})
+
+	t.Run("Not a pointer", func(t *testing.T) {
+		if err := Highlight(struct{}{}, map[string][]string{"a": {"b"}}, ""); err == nil {
+			t.Errorf("Expected error")
+		}
+	})
+}
@@ -0,0 +1,93 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package results decodes the hits, highlights, inner hits and suggestions of search responses
+// into application types.
+//
+//	res, err := es.Search().Index("articles").Request(req).Do(ctx)
+//	articles, err := results.Highlighted[Article](res.Hits.Hits, "")
+//	for i, hit := range res.Hits.Hits {
+//		comments, err := results.Sources[Comment](results.InnerHits(hit, "comments"))
+//		...
+//	}
+//
+// The suggestions are decoded from the raw response body:
+//
+//	res, err := es.Search().Index("articles").Request(req).Perform(ctx)
+//	defer res.Body.Close()
+//	suggest, err := results.DecodeSuggest(res.Body)
+//	titles, err := results.Completions[Article](suggest, "title_suggest")
+package results
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// Source decodes the source of the hit.
+func Source[T any](hit types.Hit) (T, error) {
+	var doc T
+	if len(hit.Source_) == 0 {
+		return doc, fmt.Errorf("results: hit [%s]: no source", hit.Id_)
+	}
+	if err := json.Unmarshal(hit.Source_, &doc); err != nil {
+		return doc, fmt.Errorf("results: hit [%s]: error decoding source: %s", hit.Id_, err)
+	}
+	return doc, nil
+}
+
+// Sources decodes the source of the hits, in order.
+func Sources[T any](hits []types.Hit) ([]T, error) {
+	docs := make([]T, 0, len(hits))
+	for _, hit := range hits {
+		doc, err := Source[T](hit)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// Highlighted decodes the source of the hits, in order, with the highlighted fields
+// replaced by their fragments, see Highlight.
+func Highlighted[T any](hits []types.Hit, sep string) ([]T, error) {
+	docs := make([]T, 0, len(hits))
+	for _, hit := range hits {
+		doc, err := Source[T](hit)
+		if err != nil {
+			return nil, err
+		}
+		if err := Highlight(&doc, hit.Highlight, sep); err != nil {
+			return nil, fmt.Errorf("results: hit [%s]: %s", hit.Id_, err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// InnerHits returns the hits of the named inner hits of the hit,
+// eg. the name of a nested path, or nil when there are none.
+func InnerHits(hit types.Hit, name string) []types.Hit {
+	inner, ok := hit.InnerHits[name]
+	if !ok || inner.Hits == nil {
+		return nil
+	}
+	return inner.Hits.Hits
+}
@@ -0,0 +1,99 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package results
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
+)
+
+type comment struct {
+	Author string `json:"author"`
+	Text   string `json:"text"`
+}
+
+type article struct {
+	Title    string    `json:"title"`
+	Tags     []string  `json:"tags"`
+	Comments []comment `json:"comments"`
+}
+
+func decodeResponse(t *testing.T, body string) *search.Response {
+	t.Helper()
+	res := search.NewResponse()
+	if err := json.Unmarshal([]byte(body), res); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	return res
+}
+
+func TestSources(t *testing.T) {
+	res := decodeResponse(t, `{"hits":{"hits":[
+		{"_index":"articles","_id":"1","_source":{"title":"Go","tags":["go"],"comments":[{"author":"a","text":"nice"},{"author":"b","text":"go is great"}]},
+		 "highlight":{"title":["<em>Go</em>"]},
+		 "inner_hits":{"comments":{"hits":{"hits":[
+			{"_index":"articles","_id":"1","_nested":{"field":"comments","offset":1},"_source":{"author":"b","text":"go is great"},"highlight":{"comments.text":["<em>go</em> is great"]}}]}}}},
+		{"_index":"articles","_id":"2","_source":{"title":"Rust"}}]}}`)
+
+	t.Run("Sources", func(t *testing.T) {
+		docs, err := Sources[article](res.Hits.Hits)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(docs) != 2 || docs[0].Title != "Go" || docs[1].Title != "Rust" || len(docs[0].Comments) != 2 {
+			t.Errorf("Unexpected documents: %+v", docs)
+		}
+	})
+
+	t.Run("Highlighted", func(t *testing.T) {
+		docs, err := Highlighted[article](res.Hits.Hits, "")
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if docs[0].Title != "<em>Go</em>" || docs[1].Title != "Rust" {
+			t.Errorf("Unexpected documents: %+v", docs)
+		}
+	})
+
+	t.Run("Inner hits", func(t *testing.T) {
+		comments, err := Highlighted[comment](InnerHits(res.Hits.Hits[0], "comments"), "")
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		// The highlight of the nested field is relative to the parent document.
+		if fmt.Sprint(comments) != "[{b go is great}]" {
+			t.Errorf("Unexpected comments: %+v", comments)
+		}
+		if hits := InnerHits(res.Hits.Hits[1], "comments"); hits != nil {
+			t.Errorf("Unexpected inner hits: %+v", hits)
+		}
+	})
+
+	t.Run("Missing source", func(t *testing.T) {
+		res := decodeResponse(t, `{"hits":{"hits":[{"_index":"articles","_id":"1"}]}}`)
+		if _, err := Sources[article](res.Hits.Hits); err == nil {
+			t.Errorf("Expected error")
+		}
+	})
+}
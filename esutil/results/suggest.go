@@ -0,0 +1,149 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package results
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// Suggest represents the suggest section of a search response, with the raw entries
+// of every suggester, by the name of the suggester, with or without its typed key prefix.
+//
+// The suggestions are decoded from the raw response body rather than from the typed response,
+// which keeps a single entry per suggester, eg. the last term of the text of a term suggester.
+type Suggest map[string][]json.RawMessage
+
+// DecodeSuggest decodes the suggest section of the raw body of a search response,
+// eg. returned by Perform. It returns nil when there are no suggestions.
+func DecodeSuggest(body io.Reader) (Suggest, error) {
+	var res struct {
+		Suggest Suggest `json:"suggest"`
+	}
+	if err := json.NewDecoder(body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("results: error decoding suggest: %s", err)
+	}
+	return res.Suggest, nil
+}
+
+// entries returns the raw entries of the named suggester, which must be of the kind
+// when the response was returned with typed keys.
+func (s Suggest) entries(name, kind string) ([]json.RawMessage, error) {
+	if entries, ok := s[kind+"#"+name]; ok {
+		return entries, nil
+	}
+	if entries, ok := s[name]; ok {
+		return entries, nil
+	}
+	for _, key := range sortedKeys(s) {
+		if k, n, ok := strings.Cut(key, "#"); ok && n == name {
+			return nil, fmt.Errorf("results: suggest [%s]: expected %s suggestions, got %s", name, kind, k)
+		}
+	}
+	return nil, nil
+}
+
+// CompletionOption represents an option of a completion suggester, with its decoded source.
+type CompletionOption[T any] struct {
+	types.CompletionSuggestOption
+	Source T
+}
+
+// Completions returns the options of the named completion suggester,
+// with the source decoded, or nil when there are no suggestions.
+func Completions[T any](suggest Suggest, name string) ([]CompletionOption[T], error) {
+	entries, err := suggest.entries(name, "completion")
+	if err != nil {
+		return nil, err
+	}
+	var options []CompletionOption[T]
+	for _, raw := range entries {
+		cs := types.NewCompletionSuggest()
+		if err := json.Unmarshal(raw, cs); err != nil {
+			return nil, fmt.Errorf("results: suggest [%s]: %s", name, err)
+		}
+		for _, o := range cs.Options {
+			opt := CompletionOption[T]{CompletionSuggestOption: o}
+			if len(o.Source_) > 0 {
+				if err := json.Unmarshal(o.Source_, &opt.Source); err != nil {
+					return nil, fmt.Errorf("results: suggest [%s]: error decoding source: %s", name, err)
+				}
+			}
+			options = append(options, opt)
+		}
+	}
+	return options, nil
+}
+
+// Phrases returns the options of the named phrase suggester, or nil when there are no suggestions.
+func Phrases(suggest Suggest, name string) ([]types.PhraseSuggestOption, error) {
+	entries, err := suggest.entries(name, "phrase")
+	if err != nil {
+		return nil, err
+	}
+	var options []types.PhraseSuggestOption
+	for _, raw := range entries {
+		ps := types.NewPhraseSuggest()
+		if err := json.Unmarshal(raw, ps); err != nil {
+			return nil, fmt.Errorf("results: suggest [%s]: %s", name, err)
+		}
+		options = append(options, ps.Options...)
+	}
+	return options, nil
+}
+
+// TermSuggestion represents the suggestions of a term suggester for a term of the text.
+type TermSuggestion struct {
+	Text    string
+	Offset  int
+	Length  int
+	Options []types.TermSuggestOption
+}
+
+// Terms returns the suggestions of the named term suggester, for every term of the text, in order.
+func Terms(suggest Suggest, name string) ([]TermSuggestion, error) {
+	entries, err := suggest.entries(name, "term")
+	if err != nil {
+		return nil, err
+	}
+	var suggestions []TermSuggestion
+	for _, raw := range entries {
+		ts := types.NewTermSuggest()
+		if err := json.Unmarshal(raw, ts); err != nil {
+			return nil, fmt.Errorf("results: suggest [%s]: %s", name, err)
+		}
+		suggestions = append(suggestions, TermSuggestion{Text: ts.Text, Offset: ts.Offset, Length: ts.Length, Options: ts.Options})
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool { return suggestions[i].Offset < suggestions[j].Offset })
+	return suggestions, nil
+}
+
+// sortedKeys returns the keys of the map, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,109 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package results
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSuggest(t *testing.T) {
+	suggest, err := DecodeSuggest(strings.NewReader(`{"hits":{"hits":[]},"suggest":{
+		"completion#titles":[{"text":"go","offset":0,"length":2,"options":[
+			{"text":"Go concurrency","_index":"articles","_id":"1","_score":2,"_source":{"title":"Go concurrency"}},
+			{"text":"Go modules","_index":"articles","_id":"2","_score":1,"_source":{"title":"Go modules"}}]}],
+		"phrase#phrase":[{"text":"go concurency","offset":0,"length":13,"options":[{"text":"go concurrency","highlighted":"go <em>concurrency</em>","score":0.5}]}],
+		"term#terms":[
+			{"text":"helo","offset":0,"length":4,"options":[{"text":"hello","score":0.75,"freq":12}]},
+			{"text":"wrld","offset":5,"length":4,"options":[{"text":"world","score":0.75,"freq":7}]}]}}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	t.Run("Completions", func(t *testing.T) {
+		options, err := Completions[article](suggest, "titles")
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(options) != 2 || options[0].Source.Title != "Go concurrency" || *options[1].Id_ != "2" {
+			t.Errorf("Unexpected options: %+v", options)
+		}
+	})
+
+	t.Run("Phrases", func(t *testing.T) {
+		options, err := Phrases(suggest, "phrase")
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(options) != 1 || *options[0].Highlighted != "go <em>concurrency</em>" {
+			t.Errorf("Unexpected options: %+v", options)
+		}
+	})
+
+	t.Run("Terms", func(t *testing.T) {
+		suggestions, err := Terms(suggest, "terms")
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(suggestions) != 2 {
+			t.Fatalf("Expected a suggestion for every term, got: %+v", suggestions)
+		}
+		for i, expected := range []struct {
+			text   string
+			offset int
+			option string
+		}{{"helo", 0, "hello"}, {"wrld", 5, "world"}} {
+			s := suggestions[i]
+			if s.Text != expected.text || s.Offset != expected.offset || len(s.Options) != 1 || s.Options[0].Text != expected.option {
+				t.Errorf("Unexpected suggestion: %+v", s)
+			}
+		}
+	})
+
+	t.Run("Without typed keys", func(t *testing.T) {
+		suggest, err := DecodeSuggest(strings.NewReader(`{"suggest":{"phrase":[{"text":"go concurency","offset":0,"length":13,"options":[{"text":"go concurrency","score":0.5}]}]}}`))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		options, err := Phrases(suggest, "phrase")
+		if err != nil || len(options) != 1 || options[0].Text != "go concurrency" {
+			t.Errorf("Unexpected result: %+v, %v", options, err)
+		}
+	})
+
+	t.Run("Missing suggester", func(t *testing.T) {
+		options, err := Phrases(suggest, "missing")
+		if err != nil || options != nil {
+			t.Errorf("Unexpected result: %v, %v", options, err)
+		}
+	})
+
+	t.Run("Unexpected type", func(t *testing.T) {
+		_, err := Terms(suggest, "titles")
+		if err == nil {
+			t.Fatalf("Expected error")
+		}
+		if fmt.Sprint(err) != "results: suggest [titles]: expected term suggestions, got completion" {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	})
+}
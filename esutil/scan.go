@@ -0,0 +1,353 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// ScanConfig represents configuration of the scan.
+type ScanConfig struct {
+	Index     []string // The indices to scan. Required.
+	KeepAlive string   // The point in time keep alive, refreshed with every page. Defaults to 1m.
+	Size      int      // The number of hits per page. Defaults to 1000.
+	Slices    int      // The number of slices scanned in parallel. Defaults to 1.
+
+	// Query is encoded as the query of the search request, eg. a *types.Query,
+	// a map or a json.RawMessage. Defaults to match_all.
+	Query interface{}
+
+	// Sort is encoded as the sort of the search request. Defaults to _shard_doc,
+	// the most efficient order; Elasticsearch adds the _shard_doc tie-breaker
+	// to any other sort.
+	Sort interface{}
+
+	Source interface{} // Encoded as the _source of the search request, eg. false or a list of fields.
+	Fields []string    // The fields to retrieve with the hits.
+
+	// SearchAfter resumes the scan after the sort values of a hit, see ScanHit.Sort.
+	// It can't be combined with Slices, since the sort values belong to a single slice.
+	SearchAfter []json.RawMessage
+
+	// Parameters of the Open Point In Time API.
+	ExpandWildcards   string
+	IgnoreUnavailable *bool
+	Preference        string
+	Routing           string
+
+	Header http.Header
+}
+
+// ScanHit represents a single hit of the scan.
+type ScanHit struct {
+	Index   string                     `json:"_index"`
+	ID      string                     `json:"_id"`
+	Score   *float64                   `json:"_score"`
+	Routing string                     `json:"_routing,omitempty"`
+	Source  json.RawMessage            `json:"_source,omitempty"`
+	Fields  map[string]json.RawMessage `json:"fields,omitempty"`
+
+	// Sort contains the raw sort values of the hit,
+	// which can be used as ScanConfig.SearchAfter to resume the scan.
+	Sort []json.RawMessage `json:"sort,omitempty"`
+
+	Slice int `json:"-"` // The slice which returned the hit
+}
+
+// Scan reads all documents matching the query with a point in time and search_after,
+// and calls fn for every hit. With more than one slice, fn is called concurrently
+// from a goroutine per slice.
+//
+// The scan stops at the first error returned by fn or by Elasticsearch,
+// or when the context is done. The point in time is always closed.
+//
+// The client is either a *elasticsearch.Client or a *elasticsearch.TypedClient.
+func Scan(ctx context.Context, client esapi.Transport, cfg ScanConfig, fn func(context.Context, ScanHit) error) error {
+	if len(cfg.Index) == 0 {
+		return errors.New("scan: index is required")
+	}
+	if cfg.KeepAlive == "" {
+		cfg.KeepAlive = "1m"
+	}
+	if cfg.Size == 0 {
+		cfg.Size = 1000
+	}
+	if cfg.Slices == 0 {
+		cfg.Slices = 1
+	}
+	if cfg.SearchAfter != nil && cfg.Slices > 1 {
+		return errors.New("scan: search after can't be combined with slices")
+	}
+
+	s := scanner{client: client, cfg: cfg, fn: fn}
+	return s.run(ctx)
+}
+
+// scanner represents a single scan over a point in time.
+type scanner struct {
+	client esapi.Transport
+	cfg    ScanConfig
+	fn     func(context.Context, ScanHit) error
+
+	mu     sync.Mutex
+	pitIDs map[string]struct{} // All point in time IDs seen, closed when done
+}
+
+// run opens the point in time, scans the slices and closes the point in time.
+func (s *scanner) run(ctx context.Context) (err error) {
+	pitID, err := s.open(ctx)
+	if err != nil {
+		return err
+	}
+	s.pitIDs = map[string]struct{}{pitID: {}}
+
+	defer func() {
+		// Use a separate context, so that the point in time is closed on cancellation.
+		if cerr := s.close(context.WithoutCancel(ctx)); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+	)
+	for i := 0; i < s.cfg.Slices; i++ {
+		wg.Add(1)
+		go func(slice int) {
+			defer wg.Done()
+			if serr := s.scanSlice(ctx, pitID, slice); serr != nil {
+				once.Do(func() {
+					err = serr
+					cancel()
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	return err
+}
+
+// open opens the point in time and returns its ID.
+func (s *scanner) open(ctx context.Context) (string, error) {
+	req := esapi.OpenPointInTimeRequest{
+		Index:     s.cfg.Index,
+		KeepAlive: s.cfg.KeepAlive,
+
+		ExpandWildcards:   s.cfg.ExpandWildcards,
+		IgnoreUnavailable: s.cfg.IgnoreUnavailable,
+		Preference:        s.cfg.Preference,
+		Routing:           s.cfg.Routing,
+
+		Header: s.header(),
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return "", fmt.Errorf("scan: open point in time: %s", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return "", fmt.Errorf("scan: open point in time: %s", res.String())
+	}
+
+	var pit struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&pit); err != nil {
+		return "", fmt.Errorf("scan: open point in time: error parsing response body: %s", err)
+	}
+	return pit.ID, nil
+}
+
+// close closes all point in time IDs seen during the scan.
+func (s *scanner) close(ctx context.Context) error {
+	var errs []error
+	for id := range s.pitIDs {
+		body, _ := json.Marshal(map[string]string{"id": id})
+		req := esapi.ClosePointInTimeRequest{Body: bytes.NewReader(body), Header: s.header()}
+
+		res, err := req.Do(ctx, s.client)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("scan: close point in time: %s", err))
+			continue
+		}
+		res.Body.Close()
+		// A point in time ID replaced during the scan may already be gone.
+		if res.IsError() && res.StatusCode != http.StatusNotFound {
+			errs = append(errs, fmt.Errorf("scan: close point in time: %s", res.String()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// scanSlice pages through a single slice with search_after.
+func (s *scanner) scanSlice(ctx context.Context, pitID string, slice int) error {
+	searchAfter := s.cfg.SearchAfter
+
+	for {
+		body, err := s.searchBody(pitID, slice, searchAfter)
+		if err != nil {
+			return err
+		}
+
+		req := esapi.SearchRequest{Body: bytes.NewReader(body), Header: s.header()}
+		res, err := req.Do(ctx, s.client)
+		if err != nil {
+			return fmt.Errorf("scan: search: %s", err)
+		}
+
+		var page struct {
+			PitID string `json:"pit_id"`
+			Hits  struct {
+				Hits []ScanHit `json:"hits"`
+			} `json:"hits"`
+		}
+		if res.IsError() {
+			res.Body.Close()
+			return fmt.Errorf("scan: search: %s", res.String())
+		}
+		err = json.NewDecoder(res.Body).Decode(&page)
+		res.Body.Close()
+		if err != nil {
+			return fmt.Errorf("scan: search: error parsing response body: %s", err)
+		}
+
+		if page.PitID != "" && page.PitID != pitID {
+			pitID = page.PitID
+			s.mu.Lock()
+			s.pitIDs[pitID] = struct{}{}
+			s.mu.Unlock()
+		}
+
+		for _, hit := range page.Hits.Hits {
+			hit.Slice = slice
+			if err := s.fn(ctx, hit); err != nil {
+				return err
+			}
+		}
+
+		if len(page.Hits.Hits) < s.cfg.Size {
+			return nil
+		}
+		searchAfter = page.Hits.Hits[len(page.Hits.Hits)-1].Sort
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// searchBody encodes the search request for the page.
+func (s *scanner) searchBody(pitID string, slice int, searchAfter []json.RawMessage) ([]byte, error) {
+	body := map[string]interface{}{
+		"size":             s.cfg.Size,
+		"track_total_hits": false,
+		"pit":              map[string]string{"id": pitID, "keep_alive": s.cfg.KeepAlive},
+	}
+	if s.cfg.Query != nil {
+		body["query"] = s.cfg.Query
+	}
+	if s.cfg.Sort != nil {
+		body["sort"] = s.cfg.Sort
+	} else {
+		body["sort"] = []string{"_shard_doc"}
+	}
+	if s.cfg.Source != nil {
+		body["_source"] = s.cfg.Source
+	}
+	if len(s.cfg.Fields) > 0 {
+		body["fields"] = s.cfg.Fields
+	}
+	if len(searchAfter) > 0 {
+		body["search_after"] = searchAfter
+	}
+	if s.cfg.Slices > 1 {
+		body["slice"] = map[string]int{"id": slice, "max": s.cfg.Slices}
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("scan: error encoding request body: %s", err)
+	}
+	return b, nil
+}
+
+// header returns the request header with the helper meta header.
+func (s *scanner) header() http.Header {
+	h := s.cfg.Header.Clone()
+	if h == nil {
+		h = http.Header{}
+	}
+	h.Set(elasticsearch.HeaderClientMeta, "h=s")
+	return h
+}
+
+// ScanIterator iterates over the hits of a scan running in the background.
+//
+// It must be closed, to stop the scan and close the point in time,
+// unless Next returned false.
+type ScanIterator struct {
+	it *iterator[ScanHit]
+}
+
+// NewScanIterator starts the scan in the background and returns an iterator over the hits.
+func NewScanIterator(ctx context.Context, client esapi.Transport, cfg ScanConfig) *ScanIterator {
+	return &ScanIterator{it: newIterator(ctx, cfg.Size, func(ctx context.Context, emit func(context.Context, ScanHit) error) error {
+		return Scan(ctx, client, cfg, emit)
+	})}
+}
+
+// Hits returns the channel of the hits, closed when the scan ends.
+// Check Err after the channel is closed.
+func (it *ScanIterator) Hits() <-chan ScanHit {
+	return it.it.items
+}
+
+// Next advances the iterator to the next hit. It returns false when the scan ends.
+func (it *ScanIterator) Next() bool {
+	return it.it.next()
+}
+
+// Hit returns the current hit.
+func (it *ScanIterator) Hit() ScanHit {
+	return it.it.item
+}
+
+// Err returns the error of the scan, once it has ended.
+func (it *ScanIterator) Err() error {
+	return it.it.err()
+}
+
+// Close stops the scan and waits until the point in time is closed.
+func (it *ScanIterator) Close() error {
+	return it.it.close()
+}
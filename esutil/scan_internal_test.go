@@ -0,0 +1,270 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// mockScanCluster serves point in time and search requests over numDocs documents.
+type mockScanCluster struct {
+	mu sync.Mutex
+
+	numDocs  int
+	opened   []string
+	closed   []string
+	searches []map[string]interface{}
+	failAt   int // Fail the n-th search request, when set
+}
+
+func (m *mockScanCluster) handle(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/_pit") && req.Method == http.MethodPost:
+		id := fmt.Sprintf("pit-%d", len(m.opened)+1)
+		m.opened = append(m.opened, req.URL.Path+"?"+req.URL.Query().Get("keep_alive"))
+		return mockResponse(200, fmt.Sprintf(`{"id":%q}`, id))
+
+	case req.URL.Path == "/_pit" && req.Method == http.MethodDelete:
+		var body struct {
+			ID string `json:"id"`
+		}
+		json.NewDecoder(req.Body).Decode(&body)
+		m.closed = append(m.closed, body.ID)
+		return mockResponse(200, `{"succeeded":true,"num_freed":1}`)
+
+	case req.URL.Path == "/_search":
+		var body map[string]interface{}
+		json.NewDecoder(req.Body).Decode(&body)
+		m.searches = append(m.searches, body)
+		if m.failAt > 0 && len(m.searches) == m.failAt {
+			return mockResponse(500, `{"error":{"type":"search_phase_execution_exception"},"status":500}`)
+		}
+
+		var (
+			size  = int(body["size"].(float64))
+			after = -1
+			slice = 0
+			max   = 1
+		)
+		if sa, ok := body["search_after"].([]interface{}); ok {
+			after = int(sa[0].(float64))
+		}
+		if sl, ok := body["slice"].(map[string]interface{}); ok {
+			slice, max = int(sl["id"].(float64)), int(sl["max"].(float64))
+		}
+
+		var hits []string
+		for i := after + 1; i < m.numDocs && len(hits) < size; i++ {
+			if i%max != slice {
+				continue
+			}
+			hits = append(hits, fmt.Sprintf(`{"_index":"test","_id":"%d","_source":{"n":%d},"sort":[%d]}`, i, i, i))
+		}
+		return mockResponse(200, fmt.Sprintf(`{"pit_id":"pit-1","hits":{"hits":[%s]}}`, strings.Join(hits, ",")))
+	}
+
+	return mockResponse(404, `{}`)
+}
+
+func TestScan(t *testing.T) {
+	t.Run("Basic", func(t *testing.T) {
+		cluster := &mockScanCluster{numDocs: 25}
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+
+		var ids []string
+		err := Scan(context.Background(), es, ScanConfig{Index: []string{"test"}, Size: 10},
+			func(ctx context.Context, hit ScanHit) error {
+				ids = append(ids, hit.ID)
+				return nil
+			})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if len(ids) != 25 || ids[0] != "0" || ids[24] != "24" {
+			t.Errorf("Unexpected hits: %v", ids)
+		}
+		if len(cluster.searches) != 3 {
+			t.Errorf("Unexpected number of searches: %d", len(cluster.searches))
+		}
+		if cluster.opened[0] != "/test/_pit?1m" {
+			t.Errorf("Unexpected open request: %s", cluster.opened[0])
+		}
+		if len(cluster.closed) != 1 || cluster.closed[0] != "pit-1" {
+			t.Errorf("Expected the point in time to be closed, got: %v", cluster.closed)
+		}
+
+		pit := cluster.searches[1]["pit"].(map[string]interface{})
+		if pit["id"] != "pit-1" || pit["keep_alive"] != "1m" {
+			t.Errorf("Unexpected pit: %v", pit)
+		}
+		if sort := fmt.Sprint(cluster.searches[0]["sort"]); sort != "[_shard_doc]" {
+			t.Errorf("Unexpected sort: %s", sort)
+		}
+	})
+
+	t.Run("Slices", func(t *testing.T) {
+		cluster := &mockScanCluster{numDocs: 100}
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+
+		var (
+			mu   sync.Mutex
+			seen = make(map[string]int)
+		)
+		err := Scan(context.Background(), es, ScanConfig{Index: []string{"test"}, Size: 10, Slices: 4},
+			func(ctx context.Context, hit ScanHit) error {
+				mu.Lock()
+				seen[hit.ID]++
+				mu.Unlock()
+				return nil
+			})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if len(seen) != 100 {
+			t.Errorf("Unexpected number of hits: %d", len(seen))
+		}
+		for id, n := range seen {
+			if n != 1 {
+				t.Errorf("Unexpected duplicate hit [%s]: %d", id, n)
+			}
+		}
+	})
+
+	t.Run("Callback error closes point in time", func(t *testing.T) {
+		cluster := &mockScanCluster{numDocs: 25}
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+
+		errStop := errors.New("stop")
+		err := Scan(context.Background(), es, ScanConfig{Index: []string{"test"}, Size: 10},
+			func(ctx context.Context, hit ScanHit) error {
+				if hit.ID == "12" {
+					return errStop
+				}
+				return nil
+			})
+		if err != errStop {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if len(cluster.closed) != 1 {
+			t.Errorf("Expected the point in time to be closed, got: %v", cluster.closed)
+		}
+	})
+
+	t.Run("Search error closes point in time", func(t *testing.T) {
+		cluster := &mockScanCluster{numDocs: 25, failAt: 2}
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+
+		err := Scan(context.Background(), es, ScanConfig{Index: []string{"test"}, Size: 10},
+			func(ctx context.Context, hit ScanHit) error { return nil })
+		if err == nil {
+			t.Errorf("Expected error")
+		}
+		if len(cluster.closed) != 1 {
+			t.Errorf("Expected the point in time to be closed, got: %v", cluster.closed)
+		}
+	})
+
+	t.Run("Missing index", func(t *testing.T) {
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: (&mockScanCluster{}).handle}})
+		if err := Scan(context.Background(), es, ScanConfig{}, nil); err == nil {
+			t.Errorf("Expected error")
+		}
+	})
+
+	t.Run("Search after with slices", func(t *testing.T) {
+		cluster := &mockScanCluster{numDocs: 25}
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+
+		cfg := ScanConfig{Index: []string{"test"}, Slices: 2, SearchAfter: []json.RawMessage{json.RawMessage("10")}}
+		err := Scan(context.Background(), es, cfg, func(context.Context, ScanHit) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), "can't be combined with slices") {
+			t.Errorf("Expected error for search after with slices, got: %v", err)
+		}
+		if len(cluster.opened) != 0 {
+			t.Errorf("Unexpected point in time: %v", cluster.opened)
+		}
+	})
+}
+
+func TestScanIterator(t *testing.T) {
+	t.Run("Next", func(t *testing.T) {
+		cluster := &mockScanCluster{numDocs: 25}
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+
+		it := NewScanIterator(context.Background(), es, ScanConfig{Index: []string{"test"}, Size: 10})
+		var n int
+		for it.Next() {
+			var doc struct {
+				N int `json:"n"`
+			}
+			if err := json.Unmarshal(it.Hit().Source, &doc); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if doc.N != n {
+				t.Errorf("Unexpected document: want=%d, got=%d", n, doc.N)
+			}
+			n++
+		}
+		if err := it.Err(); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if n != 25 {
+			t.Errorf("Unexpected number of hits: %d", n)
+		}
+		if len(cluster.closed) != 1 {
+			t.Errorf("Expected the point in time to be closed, got: %v", cluster.closed)
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		cluster := &mockScanCluster{numDocs: 1000}
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+
+		it := NewScanIterator(context.Background(), es, ScanConfig{Index: []string{"test"}, Size: 10})
+		<-it.Hits()
+		if err := it.Close(); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		cluster.mu.Lock()
+		defer cluster.mu.Unlock()
+		if len(cluster.searches) >= 100 {
+			t.Errorf("Expected the scan to stop, got %d searches", len(cluster.searches))
+		}
+		if len(cluster.closed) != 1 {
+			t.Errorf("Expected the point in time to be closed, got: %v", cluster.closed)
+		}
+	})
+}
@@ -0,0 +1,435 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package schema
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/elastic/elastic-transport-go/v8/elastictransport"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/cluster/getcomponenttemplate"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/cluster/putcomponenttemplate"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/ilm/getlifecycle"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/ilm/putlifecycle"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/indices/getalias"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/indices/getindextemplate"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/indices/putindextemplate"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/indices/updatealiases"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/ingest/getpipeline"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/ingest/putpipeline"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// The actions of a change.
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+)
+
+// Plan represents the changes needed to bring the cluster to the schema, in dependency order.
+type Plan struct {
+	Changes []Change
+}
+
+// Change represents the creation or update of a single resource.
+type Change struct {
+	Kind   string        // One of the Kind constants
+	Name   string        // The name of the resource
+	Action string        // ActionCreate or ActionUpdate
+	Fields []FieldChange // The changed fields, for updates
+
+	body json.RawMessage
+}
+
+// FieldChange represents a field which differs between the cluster and the schema.
+type FieldChange struct {
+	Path string      // The dotted path of the field, eg. "template.settings.index.number_of_shards"
+	Old  interface{} // The value in the cluster, nil when it is missing
+	New  interface{} // The value in the schema
+}
+
+// HasChanges returns true when the cluster differs from the schema.
+func (p *Plan) HasChanges() bool {
+	return len(p.Changes) > 0
+}
+
+// String returns the changes as a readable diff.
+func (p *Plan) String() string {
+	if !p.HasChanges() {
+		return "No changes.\n"
+	}
+
+	var b strings.Builder
+	for _, c := range p.Changes {
+		switch c.Action {
+		case ActionCreate:
+			fmt.Fprintf(&b, "+ create %s [%s]\n", c.Kind, c.Name)
+		default:
+			fmt.Fprintf(&b, "~ update %s [%s]\n", c.Kind, c.Name)
+		}
+		for _, f := range c.Fields {
+			fmt.Fprintf(&b, "    %s: %s -> %s\n", f.Path, formatValue(f.Old), formatValue(f.New))
+		}
+	}
+	return b.String()
+}
+
+// Manager compares a schema with the cluster and applies the changes.
+type Manager struct {
+	client elastictransport.Interface
+	schema *Schema
+}
+
+// NewManager creates a manager for the schema.
+//
+// The client is a *elasticsearch.TypedClient or a *elasticsearch.Client.
+func NewManager(client elastictransport.Interface, s *Schema) *Manager {
+	return &Manager{client: client, schema: s}
+}
+
+// Plan compares the schema with the cluster, and returns the changes.
+//
+// Only the fields set in the schema are compared, since Elasticsearch
+// adds defaults to the stored resources. Index settings are compared
+// in their canonical "index." form.
+func (m *Manager) Plan(ctx context.Context) (*Plan, error) {
+	var plan Plan
+
+	for _, name := range sortedKeys(m.schema.ILMPolicies) {
+		desired := m.schema.ILMPolicies[name]
+		res, err := getlifecycle.NewGetLifecycleFunc(m.client)().Policy(name).Do(ctx)
+		var current interface{}
+		if lc, ok := res[name]; ok {
+			current = lc.Policy
+		}
+		if err := plan.add(KindILMPolicy, name, desired, current, err, map[string]interface{}{"policy": desired}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range sortedKeys(m.schema.IngestPipelines) {
+		desired := m.schema.IngestPipelines[name]
+		res, err := getpipeline.NewGetPipelineFunc(m.client)().Id(name).Do(ctx)
+		var current interface{}
+		if p, ok := res[name]; ok {
+			current = p
+		}
+		if err := plan.add(KindIngestPipeline, name, desired, current, err, desired); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range sortedKeys(m.schema.ComponentTemplates) {
+		desired := m.schema.ComponentTemplates[name]
+		res, err := getcomponenttemplate.NewGetComponentTemplateFunc(m.client)().Name(name).Do(ctx)
+		var current interface{}
+		if res != nil && len(res.ComponentTemplates) > 0 {
+			current = res.ComponentTemplates[0].ComponentTemplate
+		}
+		if err := plan.add(KindComponentTemplate, name, desired, current, err, desired); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range sortedKeys(m.schema.IndexTemplates) {
+		desired := m.schema.IndexTemplates[name]
+		res, err := getindextemplate.NewGetIndexTemplateFunc(m.client)().Name(name).Do(ctx)
+		var current interface{}
+		if res != nil && len(res.IndexTemplates) > 0 {
+			current = res.IndexTemplates[0].IndexTemplate
+		}
+		if err := plan.add(KindIndexTemplate, name, desired, current, err, desired); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range sortedKeys(m.schema.Aliases) {
+		alias := m.schema.Aliases[name]
+		res, err := getalias.NewGetAliasFunc(m.client)().Name(name).Do(ctx)
+		if err != nil && !isNotFound(err) {
+			return nil, fmt.Errorf("plan: %s [%s]: %w", KindAlias, name, err)
+		}
+		desired, current, body := aliasState(name, alias, res)
+		if err := plan.add(KindAlias, name, desired, current, nil, body); err != nil {
+			return nil, err
+		}
+	}
+
+	return &plan, nil
+}
+
+// Apply pushes the changes of the plan to the cluster, in order.
+// It stops at the first failed change.
+func (m *Manager) Apply(ctx context.Context, plan *Plan) error {
+	for _, c := range plan.Changes {
+		var err error
+		body := bytes.NewReader(c.body)
+
+		switch c.Kind {
+		case KindILMPolicy:
+			_, err = putlifecycle.NewPutLifecycleFunc(m.client)(c.Name).Raw(body).Do(ctx)
+		case KindIngestPipeline:
+			_, err = putpipeline.NewPutPipelineFunc(m.client)(c.Name).Raw(body).Do(ctx)
+		case KindComponentTemplate:
+			_, err = putcomponenttemplate.NewPutComponentTemplateFunc(m.client)(c.Name).Raw(body).Do(ctx)
+		case KindIndexTemplate:
+			_, err = putindextemplate.NewPutIndexTemplateFunc(m.client)(c.Name).Raw(body).Do(ctx)
+		case KindAlias:
+			_, err = updatealiases.NewUpdateAliasesFunc(m.client)().Raw(body).Do(ctx)
+		default:
+			err = fmt.Errorf("unknown kind [%s]", c.Kind)
+		}
+		if err != nil {
+			return fmt.Errorf("apply: %s [%s]: %w", c.Kind, c.Name, err)
+		}
+	}
+	return nil
+}
+
+// add compares the desired and current resource, and adds the change
+// with the request body to the plan, when they differ.
+func (p *Plan) add(kind, name string, desired, current interface{}, getErr error, body interface{}) error {
+	if getErr != nil && !isNotFound(getErr) {
+		return fmt.Errorf("plan: %s [%s]: %w", kind, name, getErr)
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("plan: %s [%s]: error encoding request body: %s", kind, name, err)
+	}
+	c := Change{Kind: kind, Name: name, body: b}
+
+	if current == nil {
+		c.Action = ActionCreate
+		p.Changes = append(p.Changes, c)
+		return nil
+	}
+
+	fields, err := diff(desired, current)
+	if err != nil {
+		return fmt.Errorf("plan: %s [%s]: %s", kind, name, err)
+	}
+	if len(fields) > 0 {
+		c.Action = ActionUpdate
+		c.Fields = fields
+		p.Changes = append(p.Changes, c)
+	}
+	return nil
+}
+
+// aliasState returns the desired and current state of the alias, for comparison,
+// and the body of the update aliases request.
+func aliasState(name string, alias Alias, res getalias.Response) (desired, current, body interface{}) {
+	indices := append([]string(nil), alias.Indices...)
+	sort.Strings(indices)
+
+	desiredDefs := make(map[string]interface{}, len(indices))
+	for _, index := range indices {
+		desiredDefs[index] = alias.Definition
+	}
+	desired = map[string]interface{}{"indices": indices, "definitions": desiredDefs}
+
+	var (
+		actions    []map[string]interface{}
+		currentIdx []string
+		wanted     = make(map[string]bool, len(indices))
+	)
+	for _, index := range indices {
+		wanted[index] = true
+	}
+
+	currentDefs := make(map[string]interface{})
+	for index, aliases := range res {
+		def, ok := aliases.Aliases[name]
+		if !ok {
+			continue
+		}
+		currentIdx = append(currentIdx, index)
+		currentDefs[index] = def
+		if !wanted[index] {
+			actions = append(actions, map[string]interface{}{"remove": map[string]interface{}{"index": index, "alias": name}})
+		}
+	}
+	sort.Strings(currentIdx)
+	sort.Slice(actions, func(i, j int) bool {
+		return fmt.Sprint(actions[i]) < fmt.Sprint(actions[j])
+	})
+
+	if len(currentIdx) > 0 {
+		current = map[string]interface{}{"indices": currentIdx, "definitions": currentDefs}
+	}
+
+	for _, index := range indices {
+		add := map[string]interface{}{}
+		if b, err := json.Marshal(alias.Definition); err == nil {
+			json.Unmarshal(b, &add)
+		}
+		add["index"] = index
+		add["alias"] = name
+		actions = append(actions, map[string]interface{}{"add": add})
+	}
+	body = map[string]interface{}{"actions": actions}
+
+	return desired, current, body
+}
+
+// diff returns the fields set in desired which differ in current.
+func diff(desired, current interface{}) ([]FieldChange, error) {
+	d, err := flatten(desired)
+	if err != nil {
+		return nil, err
+	}
+	c, err := flatten(current)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []FieldChange
+	for _, path := range sortedKeys(d) {
+		dv := d[path]
+		cv, ok := c[path]
+
+		// An empty object is only compared for presence, eg. the delete action of an ILM phase.
+		if m, isMap := dv.(map[string]interface{}); isMap && len(m) == 0 {
+			if ok || hasPrefix(c, path+".") {
+				continue
+			}
+		} else if ok && equal(dv, cv) {
+			continue
+		}
+		fields = append(fields, FieldChange{Path: path, Old: cv, New: dv})
+	}
+	return fields, nil
+}
+
+// flatten returns the leaf values of v, encoded as JSON, by dotted path.
+func flatten(v interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(b, &generic); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]interface{})
+	walk("", generic, out)
+	return out, nil
+}
+
+// walk adds the leaf values of v under the prefix to out.
+// Null values are left out, and empty objects are leaves.
+func walk(prefix string, v interface{}, out map[string]interface{}) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		if v != nil && prefix != "" {
+			out[prefix] = v
+		}
+		return
+	}
+	if len(m) == 0 && prefix != "" {
+		out[prefix] = m
+		return
+	}
+
+	for k, e := range m {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+		if path == "template.settings" {
+			e = canonicalSettings(e)
+		}
+		walk(path, e, out)
+	}
+}
+
+// canonicalSettings prefixes the index settings with "index.",
+// the form in which Elasticsearch returns them.
+func canonicalSettings(v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, e := range m {
+		if k != "index" && !strings.HasPrefix(k, "index.") {
+			k = "index." + k
+		}
+		out[k] = e
+	}
+	return out
+}
+
+// equal compares JSON values, numbers and booleans are equal to their string form,
+// as Elasticsearch returns settings as strings.
+func equal(a, b interface{}) bool {
+	switch a.(type) {
+	case []interface{}, map[string]interface{}:
+		ab, _ := json.Marshal(a)
+		bb, _ := json.Marshal(b)
+		return bytes.Equal(ab, bb)
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// hasPrefix returns true when any path in m starts with prefix.
+func hasPrefix(m map[string]interface{}, prefix string) bool {
+	for path := range m {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatValue returns the JSON representation of a value in the plan.
+func formatValue(v interface{}) string {
+	if v == nil {
+		return "<none>"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// isNotFound returns true for the 404 errors of the getters.
+func isNotFound(err error) bool {
+	var esErr *types.ElasticsearchError
+	return errors.As(err, &esErr) && esErr.Status == http.StatusNotFound
+}
+
+// sortedKeys returns the keys of the map, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,197 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esutil/internal/estest"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// mockSchemaCluster serves the getters from resources by path, and records the other requests.
+type mockSchemaCluster struct {
+	mu sync.Mutex
+
+	resources map[string]string
+	requests  []string
+	fail      string // The path of a request to fail
+}
+
+func (m *mockSchemaCluster) handle(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if req.Method == http.MethodGet {
+		if body, ok := m.resources[req.URL.Path]; ok {
+			return estest.Response(200, body)
+		}
+		return estest.Response(404, `{"error":{"type":"resource_not_found_exception","reason":"not found"},"status":404}`)
+	}
+
+	body, _ := ioutil.ReadAll(req.Body)
+	m.requests = append(m.requests, req.Method+" "+req.URL.Path+" "+string(body))
+	if req.URL.Path == m.fail {
+		return estest.Response(400, `{"error":{"type":"illegal_argument_exception","reason":"invalid"},"status":400}`)
+	}
+	return estest.Response(200, `{"acknowledged":true}`)
+}
+
+func newTestSchema() *Schema {
+	var s Schema
+
+	s.ILMPolicies = map[string]types.IlmPolicy{}
+	var policy types.IlmPolicy
+	json.Unmarshal([]byte(`{"phases":{"hot":{"actions":{"rollover":{"max_age":"7d"}}},"delete":{"min_age":"30d","actions":{"delete":{}}}}}`), &policy)
+	s.ILMPolicies["logs"] = policy
+
+	description := "Parse logs"
+	s.IngestPipelines = map[string]types.IngestPipeline{"logs": {Description: &description}}
+
+	s.ComponentTemplates = map[string]types.ComponentTemplateNode{
+		"base": {Template: types.ComponentTemplateSummary{Settings: map[string]types.IndexSettings{"index": {NumberOfShards: "2"}}}},
+	}
+
+	priority := int64(200)
+	s.IndexTemplates = map[string]types.IndexTemplate{
+		"logs": {IndexPatterns: []string{"logs-*"}, ComposedOf: []string{"base"}, Priority: &priority},
+	}
+
+	writeIndex := true
+	s.Aliases = map[string]Alias{
+		"logs": {Indices: []string{"logs-2"}, Definition: types.AliasDefinition{IsWriteIndex: &writeIndex}},
+	}
+	return &s
+}
+
+func newTestCluster() *mockSchemaCluster {
+	return &mockSchemaCluster{resources: map[string]string{
+		"/_ilm/policy/logs": `{"logs":{"version":1,"modified_date":"2024-01-01T00:00:00.000Z","policy":{"phases":{` +
+			`"hot":{"min_age":"0ms","actions":{"rollover":{"max_age":"7d"}}},` +
+			`"delete":{"min_age":"30d","actions":{"delete":{"delete_searchable_snapshot":true}}}}}}}`,
+		"/_component_template/base": `{"component_templates":[{"name":"base","component_template":{"template":{"settings":{"index":{"number_of_shards":"1","number_of_replicas":"1"}}}}}]}`,
+		"/_index_template/logs":     `{"index_templates":[{"name":"logs","index_template":{"index_patterns":["logs-*"],"composed_of":["base"],"priority":100}}]}`,
+		"/_alias/logs":              `{"logs-1":{"aliases":{"logs":{}}}}`,
+	}}
+}
+
+func TestManager(t *testing.T) {
+	t.Run("Plan", func(t *testing.T) {
+		cluster := newTestCluster()
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: estest.Transport(cluster.handle)})
+
+		plan, err := NewManager(es, newTestSchema()).Plan(context.Background())
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		expected := `+ create ingest_pipeline [logs]
+~ update component_template [base]
+    template.settings.index.number_of_shards: "1" -> "2"
+~ update index_template [logs]
+    priority: 100 -> 200
+~ update alias [logs]
+    definitions.logs-2.is_write_index: <none> -> true
+    indices: ["logs-1"] -> ["logs-2"]
+`
+		if plan.String() != expected {
+			t.Errorf("Unexpected plan:\n%s\nexpected:\n%s", plan, expected)
+		}
+		if len(cluster.requests) != 0 {
+			t.Errorf("Unexpected requests: %v", cluster.requests)
+		}
+	})
+
+	t.Run("No changes", func(t *testing.T) {
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: estest.Transport(newTestCluster().handle)})
+
+		s := newTestSchema()
+		s.IngestPipelines, s.Aliases = nil, nil
+		var base types.ComponentTemplateNode
+		json.Unmarshal([]byte(`{"template":{"settings":{"index":{"number_of_shards":1}}}}`), &base)
+		s.ComponentTemplates["base"] = base
+		priority := int64(100)
+		s.IndexTemplates["logs"] = types.IndexTemplate{IndexPatterns: []string{"logs-*"}, Priority: &priority}
+
+		plan, err := NewManager(es, s).Plan(context.Background())
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if plan.HasChanges() {
+			t.Errorf("Unexpected changes:\n%s", plan)
+		}
+	})
+
+	t.Run("Apply", func(t *testing.T) {
+		cluster := newTestCluster()
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: estest.Transport(cluster.handle)})
+		m := NewManager(es, newTestSchema())
+
+		plan, err := m.Plan(context.Background())
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if err := m.Apply(context.Background(), plan); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		expected := []string{
+			`PUT /_ingest/pipeline/logs {"description":"Parse logs"}`,
+			`PUT /_component_template/base {"template":{"settings":{"index":{"number_of_shards":"2"}}}}`,
+			`PUT /_index_template/logs {"composed_of":["base"],"index_patterns":["logs-*"],"priority":200}`,
+			`POST /_aliases {"actions":[{"remove":{"alias":"logs","index":"logs-1"}},{"add":{"alias":"logs","index":"logs-2","is_write_index":true}}]}`,
+		}
+		if len(cluster.requests) != len(expected) {
+			t.Fatalf("Unexpected requests:\n%s", strings.Join(cluster.requests, "\n"))
+		}
+		for i := range expected {
+			if cluster.requests[i] != expected[i] {
+				t.Errorf("Unexpected request %d:\n%s\nexpected:\n%s", i, cluster.requests[i], expected[i])
+			}
+		}
+	})
+
+	t.Run("Apply error", func(t *testing.T) {
+		cluster := newTestCluster()
+		cluster.fail = "/_component_template/base"
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: estest.Transport(cluster.handle)})
+		m := NewManager(es, newTestSchema())
+
+		plan, _ := m.Plan(context.Background())
+		err := m.Apply(context.Background(), plan)
+
+		var esErr *types.ElasticsearchError
+		if !errors.As(err, &esErr) || !strings.HasPrefix(err.Error(), "apply: component_template [base]") {
+			t.Fatalf("Expected ElasticsearchError, got: %v", err)
+		}
+		if len(cluster.requests) != 2 {
+			t.Errorf("Expected Apply to stop at the failed change, got: %v", cluster.requests)
+		}
+	})
+}
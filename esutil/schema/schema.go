@@ -0,0 +1,165 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package schema manages index templates, component templates, ILM policies,
+// ingest pipelines and aliases declaratively.
+//
+// The desired resources are described by a Schema, as Go values or JSON files.
+// The Plan method of the Manager compares them with the cluster and returns
+// the changes, which Apply pushes to the cluster in dependency order:
+//
+//	m := schema.NewManager(es, s)
+//	plan, err := m.Plan(ctx)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	fmt.Print(plan)
+//	err = m.Apply(ctx, plan)
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// The kinds of resources, in dependency order.
+const (
+	KindILMPolicy         = "ilm_policy"
+	KindIngestPipeline    = "ingest_pipeline"
+	KindComponentTemplate = "component_template"
+	KindIndexTemplate     = "index_template"
+	KindAlias             = "alias"
+)
+
+// Schema represents the desired resources, by name.
+//
+// Resources which exist in the cluster, but not in the schema, are left untouched.
+type Schema struct {
+	ILMPolicies        map[string]types.IlmPolicy
+	IngestPipelines    map[string]types.IngestPipeline
+	ComponentTemplates map[string]types.ComponentTemplateNode
+	IndexTemplates     map[string]types.IndexTemplate
+	Aliases            map[string]Alias
+}
+
+// Alias represents an alias and the indices it points to.
+//
+// The alias is removed from the indices which aren't in the list.
+type Alias struct {
+	Indices    []string              `json:"indices"`
+	Definition types.AliasDefinition `json:"definition"`
+}
+
+// directories maps the kinds to their directory in the file system.
+var directories = map[string]string{
+	KindILMPolicy:         "ilm_policies",
+	KindIngestPipeline:    "ingest_pipelines",
+	KindComponentTemplate: "component_templates",
+	KindIndexTemplate:     "index_templates",
+	KindAlias:             "aliases",
+}
+
+// Load reads the schema from JSON files in the file system, eg. an embed.FS or os.DirFS.
+//
+// The files are organized by kind, and named after the resource:
+//
+//	ilm_policies/<name>.json        the policy, eg. {"phases": {...}}
+//	ingest_pipelines/<name>.json    the pipeline, eg. {"processors": [...]}
+//	component_templates/<name>.json the component template, eg. {"template": {...}}
+//	index_templates/<name>.json     the index template, eg. {"index_patterns": [...]}
+//	aliases/<name>.json             the alias, eg. {"indices": [...], "definition": {...}}
+//
+// Missing directories are ignored.
+func Load(fsys fs.FS) (*Schema, error) {
+	s := Schema{
+		ILMPolicies:        make(map[string]types.IlmPolicy),
+		IngestPipelines:    make(map[string]types.IngestPipeline),
+		ComponentTemplates: make(map[string]types.ComponentTemplateNode),
+		IndexTemplates:     make(map[string]types.IndexTemplate),
+		Aliases:            make(map[string]Alias),
+	}
+
+	for kind, dir := range directories {
+		entries, err := fs.ReadDir(fsys, dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("schema: %s", err)
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || path.Ext(entry.Name()) != ".json" {
+				continue
+			}
+			name := strings.TrimSuffix(entry.Name(), ".json")
+
+			b, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("schema: %s", err)
+			}
+			if err := s.decode(kind, name, b); err != nil {
+				return nil, fmt.Errorf("schema: %s: %s", path.Join(dir, entry.Name()), err)
+			}
+		}
+	}
+
+	return &s, nil
+}
+
+// decode adds the resource from its JSON representation.
+func (s *Schema) decode(kind, name string, b []byte) error {
+	switch kind {
+	case KindILMPolicy:
+		var v types.IlmPolicy
+		if err := json.Unmarshal(b, &v); err != nil {
+			return err
+		}
+		s.ILMPolicies[name] = v
+	case KindIngestPipeline:
+		var v types.IngestPipeline
+		if err := json.Unmarshal(b, &v); err != nil {
+			return err
+		}
+		s.IngestPipelines[name] = v
+	case KindComponentTemplate:
+		var v types.ComponentTemplateNode
+		if err := json.Unmarshal(b, &v); err != nil {
+			return err
+		}
+		s.ComponentTemplates[name] = v
+	case KindIndexTemplate:
+		var v types.IndexTemplate
+		if err := json.Unmarshal(b, &v); err != nil {
+			return err
+		}
+		s.IndexTemplates[name] = v
+	case KindAlias:
+		var v Alias
+		if err := json.Unmarshal(b, &v); err != nil {
+			return err
+		}
+		s.Aliases[name] = v
+	}
+	return nil
+}
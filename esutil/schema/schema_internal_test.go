@@ -0,0 +1,65 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package schema
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"ilm_policies/logs.json":        {Data: []byte(`{"phases":{"delete":{"min_age":"30d","actions":{"delete":{}}}}}`)},
+		"ingest_pipelines/logs.json":    {Data: []byte(`{"description":"Parse logs","processors":[{"set":{"field":"a","value":"b"}}]}`)},
+		"component_templates/base.json": {Data: []byte(`{"template":{"settings":{"index":{"number_of_shards":"1"}}}}`)},
+		"index_templates/logs.json":     {Data: []byte(`{"index_patterns":["logs-*"],"composed_of":["base"]}`)},
+		"aliases/logs.json":             {Data: []byte(`{"indices":["logs-1"],"definition":{"is_write_index":true}}`)},
+		"aliases/README.md":             {Data: []byte(`Ignored`)},
+	}
+
+	s, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if s.ILMPolicies["logs"].Phases.Delete == nil {
+		t.Errorf("Unexpected ILM policy: %+v", s.ILMPolicies["logs"])
+	}
+	if p := s.IngestPipelines["logs"]; p.Description == nil || len(p.Processors) != 1 || p.Processors[0].Set == nil {
+		t.Errorf("Unexpected pipeline: %+v", p)
+	}
+	if s.ComponentTemplates["base"].Template.Settings["index"].NumberOfShards != "1" {
+		t.Errorf("Unexpected component template: %+v", s.ComponentTemplates["base"])
+	}
+	if s.IndexTemplates["logs"].ComposedOf[0] != "base" {
+		t.Errorf("Unexpected index template: %+v", s.IndexTemplates["logs"])
+	}
+	if a := s.Aliases["logs"]; len(s.Aliases) != 1 || a.Indices[0] != "logs-1" || !*a.Definition.IsWriteIndex {
+		t.Errorf("Unexpected aliases: %+v", s.Aliases)
+	}
+
+	t.Run("Invalid file", func(t *testing.T) {
+		_, err := Load(fstest.MapFS{"index_templates/bad.json": {Data: []byte(`{`)}})
+		if err == nil {
+			t.Errorf("Expected error for invalid file")
+		}
+	})
+}
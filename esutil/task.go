@@ -0,0 +1,283 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastic/elastic-transport-go/v8/elastictransport"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/deletebyqueryrethrottle"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/reindex"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/reindexrethrottle"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/updatebyqueryrethrottle"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/tasks/cancel"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/tasks/get"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// Actions of the tasks which report a types.ReindexStatus.
+const (
+	TaskActionReindex       = "indices:data/write/reindex"
+	TaskActionUpdateByQuery = "indices:data/write/update/byquery"
+	TaskActionDeleteByQuery = "indices:data/write/delete/byquery"
+)
+
+// TaskWatcherConfig represents configuration of the task watcher.
+type TaskWatcherConfig struct {
+	MinInterval time.Duration // The initial polling interval. Defaults to 500ms.
+	MaxInterval time.Duration // The maximum polling interval, reached with exponential backoff. Defaults to 30sec.
+
+	// OnProgress is called with the task status after every poll of a running task.
+	OnProgress func(context.Context, TaskProgress)
+
+	// KeepRunning leaves the task running when the context is done.
+	// By default, the task is cancelled with the Cancel Task API.
+	KeepRunning bool
+}
+
+// TaskProgress represents the status of a running task.
+type TaskProgress struct {
+	Task types.TaskInfo
+
+	// Status is the decoded status of reindex, update by query
+	// and delete by query tasks; nil for other tasks.
+	Status *types.ReindexStatus
+}
+
+// TaskResult represents the result of a completed task.
+type TaskResult struct {
+	Task     types.TaskInfo
+	Response json.RawMessage // The task response, as returned by Elasticsearch
+
+	// ByQuery is the decoded response of reindex, update by query
+	// and delete by query tasks; nil for other tasks.
+	ByQuery *reindex.Response
+}
+
+// TaskError represents the failure of a completed task.
+type TaskError struct {
+	TaskID string
+	Action string
+
+	Cause    *types.ErrorCause                // The error of the task
+	Failures []types.BulkIndexByScrollFailure // The document failures of by-query tasks
+	TimedOut bool                             // Whether a by-query task has timed out
+}
+
+// Error implements the error interface.
+func (e *TaskError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "task [%s] failed", e.TaskID)
+	if e.Cause != nil {
+		fmt.Fprintf(&b, ": [%s]", e.Cause.Type)
+		if e.Cause.Reason != nil {
+			fmt.Fprintf(&b, " %s", *e.Cause.Reason)
+		}
+	}
+	if n := len(e.Failures); n > 0 {
+		fmt.Fprintf(&b, ": %d failures, first: [%s] %s", n, e.Failures[0].Cause.Type, e.Failures[0].Id)
+	}
+	if e.TimedOut {
+		b.WriteString(": timed out")
+	}
+	return b.String()
+}
+
+// TaskWatcher follows a task started with wait_for_completion=false.
+type TaskWatcher struct {
+	client elastictransport.Interface
+	taskID string
+	cfg    TaskWatcherConfig
+
+	mu     sync.Mutex
+	action string
+}
+
+// NewTaskWatcher creates a watcher for the task.
+//
+// The client is a *elasticsearch.TypedClient or a *elasticsearch.Client.
+func NewTaskWatcher(client elastictransport.Interface, taskID string, cfg TaskWatcherConfig) *TaskWatcher {
+	if cfg.MinInterval == 0 {
+		cfg.MinInterval = 500 * time.Millisecond
+	}
+	if cfg.MaxInterval == 0 {
+		cfg.MaxInterval = 30 * time.Second
+	}
+	if cfg.MaxInterval < cfg.MinInterval {
+		cfg.MaxInterval = cfg.MinInterval
+	}
+
+	return &TaskWatcher{client: client, taskID: taskID, cfg: cfg}
+}
+
+// WaitForTask waits until the task completes, with the default configuration.
+func WaitForTask(ctx context.Context, client elastictransport.Interface, taskID string) (*TaskResult, error) {
+	return NewTaskWatcher(client, taskID, TaskWatcherConfig{}).Wait(ctx)
+}
+
+// Wait polls the task with exponential backoff until it completes.
+//
+// It returns a *TaskError when the task has failed. When the context is done,
+// the task is cancelled, unless KeepRunning is set, and the context error is returned.
+func (tw *TaskWatcher) Wait(ctx context.Context) (*TaskResult, error) {
+	interval := tw.cfg.MinInterval
+
+	for {
+		res, err := get.NewGetFunc(tw.client)(tw.taskID).Do(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, tw.canceled(ctx)
+			}
+			return nil, fmt.Errorf("task [%s]: %w", tw.taskID, err)
+		}
+
+		tw.mu.Lock()
+		tw.action = res.Task.Action
+		tw.mu.Unlock()
+
+		if res.Completed {
+			return tw.result(res)
+		}
+
+		if tw.cfg.OnProgress != nil {
+			p := TaskProgress{Task: res.Task}
+			if isByQueryAction(res.Task.Action) && len(res.Task.Status) > 0 {
+				var status types.ReindexStatus
+				if err := json.Unmarshal(res.Task.Status, &status); err == nil {
+					p.Status = &status
+				}
+			}
+			tw.cfg.OnProgress(ctx, p)
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, tw.canceled(ctx)
+		case <-timer.C:
+		}
+
+		if interval *= 2; interval > tw.cfg.MaxInterval {
+			interval = tw.cfg.MaxInterval
+		}
+	}
+}
+
+// Rethrottle changes the requests per second of a running reindex,
+// update by query or delete by query task. Zero or less disables throttling.
+func (tw *TaskWatcher) Rethrottle(ctx context.Context, requestsPerSecond float32) error {
+	tw.mu.Lock()
+	action := tw.action
+	tw.mu.Unlock()
+
+	if action == "" {
+		res, err := get.NewGetFunc(tw.client)(tw.taskID).Do(ctx)
+		if err != nil {
+			return fmt.Errorf("task [%s]: rethrottle: %w", tw.taskID, err)
+		}
+		action = res.Task.Action
+	}
+
+	rps := "-1"
+	if requestsPerSecond > 0 {
+		rps = strconv.FormatFloat(float64(requestsPerSecond), 'f', -1, 32)
+	}
+
+	var err error
+	switch action {
+	case TaskActionReindex:
+		_, err = reindexrethrottle.NewReindexRethrottleFunc(tw.client)(tw.taskID).RequestsPerSecond(rps).Do(ctx)
+	case TaskActionUpdateByQuery:
+		_, err = updatebyqueryrethrottle.NewUpdateByQueryRethrottleFunc(tw.client)(tw.taskID).RequestsPerSecond(rps).Do(ctx)
+	case TaskActionDeleteByQuery:
+		_, err = deletebyqueryrethrottle.NewDeleteByQueryRethrottleFunc(tw.client)(tw.taskID).RequestsPerSecond(rps).Do(ctx)
+	default:
+		return fmt.Errorf("task [%s]: rethrottle: unsupported action [%s]", tw.taskID, action)
+	}
+	if err != nil {
+		return fmt.Errorf("task [%s]: rethrottle: %w", tw.taskID, err)
+	}
+	return nil
+}
+
+// Cancel cancels the task with the Cancel Task API.
+func (tw *TaskWatcher) Cancel(ctx context.Context) error {
+	if _, err := cancel.NewCancelFunc(tw.client)().TaskId(tw.taskID).Do(ctx); err != nil {
+		return fmt.Errorf("task [%s]: cancel: %w", tw.taskID, err)
+	}
+	return nil
+}
+
+// canceled cancels the task, unless KeepRunning is set, and returns the context error.
+func (tw *TaskWatcher) canceled(ctx context.Context) error {
+	if tw.cfg.KeepRunning {
+		return ctx.Err()
+	}
+	// Use a separate context, since the provided one is done.
+	if err := tw.Cancel(context.WithoutCancel(ctx)); err != nil {
+		return errors.Join(ctx.Err(), err)
+	}
+	return ctx.Err()
+}
+
+// result converts the response of a completed task.
+func (tw *TaskWatcher) result(res *get.Response) (*TaskResult, error) {
+	result := TaskResult{Task: res.Task, Response: res.Response}
+
+	if res.Error != nil {
+		return &result, &TaskError{TaskID: tw.taskID, Action: res.Task.Action, Cause: res.Error}
+	}
+
+	if isByQueryAction(res.Task.Action) && len(res.Response) > 0 {
+		var byQuery reindex.Response
+		if err := json.Unmarshal(res.Response, &byQuery); err != nil {
+			return &result, fmt.Errorf("task [%s]: error parsing response: %s", tw.taskID, err)
+		}
+		result.ByQuery = &byQuery
+
+		timedOut := byQuery.TimedOut != nil && *byQuery.TimedOut
+		if len(byQuery.Failures) > 0 || timedOut {
+			return &result, &TaskError{
+				TaskID:   tw.taskID,
+				Action:   res.Task.Action,
+				Failures: byQuery.Failures,
+				TimedOut: timedOut,
+			}
+		}
+	}
+
+	return &result, nil
+}
+
+// isByQueryAction returns true for the actions which report a types.ReindexStatus.
+func isByQueryAction(action string) bool {
+	switch action {
+	case TaskActionReindex, TaskActionUpdateByQuery, TaskActionDeleteByQuery:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,257 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// mockTaskCluster serves the task APIs for a single task, completing after numPolls polls.
+type mockTaskCluster struct {
+	mu sync.Mutex
+
+	action   string
+	numPolls int
+	response string
+	error    string
+
+	polls      int
+	canceled   []string
+	rethrottle []string
+}
+
+func (m *mockTaskCluster) handle(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/_cancel"):
+		m.canceled = append(m.canceled, req.URL.Path)
+		return mockResponse(200, `{"nodes":{}}`)
+
+	case strings.HasSuffix(req.URL.Path, "/_rethrottle"):
+		m.rethrottle = append(m.rethrottle, req.URL.Path+"?"+req.URL.Query().Get("requests_per_second"))
+		return mockResponse(200, `{"nodes":{}}`)
+
+	case req.URL.Path == "/_tasks/node:1":
+		m.polls++
+		task := fmt.Sprintf(`{"node":"node","id":1,"type":"transport","action":%q,"cancellable":true,`+
+			`"start_time_in_millis":0,"running_time_in_nanos":0,`+
+			`"status":{"total":10,"created":%d,"updated":0,"deleted":0,"batches":1,"version_conflicts":0,"noops":0,`+
+			`"retries":{"bulk":0,"search":0},"throttled_millis":0,"requests_per_second":-1,"throttled_until_millis":0}}`,
+			m.action, m.polls)
+		if m.numPolls > 0 && m.polls >= m.numPolls {
+			var result string
+			if m.error != "" {
+				result = `,"error":` + m.error
+			} else if m.response != "" {
+				result = `,"response":` + m.response
+			}
+			return mockResponse(200, `{"completed":true,"task":`+task+result+`}`)
+		}
+		return mockResponse(200, `{"completed":false,"task":`+task+`}`)
+	}
+
+	return mockResponse(404, `{"error":{"type":"resource_not_found_exception","reason":"not found"},"status":404}`)
+}
+
+func TestWaitForTask(t *testing.T) {
+	t.Run("Completed", func(t *testing.T) {
+		cluster := &mockTaskCluster{
+			action:   TaskActionReindex,
+			numPolls: 3,
+			response: `{"took":10,"timed_out":false,"total":10,"created":10,"failures":[]}`,
+		}
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+
+		var progress []int64
+		tw := NewTaskWatcher(es, "node:1", TaskWatcherConfig{
+			MinInterval: time.Millisecond,
+			OnProgress: func(ctx context.Context, p TaskProgress) {
+				if p.Status != nil {
+					progress = append(progress, p.Status.Created)
+				}
+			},
+		})
+
+		res, err := tw.Wait(context.Background())
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if cluster.polls != 3 {
+			t.Errorf("Unexpected number of polls: %d", cluster.polls)
+		}
+		if fmt.Sprint(progress) != "[1 2]" {
+			t.Errorf("Unexpected progress: %v", progress)
+		}
+		if res.ByQuery == nil || res.ByQuery.Created == nil || *res.ByQuery.Created != 10 {
+			t.Errorf("Unexpected response: %+v", res.ByQuery)
+		}
+	})
+
+	t.Run("Other action", func(t *testing.T) {
+		cluster := &mockTaskCluster{action: "indices:admin/forcemerge", numPolls: 1, response: `{"_shards":{}}`}
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+
+		res, err := WaitForTask(context.Background(), es, "node:1")
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if res.ByQuery != nil {
+			t.Errorf("Unexpected by-query response: %+v", res.ByQuery)
+		}
+		if string(res.Response) != `{"_shards":{}}` {
+			t.Errorf("Unexpected response: %s", res.Response)
+		}
+	})
+
+	t.Run("Task error", func(t *testing.T) {
+		cluster := &mockTaskCluster{
+			action:   TaskActionReindex,
+			numPolls: 1,
+			error:    `{"type":"index_not_found_exception","reason":"no such index [foo]"}`,
+		}
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+
+		_, err := WaitForTask(context.Background(), es, "node:1")
+
+		var taskErr *TaskError
+		if !errors.As(err, &taskErr) {
+			t.Fatalf("Expected TaskError, got: %v", err)
+		}
+		if taskErr.Cause == nil || taskErr.Cause.Type != "index_not_found_exception" {
+			t.Errorf("Unexpected cause: %+v", taskErr.Cause)
+		}
+		if !strings.Contains(err.Error(), "no such index [foo]") {
+			t.Errorf("Unexpected error message: %s", err)
+		}
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		cluster := &mockTaskCluster{
+			action:   TaskActionUpdateByQuery,
+			numPolls: 1,
+			response: `{"timed_out":false,"failures":[{"index":"test","id":"1","status":409,` +
+				`"cause":{"type":"version_conflict_engine_exception","reason":"conflict"}}]}`,
+		}
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+
+		res, err := WaitForTask(context.Background(), es, "node:1")
+
+		var taskErr *TaskError
+		if !errors.As(err, &taskErr) {
+			t.Fatalf("Expected TaskError, got: %v", err)
+		}
+		if len(taskErr.Failures) != 1 || taskErr.Failures[0].Id != "1" {
+			t.Errorf("Unexpected failures: %+v", taskErr.Failures)
+		}
+		if res == nil || res.ByQuery == nil {
+			t.Errorf("Expected the result to be returned with the error")
+		}
+	})
+
+	t.Run("Context canceled", func(t *testing.T) {
+		cluster := &mockTaskCluster{action: TaskActionDeleteByQuery}
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		tw := NewTaskWatcher(es, "node:1", TaskWatcherConfig{
+			MinInterval: time.Millisecond,
+			OnProgress: func(ctx context.Context, p TaskProgress) {
+				cancel()
+			},
+		})
+
+		_, err := tw.Wait(ctx)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected context.Canceled, got: %v", err)
+		}
+		if len(cluster.canceled) != 1 || cluster.canceled[0] != "/_tasks/node:1/_cancel" {
+			t.Errorf("Expected the task to be canceled, got: %v", cluster.canceled)
+		}
+	})
+
+	t.Run("Context canceled KeepRunning", func(t *testing.T) {
+		cluster := &mockTaskCluster{action: TaskActionDeleteByQuery}
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		tw := NewTaskWatcher(es, "node:1", TaskWatcherConfig{
+			MinInterval: time.Millisecond,
+			KeepRunning: true,
+			OnProgress: func(ctx context.Context, p TaskProgress) {
+				cancel()
+			},
+		})
+
+		if _, err := tw.Wait(ctx); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected context.Canceled, got: %v", err)
+		}
+		if len(cluster.canceled) != 0 {
+			t.Errorf("Unexpected cancel requests: %v", cluster.canceled)
+		}
+	})
+}
+
+func TestTaskWatcherRethrottle(t *testing.T) {
+	for action, path := range map[string]string{
+		TaskActionReindex:       "/_reindex/node:1/_rethrottle",
+		TaskActionUpdateByQuery: "/_update_by_query/node:1/_rethrottle",
+		TaskActionDeleteByQuery: "/_delete_by_query/node:1/_rethrottle",
+	} {
+		t.Run(action, func(t *testing.T) {
+			cluster := &mockTaskCluster{action: action}
+			es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+			tw := NewTaskWatcher(es, "node:1", TaskWatcherConfig{})
+
+			if err := tw.Rethrottle(context.Background(), 500); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if err := tw.Rethrottle(context.Background(), 0); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			if fmt.Sprint(cluster.rethrottle) != fmt.Sprintf("[%s?500 %s?-1]", path, path) {
+				t.Errorf("Unexpected rethrottle requests: %v", cluster.rethrottle)
+			}
+		})
+	}
+
+	t.Run("Unsupported action", func(t *testing.T) {
+		cluster := &mockTaskCluster{action: "indices:admin/forcemerge"}
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: cluster.handle}})
+
+		err := NewTaskWatcher(es, "node:1", TaskWatcherConfig{}).Rethrottle(context.Background(), 10)
+		if err == nil || !strings.Contains(err.Error(), "unsupported action") {
+			t.Errorf("Expected unsupported action error, got: %v", err)
+		}
+	})
+}
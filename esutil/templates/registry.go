@@ -0,0 +1,244 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package templates manages stored search templates, and runs them with typed parameters.
+//
+// The templates are registered from files, eg. embedded in the application,
+// checked and stored at startup, and run with a Go type for their parameters:
+//
+//	//go:embed templates/*.mustache
+//	var files embed.FS
+//
+//	type ByTitle struct {
+//		Title string `json:"title"`
+//		Size  int    `json:"size"`
+//	}
+//
+//	reg := templates.NewRegistry(es)
+//	if err := reg.Load(files, "templates"); err != nil { ... }
+//	byTitle, err := templates.Define(reg, "by_title", ByTitle{Title: "example", Size: 10})
+//	if err := reg.Check(ctx); err != nil { ... }
+//	if _, err := reg.Sync(ctx); err != nil { ... }
+//
+//	res, err := byTitle.Search(ctx, ByTitle{Title: "go", Size: 20}, "articles")
+package templates
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/elastic/elastic-transport-go/v8/elastictransport"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/getscript"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/putscript"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/rendersearchtemplate"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/scriptlanguage"
+)
+
+// Extension is the extension of the template files.
+const Extension = ".mustache"
+
+// The kinds of drift between the local and the stored templates.
+const (
+	DriftMissing = "missing" // The template is not stored.
+	DriftChanged = "changed" // The stored template has another source.
+)
+
+// Drift represents a difference between a local and a stored template.
+type Drift struct {
+	ID     string
+	Kind   string
+	Local  string
+	Stored string // The source of the stored template, empty when missing.
+}
+
+// String returns a readable description of the drift.
+func (d Drift) String() string {
+	return fmt.Sprintf("%s: %s", d.ID, d.Kind)
+}
+
+// Registry represents the local search templates of an application.
+//
+// It is safe for concurrent use.
+type Registry struct {
+	client elastictransport.Interface
+
+	mu       sync.Mutex
+	sources  map[string]string
+	examples map[string]func() (map[string]json.RawMessage, error)
+}
+
+// NewRegistry creates an empty registry.
+//
+// The client is a *elasticsearch.TypedClient or a *elasticsearch.Client.
+func NewRegistry(client elastictransport.Interface) *Registry {
+	return &Registry{
+		client:   client,
+		sources:  make(map[string]string),
+		examples: make(map[string]func() (map[string]json.RawMessage, error)),
+	}
+}
+
+// Add adds a template with the mustache source.
+func (r *Registry) Add(id, source string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sources[id] = source
+}
+
+// Load adds the templates of the files with the mustache extension in the directory,
+// with the name of the file without extension as ID, eg. "by_title" for "by_title.mustache".
+func (r *Registry) Load(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("templates: %s", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != Extension {
+			continue
+		}
+		b, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return fmt.Errorf("templates: %s", err)
+		}
+		r.Add(strings.TrimSuffix(e.Name(), Extension), string(b))
+	}
+	return nil
+}
+
+// Source returns the local source of the template.
+func (r *Registry) Source(id string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sources[id]
+	return s, ok
+}
+
+// IDs returns the IDs of the templates, sorted.
+func (r *Registry) IDs() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]string, 0, len(r.sources))
+	for id := range r.sources {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Check renders the local source of every template with the Render Search Template API,
+// and returns the errors of the templates which fail to render, eg. with invalid JSON.
+// The defined templates are rendered with their example parameters, the others
+// with empty parameters.
+func (r *Registry) Check(ctx context.Context) error {
+	r.mu.Lock()
+	examples := make(map[string]func() (map[string]json.RawMessage, error), len(r.examples))
+	for id, fn := range r.examples {
+		examples[id] = fn
+	}
+	r.mu.Unlock()
+
+	var errs []error
+	for _, id := range r.IDs() {
+		source, _ := r.Source(id)
+		var params map[string]json.RawMessage
+		if example, ok := examples[id]; ok {
+			p, err := example()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("templates: check [%s]: %s", id, err))
+				continue
+			}
+			params = p
+		}
+		_, err := rendersearchtemplate.NewRenderSearchTemplateFunc(r.client)().
+			Request(&rendersearchtemplate.Request{Source: &source, Params: params}).
+			Do(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("templates: check [%s]: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Drift returns the local templates which are missing or different in the cluster.
+func (r *Registry) Drift(ctx context.Context) ([]Drift, error) {
+	var drift []Drift
+	for _, id := range r.IDs() {
+		local, _ := r.Source(id)
+
+		res, err := getscript.NewGetScriptFunc(r.client)(id).Do(ctx)
+		if err != nil {
+			var esErr *types.ElasticsearchError
+			if errors.As(err, &esErr) && esErr.Status == 404 {
+				drift = append(drift, Drift{ID: id, Kind: DriftMissing, Local: local})
+				continue
+			}
+			return nil, fmt.Errorf("templates: get [%s]: %w", id, err)
+		}
+		if !res.Found || res.Script == nil {
+			drift = append(drift, Drift{ID: id, Kind: DriftMissing, Local: local})
+			continue
+		}
+		if strings.TrimSpace(res.Script.Source) != strings.TrimSpace(local) {
+			drift = append(drift, Drift{ID: id, Kind: DriftChanged, Local: local, Stored: res.Script.Source})
+		}
+	}
+	return drift, nil
+}
+
+// Sync stores the templates which are missing or different in the cluster,
+// and returns the drift which was fixed.
+func (r *Registry) Sync(ctx context.Context) ([]Drift, error) {
+	drift, err := r.Drift(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range drift {
+		_, err := putscript.NewPutScriptFunc(r.client)(d.ID).
+			Request(&putscript.Request{Script: types.StoredScript{Lang: scriptlanguage.Mustache, Source: d.Local}}).
+			Do(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("templates: put [%s]: %w", d.ID, err)
+		}
+	}
+	return drift, nil
+}
+
+// define records the example parameters of a template.
+func (r *Registry) define(id string, example func() (map[string]json.RawMessage, error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.examples[id] = example
+}
+
+// sortedKeys returns the keys of the map, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,154 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package templates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/msearchtemplate"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/rendersearchtemplate"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/searchtemplate"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// Template represents a stored search template with the parameters of type P,
+// encoded to a JSON object, eg. a struct with JSON tags.
+type Template[P any] struct {
+	id  string
+	reg *Registry
+}
+
+// Define returns the template of the registry with the type of its parameters.
+// The example parameters are rendered by Registry.Check.
+func Define[P any](r *Registry, id string, example P) (*Template[P], error) {
+	if _, ok := r.Source(id); !ok {
+		return nil, fmt.Errorf("templates: template [%s] not found", id)
+	}
+	r.define(id, func() (map[string]json.RawMessage, error) { return encodeParams(example) })
+	return &Template[P]{id: id, reg: r}, nil
+}
+
+// ID returns the ID of the stored template.
+func (t *Template[P]) ID() string {
+	return t.id
+}
+
+// Request returns a search template request for the parameters, eg. to set request options:
+//
+//	req, err := byTitle.Request(params)
+//	res, err := req.Index("articles").Routing("user1").Do(ctx)
+func (t *Template[P]) Request(params P) (*searchtemplate.SearchTemplate, error) {
+	p, err := encodeParams(params)
+	if err != nil {
+		return nil, fmt.Errorf("templates: [%s]: %s", t.id, err)
+	}
+	id := t.id
+	return searchtemplate.NewSearchTemplateFunc(t.reg.client)().
+		Request(&searchtemplate.Request{Id: &id, Params: p}), nil
+}
+
+// Search runs the template with the parameters on the indices.
+func (t *Template[P]) Search(ctx context.Context, params P, index ...string) (*searchtemplate.Response, error) {
+	req, err := t.Request(params)
+	if err != nil {
+		return nil, err
+	}
+	if len(index) > 0 {
+		req.Index(strings.Join(index, ","))
+	}
+	res, err := req.Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("templates: search [%s]: %w", t.id, err)
+	}
+	return res, nil
+}
+
+// MultiSearch runs the template with every parameters on the indices, with a single
+// Multi Search Template API call. It returns a response per parameters, in order;
+// a failed search is returned as a *types.ElasticsearchError in the errors, at its index.
+func (t *Template[P]) MultiSearch(ctx context.Context, params []P, index ...string) ([]*types.MultiSearchItem, []error, error) {
+	req := make(msearchtemplate.Request, 0, len(params)*2)
+	for _, p := range params {
+		encoded, err := encodeParams(p)
+		if err != nil {
+			return nil, nil, fmt.Errorf("templates: [%s]: %s", t.id, err)
+		}
+		id := t.id
+		req = append(req, types.MultisearchHeader{}, types.TemplateConfig{Id: &id, Params: encoded})
+	}
+
+	r := msearchtemplate.NewMsearchTemplateFunc(t.reg.client)().Request(&req)
+	if len(index) > 0 {
+		r.Index(strings.Join(index, ","))
+	}
+	res, err := r.Do(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("templates: multi search [%s]: %w", t.id, err)
+	}
+	if len(res.Responses) != len(params) {
+		return nil, nil, fmt.Errorf("templates: multi search [%s]: unexpected number of responses: %d, expected %d", t.id, len(res.Responses), len(params))
+	}
+
+	var (
+		items = make([]*types.MultiSearchItem, len(params))
+		errs  = make([]error, len(params))
+	)
+	for i, item := range res.Responses {
+		switch item := item.(type) {
+		case *types.MultiSearchItem:
+			items[i] = item
+		case *types.ErrorResponseBase:
+			errs[i] = &types.ElasticsearchError{ErrorCause: item.Error, Status: item.Status}
+		default:
+			errs[i] = fmt.Errorf("templates: multi search [%s]: unexpected response %T", t.id, item)
+		}
+	}
+	return items, errs, nil
+}
+
+// Render returns the search request rendered by the stored template with the parameters.
+func (t *Template[P]) Render(ctx context.Context, params P) (map[string]json.RawMessage, error) {
+	p, err := encodeParams(params)
+	if err != nil {
+		return nil, fmt.Errorf("templates: [%s]: %s", t.id, err)
+	}
+	res, err := rendersearchtemplate.NewRenderSearchTemplateFunc(t.reg.client)().
+		Id(t.id).
+		Request(&rendersearchtemplate.Request{Params: p}).
+		Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("templates: render [%s]: %w", t.id, err)
+	}
+	return res.TemplateOutput, nil
+}
+
+// encodeParams returns the parameters as the params of a request.
+func encodeParams(params interface{}) (map[string]json.RawMessage, error) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding parameters: %s", err)
+	}
+	var p map[string]json.RawMessage
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("parameters are not an object: %s", err)
+	}
+	return p, nil
+}
@@ -0,0 +1,267 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package templates
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esutil/internal/estest"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// mockTemplateCluster serves the stored scripts, render and search template requests.
+type mockTemplateCluster struct {
+	mu sync.Mutex
+
+	scripts  map[string]string
+	puts     []string
+	rendered []string
+	searches []string
+}
+
+func (m *mockTemplateCluster) handle(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var body []byte
+	if req.Body != nil {
+		body, _ = ioutil.ReadAll(req.Body)
+	}
+
+	switch {
+	case strings.HasPrefix(req.URL.Path, "/_scripts/"):
+		id := strings.TrimPrefix(req.URL.Path, "/_scripts/")
+		if req.Method == http.MethodGet {
+			source, ok := m.scripts[id]
+			if !ok {
+				return estest.Response(404, fmt.Sprintf(`{"_id":%q,"found":false}`, id))
+			}
+			return estest.Response(200, fmt.Sprintf(`{"_id":%q,"found":true,"script":{"lang":"mustache","source":%q}}`, id, source))
+		}
+		var put struct {
+			Script struct {
+				Lang   string `json:"lang"`
+				Source string `json:"source"`
+			} `json:"script"`
+		}
+		json.Unmarshal(body, &put)
+		if put.Script.Lang != "mustache" {
+			return estest.Response(400, `{"error":{"type":"illegal_argument_exception","reason":"lang"},"status":400}`)
+		}
+		m.scripts[id] = put.Script.Source
+		m.puts = append(m.puts, id)
+		return estest.Response(200, `{"acknowledged":true}`)
+
+	case strings.HasPrefix(req.URL.Path, "/_render/template"):
+		var r struct {
+			Source string            `json:"source"`
+			Params map[string]string `json:"params"`
+		}
+		json.Unmarshal(body, &r)
+		if id := strings.TrimPrefix(req.URL.Path, "/_render/template/"); id != req.URL.Path {
+			r.Source = m.scripts[id]
+		}
+		m.rendered = append(m.rendered, string(body))
+		out := r.Source
+		for k, v := range r.Params {
+			out = strings.ReplaceAll(out, "{{"+k+"}}", v)
+		}
+		if !json.Valid([]byte(out)) {
+			return estest.Response(400, `{"error":{"type":"json_parse_exception","reason":"invalid"},"status":400}`)
+		}
+		return estest.Response(200, fmt.Sprintf(`{"template_output":%s}`, out))
+
+	case strings.HasSuffix(req.URL.Path, "/_search/template"):
+		m.searches = append(m.searches, req.URL.Path+" "+string(body))
+		return estest.Response(200, `{"took":1,"timed_out":false,"_shards":{"total":1,"successful":1,"skipped":0,"failed":0},"hits":{"hits":[{"_index":"articles","_id":"1","_source":{"title":"go"}}]}}`)
+
+	case strings.HasSuffix(req.URL.Path, "/_msearch/template"):
+		m.searches = append(m.searches, req.URL.Path+" "+string(body))
+		return estest.Response(200, `{"took":1,"responses":[`+
+			`{"took":1,"timed_out":false,"_shards":{"total":1,"successful":1,"skipped":0,"failed":0},"hits":{"hits":[{"_index":"articles","_id":"1"}]},"status":200},`+
+			`{"error":{"type":"index_not_found_exception","reason":"no such index"},"status":404}]}`)
+	}
+
+	return estest.Response(404, `{}`)
+}
+
+type byTitle struct {
+	Title string `json:"title"`
+}
+
+func newTestRegistry(t *testing.T, cluster *mockTemplateCluster) *Registry {
+	es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: estest.Transport(cluster.handle)})
+	reg := NewRegistry(es)
+
+	fsys := fstest.MapFS{
+		"templates/by_title.mustache": {Data: []byte(`{"query":{"match":{"title":"{{title}}"}}}` + "\n")},
+		"templates/broken.mustache":   {Data: []byte(`{"query":{"match":{"title":"{{title}}"}}`)},
+		"templates/README.md":         {Data: []byte(`Not a template`)},
+	}
+	if err := reg.Load(fsys, "templates"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	return reg
+}
+
+func TestRegistry(t *testing.T) {
+	t.Run("Load", func(t *testing.T) {
+		reg := newTestRegistry(t, &mockTemplateCluster{})
+		if ids := fmt.Sprint(reg.IDs()); ids != "[broken by_title]" {
+			t.Errorf("Unexpected templates: %s", ids)
+		}
+	})
+
+	t.Run("Check", func(t *testing.T) {
+		cluster := &mockTemplateCluster{}
+		reg := newTestRegistry(t, cluster)
+
+		if _, err := Define(reg, "by_title", byTitle{Title: "example"}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		err := reg.Check(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "[broken]") || strings.Contains(err.Error(), "[by_title]") {
+			t.Fatalf("Expected error for the broken template, got: %v", err)
+		}
+		var esErr *types.ElasticsearchError
+		if !errors.As(err, &esErr) || esErr.Status != 400 {
+			t.Errorf("Expected Elasticsearch error, got: %#v", err)
+		}
+		if len(cluster.rendered) != 2 || !strings.Contains(cluster.rendered[1], `"params":{"title":"example"}`) {
+			t.Errorf("Unexpected render requests: %v", cluster.rendered)
+		}
+
+		reg.Add("broken", `{"query":{"match_all":{}}}`)
+		if err := reg.Check(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	})
+
+	t.Run("Undefined template", func(t *testing.T) {
+		reg := newTestRegistry(t, &mockTemplateCluster{})
+		if _, err := Define(reg, "missing", byTitle{}); err == nil {
+			t.Errorf("Expected error")
+		}
+	})
+
+	t.Run("Drift and sync", func(t *testing.T) {
+		cluster := &mockTemplateCluster{scripts: map[string]string{
+			"by_title": `{"query":{"match":{"title":"{{title}}"}}}`,
+			"broken":   `{}`,
+		}}
+		reg := newTestRegistry(t, cluster)
+		reg.Add("new", `{"query":{"match_all":{}}}`)
+
+		drift, err := reg.Drift(context.Background())
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if fmt.Sprint(drift) != "[broken: changed new: missing]" {
+			t.Fatalf("Unexpected drift: %v", drift)
+		}
+		if drift[0].Stored != `{}` {
+			t.Errorf("Unexpected stored source: %s", drift[0].Stored)
+		}
+
+		if _, err := reg.Sync(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if fmt.Sprint(cluster.puts) != "[broken new]" {
+			t.Errorf("Unexpected stored templates: %v", cluster.puts)
+		}
+
+		drift, err = reg.Drift(context.Background())
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(drift) != 0 {
+			t.Errorf("Unexpected drift after sync: %v", drift)
+		}
+	})
+}
+
+func TestTemplate(t *testing.T) {
+	cluster := &mockTemplateCluster{scripts: map[string]string{
+		"by_title": `{"query":{"match":{"title":"{{title}}"}}}`,
+	}}
+	reg := newTestRegistry(t, cluster)
+	tmpl, err := Define(reg, "by_title", byTitle{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	t.Run("Search", func(t *testing.T) {
+		res, err := tmpl.Search(context.Background(), byTitle{Title: "go"}, "articles", "posts")
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(res.Hits.Hits) != 1 || res.Hits.Hits[0].Id_ != "1" {
+			t.Errorf("Unexpected hits: %+v", res.Hits.Hits)
+		}
+		if s := cluster.searches[len(cluster.searches)-1]; s != `/articles,posts/_search/template {"id":"by_title","params":{"title":"go"}}` {
+			t.Errorf("Unexpected request: %s", s)
+		}
+	})
+
+	t.Run("Multi search", func(t *testing.T) {
+		items, errs, err := tmpl.MultiSearch(context.Background(), []byTitle{{Title: "go"}, {Title: "rust"}}, "articles")
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if items[0] == nil || errs[0] != nil {
+			t.Errorf("Unexpected first response: %v, %v", items[0], errs[0])
+		}
+		var esErr *types.ElasticsearchError
+		if items[1] != nil || !errors.As(errs[1], &esErr) || esErr.Status != 404 {
+			t.Errorf("Unexpected second response: %v, %v", items[1], errs[1])
+		}
+		if s := cluster.searches[len(cluster.searches)-1]; !strings.Contains(s, `{"id":"by_title","params":{"title":"rust"}}`) {
+			t.Errorf("Unexpected request: %s", s)
+		}
+	})
+
+	t.Run("Render", func(t *testing.T) {
+		out, err := tmpl.Render(context.Background(), byTitle{Title: "go"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if string(out["query"]) != `{"match":{"title":"go"}}` {
+			t.Errorf("Unexpected output: %s", out["query"])
+		}
+	})
+
+	t.Run("Parameters must be an object", func(t *testing.T) {
+		tmpl, _ := Define(reg, "by_title", "title")
+		if _, err := tmpl.Search(context.Background(), "go"); err == nil {
+			t.Errorf("Expected error")
+		}
+	})
+}
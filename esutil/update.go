@@ -0,0 +1,202 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/elastic/elastic-transport-go/v8/elastictransport"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/get"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/index"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/optype"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/refresh"
+)
+
+// ErrSkipUpdate is returned by the update function to leave the document unchanged.
+var ErrSkipUpdate = errors.New("skip update")
+
+// ErrDocumentNotFound is returned by Update when the document doesn't exist and Upsert is not set.
+var ErrDocumentNotFound = errors.New("document not found")
+
+// The results of Update.
+const (
+	UpdateResultCreated = "created"
+	UpdateResultUpdated = "updated"
+	UpdateResultNoop    = "noop"
+)
+
+// UpdateConfig represents configuration of the update.
+type UpdateConfig struct {
+	MaxRetries   int                             // The number of retries on version conflicts. Defaults to 5.
+	RetryBackoff func(attempt int) time.Duration // The backoff before a retry. Defaults to exponential, from 10ms up to 1s.
+
+	// Upsert calls the update function with the zero value when the document
+	// doesn't exist, and creates the document.
+	Upsert bool
+
+	Routing string
+	Refresh refresh.Refresh // Refresh the shard after the write, eg. refresh.Waitfor.
+}
+
+// UpdateResult represents the outcome of the update.
+type UpdateResult struct {
+	Result      string // One of the UpdateResult constants
+	SeqNo       int64  // The sequence number of the written document
+	PrimaryTerm int64  // The primary term of the written document
+	Version     int64  // The version of the written document
+	Attempts    int    // The number of read-modify-write attempts
+}
+
+// Update reads the document, applies fn to it and writes it back, only when it
+// has not changed in the meantime, using if_seq_no and if_primary_term.
+// When the document has been changed concurrently, it retries with backoff.
+//
+// fn can return ErrSkipUpdate to leave the document unchanged. It can be called
+// several times, so it should only modify the document.
+//
+// The client is a *elasticsearch.TypedClient or a *elasticsearch.Client.
+func Update[T any](ctx context.Context, client elastictransport.Interface, index, id string, fn func(doc *T) error) (UpdateResult, error) {
+	return UpdateWithConfig(ctx, client, index, id, UpdateConfig{}, fn)
+}
+
+// UpdateWithConfig is like Update, with configuration.
+func UpdateWithConfig[T any](ctx context.Context, client elastictransport.Interface, idx, id string, cfg UpdateConfig, fn func(doc *T) error) (UpdateResult, error) {
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = 5
+	}
+	if cfg.RetryBackoff == nil {
+		cfg.RetryBackoff = func(attempt int) time.Duration {
+			d := 10 * time.Millisecond << (attempt - 1)
+			if d > time.Second || d <= 0 {
+				d = time.Second
+			}
+			return d
+		}
+	}
+
+	var result UpdateResult
+	for {
+		result.Attempts++
+
+		err := updateOnce(ctx, client, idx, id, cfg, fn, &result)
+		if err == nil || !isConflict(err) || result.Attempts > cfg.MaxRetries {
+			if err != nil {
+				err = fmt.Errorf("update [%s/%s]: %w", idx, id, err)
+			}
+			return result, err
+		}
+
+		timer := time.NewTimer(cfg.RetryBackoff(result.Attempts))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// updateOnce runs a single read-modify-write.
+func updateOnce[T any](ctx context.Context, client elastictransport.Interface, idx, id string, cfg UpdateConfig, fn func(doc *T) error, result *UpdateResult) error {
+	req := get.NewGetFunc(client)(idx, id)
+	if cfg.Routing != "" {
+		req.Routing(cfg.Routing)
+	}
+	res, err := req.Do(ctx)
+	if err != nil && !isIndexNotFound(err) {
+		return err
+	}
+	found := err == nil && res.Found
+
+	var doc T
+	if found {
+		if err := json.Unmarshal(res.Source_, &doc); err != nil {
+			return fmt.Errorf("error decoding document: %s", err)
+		}
+	} else if !cfg.Upsert {
+		return ErrDocumentNotFound
+	}
+
+	if err := fn(&doc); err != nil {
+		if errors.Is(err, ErrSkipUpdate) {
+			result.Result = UpdateResultNoop
+			if found && res.SeqNo_ != nil && res.PrimaryTerm_ != nil {
+				result.SeqNo, result.PrimaryTerm = *res.SeqNo_, *res.PrimaryTerm_
+			}
+			if found && res.Version_ != nil {
+				result.Version = *res.Version_
+			}
+			return nil
+		}
+		return err
+	}
+
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("error encoding document: %s", err)
+	}
+
+	ireq := index.NewIndexFunc(client)(idx).Id(id).Raw(bytes.NewReader(body))
+	if found {
+		if res.SeqNo_ == nil || res.PrimaryTerm_ == nil {
+			return errors.New("missing sequence number in get response")
+		}
+		ireq.IfSeqNo(strconv.FormatInt(*res.SeqNo_, 10)).IfPrimaryTerm(strconv.FormatInt(*res.PrimaryTerm_, 10))
+	} else {
+		ireq.OpType(optype.Create)
+	}
+	if cfg.Routing != "" {
+		ireq.Routing(cfg.Routing)
+	}
+	if cfg.Refresh.Name != "" {
+		ireq.Refresh(cfg.Refresh)
+	}
+
+	ires, err := ireq.Do(ctx)
+	if err != nil {
+		return err
+	}
+
+	result.Result = UpdateResultUpdated
+	if !found {
+		result.Result = UpdateResultCreated
+	}
+	result.SeqNo, result.PrimaryTerm, result.Version = ires.SeqNo_, ires.PrimaryTerm_, ires.Version_
+	return nil
+}
+
+// isConflict returns true for version conflicts, and for documents created concurrently.
+func isConflict(err error) bool {
+	var esErr *types.ElasticsearchError
+	return errors.As(err, &esErr) && esErr.Status == http.StatusConflict
+}
+
+// isIndexNotFound returns true when the index doesn't exist.
+func isIndexNotFound(err error) bool {
+	var esErr *types.ElasticsearchError
+	return errors.As(err, &esErr) && esErr.Status == http.StatusNotFound
+}
@@ -0,0 +1,201 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package esutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// mockDocumentStore serves get and index requests for a single document,
+// and simulates concurrent writes after the first numConflicts reads.
+type mockDocumentStore struct {
+	mu sync.Mutex
+
+	source       string // The document source, empty when missing
+	seqNo        int
+	noIndex      bool // Whether the index is missing
+	numConflicts int
+
+	writes []string
+}
+
+func (m *mockDocumentStore) handle(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conflict := `{"error":{"type":"version_conflict_engine_exception","reason":"conflict"},"status":409}`
+
+	switch req.Method {
+	case http.MethodGet:
+		if m.noIndex {
+			return mockResponse(404, `{"error":{"type":"index_not_found_exception","reason":"no such index"},"status":404}`)
+		}
+		if m.source == "" {
+			return mockResponse(404, `{"_index":"test","_id":"1","found":false}`)
+		}
+		res := fmt.Sprintf(`{"_index":"test","_id":"1","found":true,"_seq_no":%d,"_primary_term":1,"_version":%d,"_source":%s}`, m.seqNo, m.seqNo+1, m.source)
+		if m.numConflicts > 0 {
+			m.numConflicts--
+			m.seqNo++ // A concurrent write after the read
+		}
+		return mockResponse(200, res)
+
+	case http.MethodPut, http.MethodPost:
+		body, _ := ioutil.ReadAll(req.Body)
+		q := req.URL.Query()
+		if q.Get("op_type") == "create" {
+			if m.source != "" {
+				return mockResponse(409, conflict)
+			}
+		} else if q.Get("if_seq_no") != fmt.Sprint(m.seqNo) || q.Get("if_primary_term") != "1" {
+			return mockResponse(409, conflict)
+		}
+		m.writes = append(m.writes, req.URL.RawQuery+" "+string(body))
+		m.source = string(body)
+		m.noIndex = false
+		m.seqNo++
+		return mockResponse(200, fmt.Sprintf(`{"_index":"test","_id":"1","_seq_no":%d,"_primary_term":1,"_version":%d,"result":"updated","_shards":{"total":1,"successful":1,"failed":0}}`, m.seqNo, m.seqNo+1))
+	}
+
+	return mockResponse(400, `{}`)
+}
+
+type testCounter struct {
+	Count int    `json:"count"`
+	Name  string `json:"name,omitempty"`
+}
+
+func TestUpdate(t *testing.T) {
+	increment := func(doc *testCounter) error {
+		doc.Count++
+		return nil
+	}
+
+	t.Run("Update", func(t *testing.T) {
+		store := &mockDocumentStore{source: `{"count":1,"name":"a"}`, seqNo: 5}
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: store.handle}})
+
+		res, err := Update(context.Background(), es, "test", "1", increment)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if res.Result != UpdateResultUpdated || res.SeqNo != 6 || res.Attempts != 1 {
+			t.Errorf("Unexpected result: %+v", res)
+		}
+		if len(store.writes) != 1 || store.writes[0] != `if_primary_term=1&if_seq_no=5 {"count":2,"name":"a"}` {
+			t.Errorf("Unexpected writes: %v", store.writes)
+		}
+	})
+
+	t.Run("Retry on conflict", func(t *testing.T) {
+		store := &mockDocumentStore{source: `{"count":1}`, numConflicts: 2}
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: store.handle}})
+
+		res, err := UpdateWithConfig(context.Background(), es, "test", "1",
+			UpdateConfig{RetryBackoff: func(int) time.Duration { return time.Millisecond }}, increment)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if res.Attempts != 3 || len(store.writes) != 1 {
+			t.Errorf("Unexpected result: %+v, writes: %v", res, store.writes)
+		}
+	})
+
+	t.Run("Too many conflicts", func(t *testing.T) {
+		store := &mockDocumentStore{source: `{"count":1}`, numConflicts: 10}
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: store.handle}})
+
+		res, err := UpdateWithConfig(context.Background(), es, "test", "1",
+			UpdateConfig{MaxRetries: 2, RetryBackoff: func(int) time.Duration { return time.Millisecond }}, increment)
+
+		var esErr *types.ElasticsearchError
+		if !errors.As(err, &esErr) || esErr.Status != 409 {
+			t.Fatalf("Expected conflict error, got: %v", err)
+		}
+		if res.Attempts != 3 {
+			t.Errorf("Unexpected attempts: %d", res.Attempts)
+		}
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: (&mockDocumentStore{}).handle}})
+
+		_, err := Update(context.Background(), es, "test", "1", increment)
+		if !errors.Is(err, ErrDocumentNotFound) {
+			t.Errorf("Expected ErrDocumentNotFound, got: %v", err)
+		}
+	})
+
+	for name, store := range map[string]*mockDocumentStore{
+		"Upsert":               &mockDocumentStore{},
+		"Upsert missing index": &mockDocumentStore{noIndex: true},
+	} {
+		store := store
+		t.Run(name, func(t *testing.T) {
+			es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: store.handle}})
+
+			res, err := UpdateWithConfig(context.Background(), es, "test", "1", UpdateConfig{Upsert: true}, increment)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if res.Result != UpdateResultCreated || len(store.writes) != 1 || store.writes[0] != `op_type=create {"count":1}` {
+				t.Errorf("Unexpected result: %+v, writes: %v", res, store.writes)
+			}
+		})
+	}
+
+	t.Run("Skip", func(t *testing.T) {
+		store := &mockDocumentStore{source: `{"count":1}`, seqNo: 3}
+		es, _ := elasticsearch.NewClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: store.handle}})
+
+		res, err := Update(context.Background(), es, "test", "1", func(doc *testCounter) error {
+			return ErrSkipUpdate
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if res.Result != UpdateResultNoop || res.SeqNo != 3 || len(store.writes) != 0 {
+			t.Errorf("Unexpected result: %+v, writes: %v", res, store.writes)
+		}
+	})
+
+	t.Run("Function error", func(t *testing.T) {
+		store := &mockDocumentStore{source: `{"count":1}`}
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: &mockTransport{RoundTripFunc: store.handle}})
+
+		errBoom := errors.New("boom")
+		_, err := Update(context.Background(), es, "test", "1", func(doc *testCounter) error { return errBoom })
+		if !errors.Is(err, errBoom) || len(store.writes) != 0 {
+			t.Errorf("Expected the function error, got: %v", err)
+		}
+	})
+}
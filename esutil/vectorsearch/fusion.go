@@ -0,0 +1,172 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package vectorsearch
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/elastic/elastic-transport-go/v8/elastictransport"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/msearch"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// FusedHit represents a hit of the fused results.
+type FusedHit struct {
+	types.Hit // The hit, from the first result list which contains it
+
+	Score float64 // The fused score
+	Ranks []int   // The 1-based rank of the hit in every result list, 0 when missing
+}
+
+// Fusion combines ranked lists of hits into a single ranked list.
+type Fusion interface {
+	Fuse(lists ...[]types.Hit) []FusedHit
+}
+
+// RRF combines the lists with reciprocal rank fusion: the score of a hit is
+// the sum of 1 / (rank constant + rank) over the lists which contain it.
+// The scores of the hits are ignored, so that lists with unrelated scores,
+// such as BM25 and vector similarity, can be combined.
+type RRF struct {
+	RankConstant int // Defaults to 60.
+	WindowSize   int // The number of hits of every list to combine. Defaults to all.
+}
+
+// Fuse returns the hits of the lists, by descending fused score.
+func (f RRF) Fuse(lists ...[]types.Hit) []FusedHit {
+	k := f.RankConstant
+	if k <= 0 {
+		k = 60
+	}
+	return fuse(lists, f.WindowSize, func(list, rank int, hits []types.Hit) float64 {
+		return 1 / float64(k+rank)
+	})
+}
+
+// Linear combines the lists with the weighted sum of the scores of the hits,
+// normalized to [0, 1] in every list with min-max normalization.
+type Linear struct {
+	Weights    []float64 // The weight of every list. Defaults to 1 for lists without one.
+	WindowSize int       // The number of hits of every list to combine. Defaults to all.
+}
+
+// Fuse returns the hits of the lists, by descending fused score.
+func (f Linear) Fuse(lists ...[]types.Hit) []FusedHit {
+	window := make([][2]float64, len(lists)) // The min and max score of every list
+	for i, hits := range lists {
+		if f.WindowSize > 0 && len(hits) > f.WindowSize {
+			hits = hits[:f.WindowSize]
+		}
+		for j, h := range hits {
+			s := float64(h.Score_)
+			if j == 0 || s < window[i][0] {
+				window[i][0] = s
+			}
+			if j == 0 || s > window[i][1] {
+				window[i][1] = s
+			}
+		}
+	}
+
+	return fuse(lists, f.WindowSize, func(list, rank int, hits []types.Hit) float64 {
+		weight := 1.0
+		if list < len(f.Weights) {
+			weight = f.Weights[list]
+		}
+		min, max := window[list][0], window[list][1]
+		if max == min {
+			return weight
+		}
+		return weight * (float64(hits[rank-1].Score_) - min) / (max - min)
+	})
+}
+
+// fuse sums the scores of the hits over the lists, and sorts them by descending score.
+// Hits are identified by index and ID.
+func fuse(lists [][]types.Hit, windowSize int, score func(list, rank int, hits []types.Hit) float64) []FusedHit {
+	var (
+		fused []FusedHit
+		seen  = make(map[string]int)
+	)
+	for i, hits := range lists {
+		if windowSize > 0 && len(hits) > windowSize {
+			hits = hits[:windowSize]
+		}
+		for j, h := range hits {
+			key := h.Index_ + "\x00" + h.Id_
+			n, ok := seen[key]
+			if !ok {
+				n = len(fused)
+				seen[key] = n
+				fused = append(fused, FusedHit{Hit: h, Ranks: make([]int, len(lists))})
+			}
+			fused[n].Ranks[i] = j + 1
+			fused[n].Score += score(i, j+1, hits)
+		}
+	}
+
+	sort.SliceStable(fused, func(i, j int) bool { return fused[i].Score > fused[j].Score })
+	return fused
+}
+
+// SearchFused runs the searches on the indices with a single Multi Search API call,
+// and returns the first size hits of their fused results, eg. a lexical search
+// and kNN searches on several vector fields:
+//
+//	hits, err := vectorsearch.SearchFused(ctx, es, []string{"articles"}, vectorsearch.RRF{}, 10,
+//		types.MultisearchBody{Query: lexical, Size: some.Int(50)},
+//		types.MultisearchBody{Knn: []types.KnnQuery{vectorsearch.Knn("embedding", vector, 50).Query()}, Size: some.Int(50)},
+//	)
+//
+// The searches should return more hits than size, for the fusion to rank them.
+// It fails at the first search which fails.
+//
+// The client is a *elasticsearch.TypedClient or a *elasticsearch.Client.
+func SearchFused(ctx context.Context, client elastictransport.Interface, index []string, fusion Fusion, size int, searches ...types.MultisearchBody) ([]FusedHit, error) {
+	req := make(msearch.Request, 0, len(searches)*2)
+	for _, s := range searches {
+		req = append(req, types.MultisearchHeader{}, s)
+	}
+
+	res, err := msearch.NewMsearchFunc(client)().Index(strings.Join(index, ",")).Request(&req).Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("vectorsearch: multi search: %w", err)
+	}
+
+	lists := make([][]types.Hit, len(res.Responses))
+	for i, r := range res.Responses {
+		switch r := r.(type) {
+		case *types.MultiSearchItem:
+			lists[i] = r.Hits.Hits
+		case *types.ErrorResponseBase:
+			return nil, fmt.Errorf("vectorsearch: search %d: %w", i, &types.ElasticsearchError{ErrorCause: r.Error, Status: r.Status})
+		default:
+			return nil, fmt.Errorf("vectorsearch: search %d: unexpected response %T", i, r)
+		}
+	}
+
+	hits := fusion.Fuse(lists...)
+	if size > 0 && len(hits) > size {
+		hits = hits[:size]
+	}
+	return hits, nil
+}
@@ -0,0 +1,130 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package vectorsearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+func hits(scores map[string]float64, ids ...string) []types.Hit {
+	var hh []types.Hit
+	for _, id := range ids {
+		hh = append(hh, types.Hit{Index_: "test", Id_: id, Score_: types.Float64(scores[id])})
+	}
+	return hh
+}
+
+func ids(fused []FusedHit) string {
+	var s []string
+	for _, h := range fused {
+		s = append(s, h.Id_)
+	}
+	return strings.Join(s, ",")
+}
+
+func TestRRF(t *testing.T) {
+	lexical := hits(nil, "a", "b", "c")
+	vector := hits(nil, "c", "d", "a")
+
+	fused := RRF{}.Fuse(lexical, vector)
+	if ids(fused) != "a,c,b,d" {
+		t.Errorf("Unexpected order: %s", ids(fused))
+	}
+	if want := 1.0/61 + 1.0/63; math.Abs(fused[0].Score-want) > 1e-9 {
+		t.Errorf("Unexpected score: want=%f, got=%f", want, fused[0].Score)
+	}
+	if fmt.Sprint(fused[0].Ranks) != "[1 3]" || fmt.Sprint(fused[3].Ranks) != "[0 2]" {
+		t.Errorf("Unexpected ranks: %v, %v", fused[0].Ranks, fused[3].Ranks)
+	}
+
+	if fused := (RRF{WindowSize: 1}).Fuse(lexical, vector); ids(fused) != "a,c" {
+		t.Errorf("Unexpected order with window: %s", ids(fused))
+	}
+}
+
+func TestLinear(t *testing.T) {
+	lexical := hits(map[string]float64{"a": 12, "b": 8, "c": 2}, "a", "b", "c")
+	vector := hits(map[string]float64{"c": 0.9, "d": 0.8, "a": 0.5}, "c", "d", "a")
+
+	fused := Linear{Weights: []float64{1, 2}}.Fuse(lexical, vector)
+	if ids(fused) != "c,d,a,b" {
+		t.Errorf("Unexpected order: %s", ids(fused))
+	}
+	// a: 1 * (12-2)/10 + 2 * (0.5-0.5)/0.4 = 1
+	if fused[2].Id_ != "a" || math.Abs(fused[2].Score-1) > 1e-9 {
+		t.Errorf("Unexpected score: %+v", fused[2])
+	}
+}
+
+// mockSearchCluster serves multi search requests, with a response per search.
+type mockSearchCluster struct {
+	responses []string
+}
+
+func (m *mockSearchCluster) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(strings.NewReader(`{"took":1,"responses":[` + strings.Join(m.responses, ",") + `]}`)),
+		Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+	}, nil
+}
+
+func TestSearchFused(t *testing.T) {
+	item := func(ids ...string) string {
+		var hh []string
+		for i, id := range ids {
+			hh = append(hh, fmt.Sprintf(`{"_index":"test","_id":%q,"_score":%d}`, id, len(ids)-i))
+		}
+		return `{"took":1,"timed_out":false,"_shards":{"total":1,"successful":1,"failed":0},"hits":{"hits":[` + strings.Join(hh, ",") + `]},"status":200}`
+	}
+
+	t.Run("Fused", func(t *testing.T) {
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: &mockSearchCluster{responses: []string{item("a", "b"), item("b", "c")}}})
+		fused, err := SearchFused(context.Background(), es, []string{"test"}, RRF{}, 2, types.MultisearchBody{}, types.MultisearchBody{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if ids(fused) != "b,a" {
+			t.Errorf("Unexpected hits: %s", ids(fused))
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		es, _ := elasticsearch.NewTypedClient(elasticsearch.Config{Transport: &mockSearchCluster{responses: []string{
+			item("a"), `{"error":{"type":"illegal_argument_exception","reason":"bad"},"status":400}`,
+		}}})
+		_, err := SearchFused(context.Background(), es, []string{"test"}, RRF{}, 2, types.MultisearchBody{}, types.MultisearchBody{})
+		var esErr *types.ElasticsearchError
+		if !errors.As(err, &esErr) || esErr.Status != 400 {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
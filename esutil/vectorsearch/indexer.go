@@ -0,0 +1,169 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package vectorsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/esutil"
+)
+
+// IndexerConfig represents configuration of the vector indexer.
+type IndexerConfig struct {
+	Indexer esutil.BulkIndexer // The bulk indexer. Required.
+	Index   string             // The index of the documents. Defaults to the index of the bulk indexer.
+
+	// Dims is the number of dimensions of the dense vectors, checked before
+	// the documents are added when set, as Elasticsearch rejects the others.
+	Dims int
+
+	// Normalize scales the dense vectors to unit length,
+	// which the dot_product similarity requires.
+	Normalize bool
+
+	OnSuccess func(context.Context, esutil.BulkIndexerItem, esutil.BulkIndexerResponseItem)        // Per document
+	OnFailure func(context.Context, esutil.BulkIndexerItem, esutil.BulkIndexerResponseItem, error) // Per document
+}
+
+// Document represents a document with vector fields.
+type Document struct {
+	ID     string
+	Source interface{} // The fields of the document, encoded to a JSON object. Optional.
+
+	Dense  map[string][]float32          // The dense_vector fields, by name.
+	Sparse map[string]map[string]float32 // The sparse_vector fields, by name, as weights by token.
+}
+
+// Indexer adds documents with dense_vector and sparse_vector fields to a bulk indexer,
+// checking the vectors before they are sent.
+type Indexer struct {
+	cfg IndexerConfig
+}
+
+// NewIndexer creates a vector indexer.
+func NewIndexer(cfg IndexerConfig) (*Indexer, error) {
+	if cfg.Indexer == nil {
+		return nil, errors.New("vectorsearch: indexer is required")
+	}
+	return &Indexer{cfg: cfg}, nil
+}
+
+// Add adds the document to the bulk indexer. It returns an error when a vector is invalid:
+// a dense vector with the wrong number of dimensions or with non-finite values, or a
+// sparse vector with non-positive weights.
+func (ix *Indexer) Add(ctx context.Context, doc Document) error {
+	source := make(map[string]interface{})
+	if doc.Source != nil {
+		b, err := json.Marshal(doc.Source)
+		if err != nil {
+			return fmt.Errorf("vectorsearch: error encoding document [%s]: %s", doc.ID, err)
+		}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if err := dec.Decode(&source); err != nil {
+			return fmt.Errorf("vectorsearch: document [%s] is not an object: %s", doc.ID, err)
+		}
+	}
+
+	for field, v := range doc.Dense {
+		v, err := ix.dense(v)
+		if err != nil {
+			return fmt.Errorf("vectorsearch: document [%s]: field [%s]: %s", doc.ID, field, err)
+		}
+		source[field] = v
+	}
+	for field, v := range doc.Sparse {
+		if err := sparse(v); err != nil {
+			return fmt.Errorf("vectorsearch: document [%s]: field [%s]: %s", doc.ID, field, err)
+		}
+		source[field] = v
+	}
+
+	body, err := json.Marshal(source)
+	if err != nil {
+		return fmt.Errorf("vectorsearch: error encoding document [%s]: %s", doc.ID, err)
+	}
+
+	return ix.cfg.Indexer.Add(ctx, esutil.BulkIndexerItem{
+		Action:     "index",
+		Index:      ix.cfg.Index,
+		DocumentID: doc.ID,
+		Body:       bytes.NewReader(body),
+		OnSuccess:  ix.cfg.OnSuccess,
+		OnFailure:  ix.cfg.OnFailure,
+	})
+}
+
+// dense checks the dense vector, and returns it normalized when configured.
+func (ix *Indexer) dense(v []float32) ([]float32, error) {
+	if ix.cfg.Dims > 0 && len(v) != ix.cfg.Dims {
+		return nil, fmt.Errorf("unexpected number of dimensions: %d, expected %d", len(v), ix.cfg.Dims)
+	}
+
+	var sum float64
+	for _, x := range v {
+		if math.IsNaN(float64(x)) || math.IsInf(float64(x), 0) {
+			return nil, errors.New("non-finite value")
+		}
+		sum += float64(x) * float64(x)
+	}
+	if !ix.cfg.Normalize {
+		return v, nil
+	}
+	if sum == 0 {
+		return nil, errors.New("zero vector cannot be normalized")
+	}
+	return Normalize(v), nil
+}
+
+// sparse checks the sparse vector.
+func sparse(v map[string]float32) error {
+	for token, w := range v {
+		if !(w > 0) || math.IsInf(float64(w), 0) {
+			return fmt.Errorf("weight of token %q must be positive", token)
+		}
+		if strings.Contains(token, ".") {
+			return fmt.Errorf("token %q contains a dot", token)
+		}
+	}
+	return nil
+}
+
+// Normalize returns the vector scaled to unit length, eg. for a query vector
+// on a field with the dot_product similarity. The zero vector is returned as is.
+func Normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := math.Sqrt(sum)
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = float32(float64(x) / norm)
+	}
+	return out
+}
@@ -0,0 +1,112 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package vectorsearch
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8/esutil"
+)
+
+// mockIndexer records the items added.
+type mockIndexer struct {
+	items []esutil.BulkIndexerItem
+}
+
+func (m *mockIndexer) Add(ctx context.Context, item esutil.BulkIndexerItem) error {
+	m.items = append(m.items, item)
+	return nil
+}
+
+func (m *mockIndexer) Flush(context.Context) error    { return nil }
+func (m *mockIndexer) Close(context.Context) error    { return nil }
+func (m *mockIndexer) Stats() esutil.BulkIndexerStats { return esutil.BulkIndexerStats{} }
+
+func TestIndexer(t *testing.T) {
+	bi := &mockIndexer{}
+	ix, err := NewIndexer(IndexerConfig{Indexer: bi, Index: "vectors", Dims: 2, Normalize: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	t.Run("Add", func(t *testing.T) {
+		err := ix.Add(context.Background(), Document{
+			ID:     "1",
+			Source: map[string]interface{}{"title": "go", "n": 12345678901234567},
+			Dense:  map[string][]float32{"embedding": {3, 4}},
+			Sparse: map[string]map[string]float32{"tokens": {"go": 1.5, "client": 0.2}},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		item := bi.items[0]
+		if item.Index != "vectors" || item.DocumentID != "1" || item.Action != "index" {
+			t.Errorf("Unexpected item: %+v", item)
+		}
+		body, _ := ioutil.ReadAll(item.Body)
+		var doc struct {
+			Title     string             `json:"title"`
+			N         json.Number        `json:"n"`
+			Embedding []float64          `json:"embedding"`
+			Tokens    map[string]float64 `json:"tokens"`
+		}
+		json.Unmarshal(body, &doc)
+		if doc.Title != "go" || doc.N != "12345678901234567" || doc.Tokens["go"] != 1.5 {
+			t.Errorf("Unexpected document: %s", body)
+		}
+		if len(doc.Embedding) != 2 || math.Abs(doc.Embedding[0]-0.6) > 1e-6 || math.Abs(doc.Embedding[1]-0.8) > 1e-6 {
+			t.Errorf("Unexpected normalized vector: %v", doc.Embedding)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for name, doc := range map[string]Document{
+			"Dims":     {ID: "2", Dense: map[string][]float32{"embedding": {1, 2, 3}}},
+			"NaN":      {ID: "3", Dense: map[string][]float32{"embedding": {float32(math.NaN()), 1}}},
+			"Zero":     {ID: "4", Dense: map[string][]float32{"embedding": {0, 0}}},
+			"Weight":   {ID: "5", Sparse: map[string]map[string]float32{"tokens": {"go": 0}}},
+			"Dot":      {ID: "6", Sparse: map[string]map[string]float32{"tokens": {"a.b": 1}}},
+			"Not JSON": {ID: "7", Source: []int{1}},
+		} {
+			if err := ix.Add(context.Background(), doc); err == nil {
+				t.Errorf("%s: Expected error", name)
+			}
+		}
+		if len(bi.items) != 1 {
+			t.Errorf("Unexpected items added: %d", len(bi.items))
+		}
+	})
+}
+
+func TestNormalize(t *testing.T) {
+	v := Normalize([]float32{0, 2})
+	if v[0] != 0 || v[1] != 1 {
+		t.Errorf("Unexpected vector: %v", v)
+	}
+	if v := Normalize([]float32{0, 0}); v[0] != 0 {
+		t.Errorf("Unexpected vector: %v", v)
+	}
+}
@@ -0,0 +1,171 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package vectorsearch provides helpers for vector search: kNN queries,
+// hybrid lexical and vector searches, fusion of the results of several
+// searches, and bulk indexing of dense_vector and sparse_vector fields.
+//
+//	knn := vectorsearch.Knn("embedding", vector, 10).
+//		Filter(&types.Query{Term: map[string]types.TermQuery{"lang": {Value: "en"}}})
+//
+//	req := vectorsearch.Hybrid(lexical, knn.Query()).RRF(60, 100).Request()
+//	res, err := es.Search().Index("articles").Request(req).Do(ctx)
+package vectorsearch
+
+import (
+	"math"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+// maxNumCandidates is the maximum number of candidates accepted by Elasticsearch.
+const maxNumCandidates = 10000
+
+// NumCandidates returns the number of candidates per shard for k nearest neighbors:
+// 1.5 times k, and at least 100, for a better recall than the default for small k,
+// capped at the maximum of 10000.
+func NumCandidates(k int) int64 {
+	n := int64(math.Ceil(float64(k) * 1.5))
+	if n < 100 {
+		n = 100
+	}
+	if n > maxNumCandidates {
+		n = maxNumCandidates
+	}
+	if n < int64(k) {
+		n = int64(k)
+	}
+	return n
+}
+
+// Knn returns a kNN query for the k nearest neighbors of the vector on the field.
+func Knn(field string, vector []float32, k int) *KnnBuilder {
+	return &KnnBuilder{q: types.KnnQuery{
+		Field:         field,
+		QueryVector:   vector,
+		K:             int64(k),
+		NumCandidates: NumCandidates(k),
+	}}
+}
+
+// KnnText returns a kNN query for the k nearest neighbors of the text on the field,
+// whose vector is computed by the text embedding model deployed in Elasticsearch.
+func KnnText(field, modelID, text string, k int) *KnnBuilder {
+	return &KnnBuilder{q: types.KnnQuery{
+		Field: field,
+		QueryVectorBuilder: &types.QueryVectorBuilder{
+			TextEmbedding: &types.TextEmbedding{ModelId: modelID, ModelText: text},
+		},
+		K:             int64(k),
+		NumCandidates: NumCandidates(k),
+	}}
+}
+
+// KnnBuilder builds a kNN query.
+type KnnBuilder struct {
+	q types.KnnQuery
+}
+
+// Filter adds queries which the neighbors must match. The filters are applied
+// during the search, so that k neighbors are returned when enough documents match.
+func (b *KnnBuilder) Filter(queries ...*types.Query) *KnnBuilder {
+	for _, q := range queries {
+		if q != nil {
+			b.q.Filter = append(b.q.Filter, *q)
+		}
+	}
+	return b
+}
+
+// NumCandidates sets the number of candidates per shard, instead of the one computed from k.
+func (b *KnnBuilder) NumCandidates(n int) *KnnBuilder {
+	b.q.NumCandidates = int64(n)
+	return b
+}
+
+// Similarity sets the minimum similarity of the neighbors.
+func (b *KnnBuilder) Similarity(similarity float32) *KnnBuilder {
+	b.q.Similarity = &similarity
+	return b
+}
+
+// Boost sets the weight of the kNN scores, when combined with a query.
+func (b *KnnBuilder) Boost(boost float32) *KnnBuilder {
+	b.q.Boost = &boost
+	return b
+}
+
+// InnerHits returns the matching nested vectors with the hits.
+func (b *KnnBuilder) InnerHits(innerHits types.InnerHits) *KnnBuilder {
+	b.q.InnerHits = &innerHits
+	return b
+}
+
+// Query returns the kNN query of a search request.
+func (b *KnnBuilder) Query() types.KnnQuery {
+	q := b.q
+	q.Filter = append([]types.Query(nil), b.q.Filter...)
+	return q
+}
+
+// Hybrid returns a hybrid search of the lexical query and the kNN queries.
+// Without RRF, the scores of the query and of the kNN queries are summed.
+func Hybrid(query *types.Query, knn ...types.KnnQuery) *HybridBuilder {
+	return &HybridBuilder{query: query, knn: knn}
+}
+
+// HybridBuilder builds a hybrid search request.
+type HybridBuilder struct {
+	query *types.Query
+	knn   []types.KnnQuery
+	size  *int
+	rank  *types.RankContainer
+}
+
+// RRF combines the results with reciprocal rank fusion in Elasticsearch,
+// instead of summing the scores. The rank constant defaults to 60 when zero,
+// and the window size to the size of the search.
+func (b *HybridBuilder) RRF(rankConstant, windowSize int) *HybridBuilder {
+	rrf := types.RrfRank{}
+	if rankConstant > 0 {
+		c := int64(rankConstant)
+		rrf.RankConstant = &c
+	}
+	if windowSize > 0 {
+		w := int64(windowSize)
+		rrf.WindowSize = &w
+	}
+	b.rank = &types.RankContainer{Rrf: &rrf}
+	return b
+}
+
+// Size sets the number of hits.
+func (b *HybridBuilder) Size(size int) *HybridBuilder {
+	b.size = &size
+	return b
+}
+
+// Request returns the search request.
+func (b *HybridBuilder) Request() *search.Request {
+	return &search.Request{
+		Query: b.query,
+		Knn:   append([]types.KnnQuery(nil), b.knn...),
+		Rank:  b.rank,
+		Size:  b.size,
+	}
+}
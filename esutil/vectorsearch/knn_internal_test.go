@@ -0,0 +1,73 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package vectorsearch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+func TestNumCandidates(t *testing.T) {
+	for k, want := range map[int]int64{1: 100, 10: 100, 100: 150, 1000: 1500, 9000: 10000, 20000: 20000} {
+		if n := NumCandidates(k); n != want {
+			t.Errorf("Unexpected number of candidates for k=%d: want=%d, got=%d", k, want, n)
+		}
+	}
+}
+
+func TestKnn(t *testing.T) {
+	filter := &types.Query{Term: map[string]types.TermQuery{"lang": {Value: "en"}}}
+
+	t.Run("Vector", func(t *testing.T) {
+		q := Knn("embedding", []float32{0.5, 1}, 10).Filter(filter, nil).Similarity(0.7).Query()
+		out, _ := json.Marshal(q)
+		want := `{"field":"embedding","filter":[{"term":{"lang":{"value":"en"}}}],"k":10,"num_candidates":100,"query_vector":[0.5,1],"similarity":0.7}`
+		if string(out) != want {
+			t.Errorf("Unexpected query:\nwant: %s\ngot:  %s", want, out)
+		}
+	})
+
+	t.Run("Text", func(t *testing.T) {
+		q := KnnText("embedding", "e5", "go client", 5).NumCandidates(50).Query()
+		out, _ := json.Marshal(q)
+		want := `{"field":"embedding","k":5,"num_candidates":50,"query_vector_builder":{"text_embedding":{"model_id":"e5","model_text":"go client"}}}`
+		if string(out) != want {
+			t.Errorf("Unexpected query:\nwant: %s\ngot:  %s", want, out)
+		}
+	})
+
+	t.Run("Hybrid", func(t *testing.T) {
+		lexical := &types.Query{Match: map[string]types.MatchQuery{"title": {Query: "go"}}}
+		req := Hybrid(lexical, Knn("embedding", []float32{1}, 10).Query()).RRF(0, 50).Size(10).Request()
+		out, _ := json.Marshal(req)
+		var body map[string]interface{}
+		json.Unmarshal(out, &body)
+
+		if _, ok := body["query"]; !ok || len(body["knn"].([]interface{})) != 1 || body["size"] != 10.0 {
+			t.Errorf("Unexpected request: %s", out)
+		}
+		if rank, _ := json.Marshal(body["rank"]); string(rank) != `{"rrf":{"window_size":50}}` {
+			t.Errorf("Unexpected rank: %s", rank)
+		}
+	})
+}